@@ -0,0 +1,255 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+)
+
+type AdminUser struct {
+	ID               uuid.UUID  `json:"id"`
+	CreatedAt        time.Time  `json:"created_at"`
+	Email            string     `json:"email"`
+	Role             string     `json:"role"`
+	IsChirpyRed      bool       `json:"is_chirpy_red"`
+	SuspendedUntil   *time.Time `json:"suspended_until,omitempty"`
+	SuspensionReason string     `json:"suspension_reason,omitempty"`
+	BannedAt         *time.Time `json:"banned_at,omitempty"`
+	BanReason        string     `json:"ban_reason,omitempty"`
+	ShadowbannedAt   *time.Time `json:"shadowbanned_at,omitempty"`
+}
+
+func nullTimePtr(t sql.NullTime) *time.Time {
+	if !t.Valid {
+		return nil
+	}
+	return &t.Time
+}
+
+func adminUserJSON(u database.User) AdminUser {
+	return AdminUser{
+		ID:               u.ID,
+		CreatedAt:        u.CreatedAt,
+		Email:            u.Email,
+		Role:             u.Role,
+		IsChirpyRed:      u.IsChirpyRed,
+		SuspendedUntil:   nullTimePtr(u.SuspendedUntil),
+		SuspensionReason: u.SuspensionReason.String,
+		BannedAt:         nullTimePtr(u.BannedAt),
+		BanReason:        u.BanReason.String,
+		ShadowbannedAt:   nullTimePtr(u.ShadowbannedAt),
+	}
+}
+
+// adminTarget checks the caller is an admin and loads the user in the path.
+func (cfg *APIConfig) adminTarget(w http.ResponseWriter, r *http.Request) (admin database.User, user database.User, ok bool) {
+	admin, ok = cfg.requireRole(w, r, RoleAdmin)
+	if !ok {
+		return database.User{}, database.User{}, false
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, "Can't parse userID", 400, err)
+		return database.User{}, database.User{}, false
+	}
+
+	user, err = cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get user", 404, err)
+		return database.User{}, database.User{}, false
+	}
+
+	return admin, user, true
+}
+
+func (cfg *APIConfig) respondAdminUser(w http.ResponseWriter, r *http.Request, userID uuid.UUID) {
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get user", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, adminUserJSON(user))
+}
+
+func (cfg *APIConfig) adminGetUserHandler(w http.ResponseWriter, r *http.Request) {
+	_, user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	respondJSON(w, 200, adminUserJSON(user))
+}
+
+func (cfg *APIConfig) suspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Reason string `json:"reason"`
+		Hours  int    `json:"hours"`
+	}
+
+	admin, user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err := decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	if b.Reason == "" {
+		respondError(w, "A suspension needs a reason", 400, nil)
+		return
+	}
+	if b.Hours < 1 || b.Hours > 24*365 {
+		respondError(w, "hours must be between 1 and 8760", 400, nil)
+		return
+	}
+
+	err = cfg.db.SuspendUser(r.Context(), database.SuspendUserParams{
+		SuspendedUntil: sql.NullTime{
+			Time:  time.Now().UTC().Add(time.Duration(b.Hours) * time.Hour),
+			Valid: true,
+		},
+		SuspensionReason: sql.NullString{String: b.Reason, Valid: true},
+		ID:               user.ID,
+	})
+	if err != nil {
+		respondError(w, "Can't suspend user", 500, err)
+		return
+	}
+
+	cfg.recordAudit(r, admin.ID, AuditUserSuspended, "user", user.ID.String(), map[string]any{
+		"reason": b.Reason,
+		"hours":  b.Hours,
+	})
+	cfg.respondAdminUser(w, r, user.ID)
+}
+
+func (cfg *APIConfig) unsuspendUserHandler(w http.ResponseWriter, r *http.Request) {
+	admin, user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.SuspendUser(r.Context(), database.SuspendUserParams{
+		ID: user.ID,
+	})
+	if err != nil {
+		respondError(w, "Can't lift suspension", 500, err)
+		return
+	}
+
+	cfg.recordAudit(r, admin.ID, AuditUserUnsuspended, "user", user.ID.String(), nil)
+	cfg.respondAdminUser(w, r, user.ID)
+}
+
+func (cfg *APIConfig) banUserHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Reason string `json:"reason"`
+	}
+
+	admin, user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err := decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	if b.Reason == "" {
+		respondError(w, "A ban needs a reason", 400, nil)
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, "Can't ban user", 500, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.BanUser(r.Context(), database.BanUserParams{
+		BanReason: sql.NullString{String: b.Reason, Valid: true},
+		ID:        user.ID,
+	})
+	if err != nil {
+		respondError(w, "Can't ban user", 500, err)
+		return
+	}
+
+	err = qtx.RevokeUserTokens(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, "Can't revoke tokens", 500, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondError(w, "Can't ban user", 500, err)
+		return
+	}
+
+	cfg.recordAudit(r, admin.ID, AuditUserBanned, "user", user.ID.String(), map[string]string{"reason": b.Reason})
+	cfg.respondAdminUser(w, r, user.ID)
+}
+
+func (cfg *APIConfig) unbanUserHandler(w http.ResponseWriter, r *http.Request) {
+	admin, user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.UnbanUser(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, "Can't unban user", 500, err)
+		return
+	}
+
+	cfg.recordAudit(r, admin.ID, AuditUserUnbanned, "user", user.ID.String(), nil)
+	cfg.respondAdminUser(w, r, user.ID)
+}
+
+func (cfg *APIConfig) shadowbanUserHandler(w http.ResponseWriter, r *http.Request) {
+	admin, user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.ShadowbanUser(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, "Can't shadowban user", 500, err)
+		return
+	}
+
+	cfg.recordAudit(r, admin.ID, AuditUserShadowbanned, "user", user.ID.String(), nil)
+	cfg.respondAdminUser(w, r, user.ID)
+}
+
+func (cfg *APIConfig) unshadowbanUserHandler(w http.ResponseWriter, r *http.Request) {
+	admin, user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.UnshadowbanUser(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, "Can't lift shadowban", 500, err)
+		return
+	}
+
+	cfg.recordAudit(r, admin.ID, AuditUserUnshadowbanned, "user", user.ID.String(), nil)
+	cfg.respondAdminUser(w, r, user.ID)
+}
@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aobatake/goserver/internal/audit"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	AuditLoginSucceeded     = "login.succeeded"
+	AuditLoginFailed        = "login.failed"
+	AuditPasswordChanged    = "user.password_changed"
+	AuditTokenRevoked       = "token.revoked"
+	AuditChirpDeleted       = "chirp.deleted"
+	AuditChirpRestored      = "chirp.restored"
+	AuditAdminReset         = "admin.reset"
+	AuditUserUpgraded       = "user.upgraded"
+	AuditUserSuspended      = "admin.user_suspended"
+	AuditUserUnsuspended    = "admin.user_unsuspended"
+	AuditUserBanned         = "admin.user_banned"
+	AuditUserUnbanned       = "admin.user_unbanned"
+	AuditUserShadowbanned   = "admin.user_shadowbanned"
+	AuditUserUnshadowbanned = "admin.user_unshadowbanned"
+	AuditUserDeleted        = "user.deleted"
+	AuditUserRestored       = "user.restored"
+	AuditModerationDecision = "moderation.decision"
+	AuditSpamReviewed       = "moderation.spam_reviewed"
+)
+
+const (
+	auditBatchSize            = 1000
+	maxAuditEntriesPerRequest = 1000
+)
+
+type AuditLogEntry struct {
+	ID         int64           `json:"id"`
+	CreatedAt  time.Time       `json:"created_at"`
+	ActorID    *uuid.UUID      `json:"actor_id"`
+	Action     string          `json:"action"`
+	TargetType string          `json:"target_type"`
+	TargetID   string          `json:"target_id"`
+	IP         string          `json:"ip"`
+	RequestID  string          `json:"request_id"`
+	Metadata   json.RawMessage `json:"metadata"`
+	PrevHash   string          `json:"prev_hash"`
+	Hash       string          `json:"hash"`
+}
+
+func auditEntry(e database.AuditLog) audit.Entry {
+	actorID := ""
+	if e.ActorID.Valid {
+		actorID = e.ActorID.UUID.String()
+	}
+	return audit.Entry{
+		ID:         e.ID,
+		CreatedAt:  e.CreatedAt,
+		ActorID:    actorID,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		IP:         e.Ip,
+		RequestID:  e.RequestID,
+		Metadata:   string(e.Metadata),
+		PrevHash:   e.PrevHash,
+		Hash:       e.Hash,
+	}
+}
+
+// recordAudit appends an entry to the audit log. actorID is uuid.Nil when the
+// action wasn't taken by a signed in user. Failures are logged rather than
+// failing the request that triggered them.
+func (cfg *APIConfig) recordAudit(r *http.Request, actorID uuid.UUID, action, targetType, targetID string, metadata any) {
+	if metadata == nil {
+		metadata = struct{}{}
+	}
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		log.Printf("Can't marshal audit metadata for %s: %v", action, err)
+		return
+	}
+
+	err = cfg.appendAudit(r, audit.Entry{
+		CreatedAt:  audit.Timestamp(time.Now()),
+		Action:     action,
+		TargetType: targetType,
+		TargetID:   targetID,
+		IP:         cfg.clientIP(r),
+		RequestID:  requestID(r.Context()),
+		Metadata:   string(data),
+	}, actorID)
+	if err != nil {
+		log.Printf("Can't record audit entry %s: %v", action, err)
+	}
+}
+
+func (cfg *APIConfig) appendAudit(r *http.Request, e audit.Entry, actorID uuid.UUID) error {
+	// The request may already be cancelled, e.g. after a client disconnects,
+	// and the entry should still be written.
+	ctx := context.WithoutCancel(r.Context())
+
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	// Entries are chained, so appends have to happen one at a time.
+	err = qtx.LockAuditLog(ctx)
+	if err != nil {
+		return err
+	}
+
+	prevHash := audit.GenesisHash
+	last, err := qtx.GetLastAuditLogEntry(ctx)
+	if err == nil {
+		prevHash = last.Hash
+	} else if !errors.Is(err, sql.ErrNoRows) {
+		return err
+	}
+
+	actor := uuid.NullUUID{UUID: actorID, Valid: actorID != uuid.Nil}
+	if actor.Valid {
+		e.ActorID = actorID.String()
+	}
+
+	_, err = qtx.CreateAuditLogEntry(ctx, database.CreateAuditLogEntryParams{
+		CreatedAt:  e.CreatedAt,
+		ActorID:    actor,
+		Action:     e.Action,
+		TargetType: e.TargetType,
+		TargetID:   e.TargetID,
+		Ip:         e.IP,
+		RequestID:  e.RequestID,
+		Metadata:   json.RawMessage(e.Metadata),
+		PrevHash:   prevHash,
+		Hash:       audit.ComputeHash(prevHash, e),
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (cfg *APIConfig) getAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	_, ok := cfg.requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	q := r.URL.Query()
+	params := database.GetAuditLogParams{
+		Since:      time.Time{},
+		Until:      time.Now().UTC().Add(time.Minute),
+		MaxEntries: 100,
+	}
+	var err error
+
+	if v := q.Get("actor_id"); v != "" {
+		actorID, err := uuid.Parse(v)
+		if err != nil {
+			respondError(w, "Can't parse actor_id", 400, err)
+			return
+		}
+		params.ActorID = uuid.NullUUID{UUID: actorID, Valid: true}
+		params.FilterByActor = true
+	}
+	if v := q.Get("action"); v != "" {
+		params.Action = v
+		params.FilterByAction = true
+	}
+	if v := q.Get("target_id"); v != "" {
+		params.TargetID = v
+		params.FilterByTarget = true
+	}
+	if v := q.Get("since"); v != "" {
+		params.Since, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, "Can't parse since", 400, err)
+			return
+		}
+	}
+	if v := q.Get("until"); v != "" {
+		params.Until, err = time.Parse(time.RFC3339, v)
+		if err != nil {
+			respondError(w, "Can't parse until", 400, err)
+			return
+		}
+	}
+	if v := q.Get("after_id"); v != "" {
+		params.AfterID, err = strconv.ParseInt(v, 10, 64)
+		if err != nil {
+			respondError(w, "Can't parse after_id", 400, err)
+			return
+		}
+	}
+	if v := q.Get("limit"); v != "" {
+		limit, err := strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > maxAuditEntriesPerRequest {
+			respondError(w, "limit must be between 1 and 1000", 400, err)
+			return
+		}
+		params.MaxEntries = int32(limit)
+	}
+
+	if q.Get("format") == "csv" {
+		cfg.exportAuditLog(w, r, params)
+		return
+	}
+
+	entries, err := cfg.db.GetAuditLog(r.Context(), params)
+	if err != nil {
+		respondError(w, "Can't get audit log", 500, err)
+		return
+	}
+
+	ee := make([]AuditLogEntry, len(entries))
+	for i, e := range entries {
+		ee[i] = AuditLogEntry{
+			ID:         e.ID,
+			CreatedAt:  e.CreatedAt,
+			ActorID:    nullUUIDPtr(e.ActorID),
+			Action:     e.Action,
+			TargetType: e.TargetType,
+			TargetID:   e.TargetID,
+			IP:         e.Ip,
+			RequestID:  e.RequestID,
+			Metadata:   e.Metadata,
+			PrevHash:   e.PrevHash,
+			Hash:       e.Hash,
+		}
+	}
+
+	respondJSON(w, 200, ee)
+}
+
+// exportAuditLog streams every entry matching params as CSV, ignoring the
+// page size limit.
+func (cfg *APIConfig) exportAuditLog(w http.ResponseWriter, r *http.Request, params database.GetAuditLogParams) {
+	w.Header().Set("Content-Type", "text/csv; charset=utf-8")
+	w.Header().Set("Content-Disposition", `attachment; filename="audit_log.csv"`)
+	w.WriteHeader(200)
+
+	out := csv.NewWriter(w)
+	out.Write([]string{"id", "created_at", "actor_id", "action", "target_type", "target_id", "ip", "request_id", "metadata", "prev_hash", "hash"})
+
+	params.MaxEntries = auditBatchSize
+	for {
+		entries, err := cfg.db.GetAuditLog(r.Context(), params)
+		if err != nil {
+			log.Printf("Can't export audit log: %v", err)
+			break
+		}
+		for _, e := range entries {
+			ae := auditEntry(e)
+			out.Write([]string{
+				strconv.FormatInt(ae.ID, 10),
+				ae.CreatedAt.Format(time.RFC3339Nano),
+				ae.ActorID,
+				ae.Action,
+				ae.TargetType,
+				ae.TargetID,
+				ae.IP,
+				ae.RequestID,
+				ae.Metadata,
+				ae.PrevHash,
+				ae.Hash,
+			})
+			params.AfterID = e.ID
+		}
+		if len(entries) < auditBatchSize {
+			break
+		}
+	}
+
+	out.Flush()
+}
+
+func (cfg *APIConfig) verifyAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	_, ok := cfg.requireRole(w, r, RoleAdmin)
+	if !ok {
+		return
+	}
+
+	type response struct {
+		OK      bool   `json:"ok"`
+		Checked int    `json:"checked"`
+		Error   string `json:"error,omitempty"`
+	}
+
+	resp := response{OK: true}
+	prevHash := audit.GenesisHash
+	afterID := int64(0)
+	for {
+		entries, err := cfg.db.GetAuditLogChain(r.Context(), database.GetAuditLogChainParams{
+			ID:    afterID,
+			Limit: auditBatchSize,
+		})
+		if err != nil {
+			respondError(w, "Can't read audit log", 500, err)
+			return
+		}
+
+		chain := make([]audit.Entry, len(entries))
+		for i, e := range entries {
+			chain[i] = auditEntry(e)
+		}
+		prevHash, err = audit.Verify(prevHash, chain)
+		if err != nil {
+			resp.OK = false
+			resp.Error = err.Error()
+			break
+		}
+
+		resp.Checked += len(entries)
+		if len(entries) < auditBatchSize {
+			break
+		}
+		afterID = entries[len(entries)-1].ID
+	}
+
+	respondJSON(w, 200, resp)
+}
@@ -0,0 +1,167 @@
+package main
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+)
+
+type Bookmark struct {
+	Chirp        Chirp     `json:"chirp"`
+	BookmarkedAt time.Time `json:"bookmarked_at"`
+}
+
+// bookmarkTarget authenticates the request and parses the chirp from the
+// path.
+func (cfg *APIConfig) bookmarkTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, "Can't parse chirpID", 400, err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, chirpID, true
+}
+
+func (cfg *APIConfig) bookmarkChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userID, chirpID, ok := cfg.bookmarkTarget(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if err != nil {
+		respondError(w, "Can't get chirp", 404, err)
+		return
+	}
+
+	err = cfg.db.CreateBookmark(r.Context(), database.CreateBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondError(w, "Can't bookmark chirp", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) unbookmarkChirpHandler(w http.ResponseWriter, r *http.Request) {
+	userID, chirpID, ok := cfg.bookmarkTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.DeleteBookmark(r.Context(), database.DeleteBookmarkParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondError(w, "Can't remove bookmark", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// getBookmarksHandler lists the user's bookmarks, newest first. Bookmarked
+// chirps the user can no longer see are left out.
+func (cfg *APIConfig) getBookmarksHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 100 {
+			respondError(w, "limit must be between 1 and 100", 400, err)
+			return
+		}
+	}
+
+	before := time.Now().Add(time.Minute)
+	if b := r.URL.Query().Get("before"); b != "" {
+		before, err = time.Parse(time.RFC3339Nano, b)
+		if err != nil {
+			respondError(w, "Can't parse before", 400, err)
+			return
+		}
+	}
+
+	bookmarks, err := cfg.db.GetBookmarks(r.Context(), database.GetBookmarksParams{
+		UserID:       userID,
+		Before:       before,
+		MaxBookmarks: int32(limit),
+	})
+	if err != nil {
+		respondError(w, "Can't get bookmarks", 500, err)
+		return
+	}
+
+	cc := make([]Chirp, len(bookmarks))
+	for i, b := range bookmarks {
+		cc[i] = chirpJSON(b.Chirp)
+	}
+	err = cfg.loadChirpDetails(r.Context(), cc, userID)
+	if err != nil {
+		respondError(w, "Can't get chirp details", 500, err)
+		return
+	}
+	cc, err = cfg.applySensitivePreference(r.Context(), cc, userID)
+	if err != nil {
+		respondError(w, "Can't get chirp details", 500, err)
+		return
+	}
+
+	type response struct {
+		Bookmarks  []Bookmark `json:"bookmarks"`
+		NextCursor string     `json:"next_cursor,omitempty"`
+	}
+
+	// Chirps the viewer hides are dropped from cc, so they're matched by ID.
+	bookmarkedAt := make(map[uuid.UUID]time.Time, len(bookmarks))
+	for _, b := range bookmarks {
+		bookmarkedAt[b.Chirp.ID] = b.BookmarkedAt
+	}
+	resp := response{Bookmarks: make([]Bookmark, len(cc))}
+	for i, ch := range cc {
+		resp.Bookmarks[i] = Bookmark{
+			Chirp:        ch,
+			BookmarkedAt: bookmarkedAt[ch.ID],
+		}
+	}
+	if len(bookmarks) == limit {
+		resp.NextCursor = bookmarks[len(bookmarks)-1].BookmarkedAt.Format(time.RFC3339Nano)
+	}
+
+	respondJSON(w, 200, resp)
+}
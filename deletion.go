@@ -0,0 +1,375 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	// deletionRetention is how long deleted chirps and accounts are kept,
+	// and so how long their owners have to restore them.
+	deletionRetention = 30 * 24 * time.Hour
+	purgeInterval     = time.Hour
+)
+
+// What happens to the chirps of an account its owner deletes, set with
+// DELETED_ACCOUNT_CHIRPS. Deleted chirps go away with the account and come
+// back if it is restored; anonymized chirps are moved to a placeholder
+// account straight away and stay up after the account is purged.
+const (
+	DeletedChirpsDelete    = "delete"
+	DeletedChirpsAnonymize = "anonymize"
+)
+
+const anonymousUserEmail = "deleted@chirpy.invalid"
+
+var anonymousUserID = uuid.MustParse("ffffffff-ffff-ffff-ffff-ffffffffffff")
+
+func (c *APIConfig) restoreChirpHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, c.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, "Can't parse chirpID", 400, err)
+		return
+	}
+
+	ch, err := c.db.GetDeletedChirp(r.Context(), chirpID)
+	if err != nil {
+		respondError(w, "Can't get deleted chirp", 404, err)
+		return
+	}
+
+	if ch.UserID != userID {
+		respondError(w, "User is not author of chirp", 403, nil)
+		return
+	}
+
+	restored, err := c.db.RestoreChirp(r.Context(), database.RestoreChirpParams{
+		ID:           ch.ID,
+		DeletedAfter: time.Now().UTC().Add(-deletionRetention),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "Chirp can no longer be restored", 410, err)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't restore chirp", 500, err)
+		return
+	}
+
+	chirpResponse := chirpJSON(restored)
+
+	user, err := c.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get user", 500, err)
+		return
+	}
+	if broadcastable(user, chirpResponse) {
+		c.publishChirpEvent(r.Context(), events.ChirpCreated, chirpResponse)
+	}
+	c.recordAudit(r, userID, AuditChirpRestored, "chirp", ch.ID.String(), nil)
+
+	respondJSON(w, 200, chirpResponse)
+}
+
+func (cfg *APIConfig) adminDeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	admin, user, ok := cfg.adminTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.softDeleteUser(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, "Can't delete user", 500, err)
+		return
+	}
+
+	cfg.recordAudit(r, admin.ID, AuditUserDeleted, "user", user.ID.String(), nil)
+
+	w.WriteHeader(204)
+}
+
+// softDeleteUser marks an account deleted and signs it out everywhere. The
+// account and its chirps disappear from every read until it is restored or
+// purged.
+func (cfg *APIConfig) softDeleteUser(ctx context.Context, userID uuid.UUID) error {
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.SoftDeleteUser(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	err = qtx.RevokeUserTokens(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (c *APIConfig) deleteMeHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Password string `json:"password"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, c.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+
+	user, err := c.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	err = auth.CheckPasswordHash(b.Password, user.HashedPassword)
+	if err != nil {
+		respondError(w, "Password is incorrect", 403, err)
+		return
+	}
+
+	tx, err := c.sqlDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, "Can't delete account", 500, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	// The confirmation has to be stored before the account is marked
+	// deleted, as deleted accounts don't receive notifications.
+	n, err := qtx.CreateNotification(r.Context(), database.CreateNotificationParams{
+		Type:   NotificationAccount,
+		UserID: user.ID,
+	})
+	notified := err == nil
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "Can't delete account", 500, err)
+		return
+	}
+
+	if c.deletedChirps == DeletedChirpsAnonymize {
+		err = qtx.CreateAnonymousUser(r.Context(), database.CreateAnonymousUserParams{
+			ID:    anonymousUserID,
+			Email: anonymousUserEmail,
+		})
+		if err != nil {
+			respondError(w, "Can't anonymize chirps", 500, err)
+			return
+		}
+
+		_, err = qtx.AnonymizeUserChirps(r.Context(), database.AnonymizeUserChirpsParams{
+			AnonymousID: anonymousUserID,
+			UserID:      user.ID,
+		})
+		if err != nil {
+			respondError(w, "Can't anonymize chirps", 500, err)
+			return
+		}
+
+		err = qtx.AnonymizeUserAttachments(r.Context(), database.AnonymizeUserAttachmentsParams{
+			AnonymousID: anonymousUserID,
+			UserID:      user.ID,
+		})
+		if err != nil {
+			respondError(w, "Can't anonymize attachments", 500, err)
+			return
+		}
+	}
+
+	err = qtx.DowngradeUser(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, "Can't cancel Chirpy Red", 500, err)
+		return
+	}
+
+	err = qtx.RevokeUserTokens(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, "Can't revoke tokens", 500, err)
+		return
+	}
+
+	err = qtx.SoftDeleteUser(r.Context(), user.ID)
+	if err != nil {
+		respondError(w, "Can't delete account", 500, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondError(w, "Can't delete account", 500, err)
+		return
+	}
+
+	if notified {
+		c.publishNotification(r.Context(), n)
+	}
+	c.recordAudit(r, user.ID, AuditUserDeleted, "user", user.ID.String(), map[string]any{
+		"chirps":         c.deletedChirps,
+		"was_chirpy_red": user.IsChirpyRed,
+	})
+
+	w.WriteHeader(204)
+}
+
+func (c *APIConfig) restoreUserHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Email    string `json:"email"`
+		Password string `json:"password"`
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err := decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+
+	user, err := c.db.GetDeletedUser(r.Context(), b.Email)
+	if err != nil {
+		respondError(w, "401 Unauthorized", 401, err)
+		return
+	}
+
+	err = auth.CheckPasswordHash(b.Password, user.HashedPassword)
+	if err != nil {
+		respondError(w, "401 Unauthorized", 401, err)
+		return
+	}
+
+	user, err = c.db.RestoreUser(r.Context(), database.RestoreUserParams{
+		ID:           user.ID,
+		DeletedAfter: time.Now().UTC().Add(-deletionRetention),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "Account can no longer be restored", 410, err)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't restore account", 500, err)
+		return
+	}
+
+	c.recordAudit(r, user.ID, AuditUserRestored, "user", user.ID.String(), nil)
+
+	userJSON := User{
+		ID:          user.ID,
+		CreatedAt:   user.CreatedAt,
+		UpdatedAt:   user.UpdatedAt,
+		Email:       user.Email,
+		IsChirpyRed: user.IsChirpyRed,
+	}
+
+	respondJSON(w, 200, userJSON)
+}
+
+// runPurgeJob permanently removes chirps and accounts once they have been
+// deleted for longer than deletionRetention, along with expired data exports.
+// It runs until ctx is done.
+func (cfg *APIConfig) runPurgeJob(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		cfg.purgeDeleted(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *APIConfig) purgeDeleted(ctx context.Context) {
+	cutoff := time.Now().UTC().Add(-deletionRetention)
+
+	cfg.purgeAttachments(ctx, cutoff)
+
+	n, err := cfg.db.PurgeDeletedChirps(ctx, cutoff)
+	if err != nil {
+		log.Printf("Can't purge deleted chirps: %v", err)
+	} else if n > 0 {
+		log.Printf("Purged %d deleted chirps", n)
+	}
+
+	// Purging a user cascades to everything they own.
+	n, err = cfg.db.PurgeDeletedUsers(ctx, cutoff)
+	if err != nil {
+		log.Printf("Can't purge deleted users: %v", err)
+	} else if n > 0 {
+		log.Printf("Purged %d deleted users", n)
+	}
+
+	n, err = cfg.db.PurgeUnusedLinkPreviews(ctx, time.Now().UTC().Add(-linkPreviewRetention))
+	if err != nil {
+		log.Printf("Can't purge link previews: %v", err)
+	} else if n > 0 {
+		log.Printf("Purged %d unused link previews", n)
+	}
+
+	n, err = cfg.db.PurgeChirpEvents(ctx, time.Now().UTC().Add(-chirpEventReplayWindow))
+	if err != nil {
+		log.Printf("Can't purge chirp events: %v", err)
+	} else if n > 0 {
+		log.Printf("Purged %d chirp events", n)
+	}
+
+	n, err = cfg.db.PurgeRateLimitBuckets(ctx, time.Now().UTC().Add(-rateLimitRetention))
+	if err != nil {
+		log.Printf("Can't purge rate limit buckets: %v", err)
+	} else if n > 0 {
+		log.Printf("Purged %d rate limit buckets", n)
+	}
+
+	n, err = cfg.db.PurgeExpiredDataExports(ctx, time.Now().UTC())
+	if err != nil {
+		log.Printf("Can't purge expired exports: %v", err)
+	} else if n > 0 {
+		log.Printf("Purged %d expired exports", n)
+	}
+}
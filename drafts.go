@@ -0,0 +1,557 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/validate"
+	"github.com/google/uuid"
+)
+
+const (
+	// draftPublishInterval is how often due scheduled drafts are published.
+	draftPublishInterval = 30 * time.Second
+	// A scheduled draft that fails for a reason that may pass is tried
+	// again after draftRetryDelay, doubling each time, and fails once it
+	// has been tried maxDraftPublishAttempts times.
+	draftRetryDelay         = time.Minute
+	maxDraftPublishAttempts = 5
+)
+
+var (
+	errPublishAtPast = validate.Errorf("publish_at", "publish_at must be in the future")
+	// errDraftNotPublished is shown to the author of a draft that failed
+	// for an internal reason, which is only logged.
+	errDraftNotPublished = errors.New("Draft couldn't be published")
+)
+
+// Draft is an unpublished chirp. Drafts with a PublishAt are published by
+// the draft publisher once that time comes. If that fails, PublishAt is
+// cleared and PublishError says why.
+type Draft struct {
+	ID             uuid.UUID   `json:"id"`
+	CreatedAt      time.Time   `json:"created_at"`
+	UpdatedAt      time.Time   `json:"updated_at"`
+	Body           string      `json:"body"`
+	MediaIDs       []uuid.UUID `json:"media_ids"`
+	PublishAt      *time.Time  `json:"publish_at"`
+	PublishError   string      `json:"publish_error,omitempty"`
+	Visibility     string      `json:"visibility"`
+	ContentWarning string      `json:"content_warning,omitempty"`
+	Sensitive      bool        `json:"sensitive"`
+}
+
+func draftJSON(d database.Draft) Draft {
+	mediaIDs := d.MediaIds
+	if mediaIDs == nil {
+		mediaIDs = []uuid.UUID{}
+	}
+	return Draft{
+		ID:             d.ID,
+		CreatedAt:      d.CreatedAt,
+		UpdatedAt:      d.UpdatedAt,
+		Body:           d.Body,
+		MediaIDs:       mediaIDs,
+		PublishAt:      nullTimePtr(d.PublishAt),
+		PublishError:   d.PublishError.String,
+		Visibility:     d.Visibility,
+		ContentWarning: d.ContentWarning.String,
+		Sensitive:      d.Sensitive,
+	}
+}
+
+type draftRequest struct {
+	Body           string      `json:"body"`
+	MediaIDs       []uuid.UUID `json:"media_ids"`
+	PublishAt      *time.Time  `json:"publish_at"`
+	Visibility     string      `json:"visibility"`
+	ContentWarning string      `json:"content_warning"`
+	Sensitive      bool        `json:"sensitive"`
+}
+
+// validate checks the draft would make a valid chirp. Media is checked when
+// the draft is published.
+func (b draftRequest) validate(now time.Time) error {
+	errs := validate.Errors{}
+	_, err := cleanChirpBody(b.Body)
+	errs.Add(err)
+	if len(b.MediaIDs) > maxAttachmentsPerChirp {
+		errs.Add(errTooManyAttachments)
+	}
+	if b.PublishAt != nil && !b.PublishAt.After(now) {
+		errs.Add(errPublishAtPast)
+	}
+	_, err = chirpVisibility(b.Visibility)
+	errs.Add(err)
+	_, err = contentWarning(b.ContentWarning)
+	errs.Add(err)
+	return errs.Err()
+}
+
+func (b draftRequest) publishAt() sql.NullTime {
+	if b.PublishAt == nil {
+		return sql.NullTime{}
+	}
+	return sql.NullTime{Time: b.PublishAt.UTC(), Valid: true}
+}
+
+func (b draftRequest) visibility() string {
+	visibility, _ := chirpVisibility(b.Visibility)
+	return visibility
+}
+
+func (b draftRequest) contentWarning() sql.NullString {
+	warning, _ := contentWarning(b.ContentWarning)
+	return warning
+}
+
+func (b draftRequest) mediaIDs() []uuid.UUID {
+	if b.MediaIDs == nil {
+		return []uuid.UUID{}
+	}
+	return b.MediaIDs
+}
+
+func (cfg *APIConfig) createDraftHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxChirpRequestBytes)
+	decoder := json.NewDecoder(r.Body)
+	b := draftRequest{}
+	err = decoder.Decode(&b)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondError(w, "Request body is too large", 413, err)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	err = b.validate(time.Now().UTC())
+	if err != nil {
+		respondValidationError(w, err)
+		return
+	}
+
+	d, err := cfg.db.CreateDraft(r.Context(), database.CreateDraftParams{
+		UserID:         userID,
+		Body:           b.Body,
+		MediaIds:       b.mediaIDs(),
+		PublishAt:      b.publishAt(),
+		Visibility:     b.visibility(),
+		ContentWarning: b.contentWarning(),
+		Sensitive:      b.Sensitive,
+	})
+	if err != nil {
+		respondError(w, "Can't create draft", 500, err)
+		return
+	}
+
+	respondJSON(w, 201, draftJSON(d))
+}
+
+// getDraftsHandler lists the user's drafts, or only the scheduled ones with
+// ?scheduled=true.
+func (cfg *APIConfig) getDraftsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	drafts, err := cfg.db.GetDrafts(r.Context(), database.GetDraftsParams{
+		UserID:        userID,
+		ScheduledOnly: r.URL.Query().Get("scheduled") == "true",
+	})
+	if err != nil {
+		respondError(w, "Can't get drafts", 500, err)
+		return
+	}
+
+	dd := make([]Draft, len(drafts))
+	for i, d := range drafts {
+		dd[i] = draftJSON(d)
+	}
+
+	respondJSON(w, 200, dd)
+}
+
+func (cfg *APIConfig) getDraftHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondError(w, "Can't parse draftID", 400, err)
+		return
+	}
+
+	d, err := cfg.db.GetDraft(r.Context(), database.GetDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondError(w, "Draft not found", 404, err)
+		return
+	}
+
+	respondJSON(w, 200, draftJSON(d))
+}
+
+func (cfg *APIConfig) updateDraftHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondError(w, "Can't parse draftID", 400, err)
+		return
+	}
+
+	r.Body = http.MaxBytesReader(w, r.Body, maxChirpRequestBytes)
+	decoder := json.NewDecoder(r.Body)
+	b := draftRequest{}
+	err = decoder.Decode(&b)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondError(w, "Request body is too large", 413, err)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	err = b.validate(time.Now().UTC())
+	if err != nil {
+		respondValidationError(w, err)
+		return
+	}
+
+	// A draft that is being published is locked, so this waits for the
+	// publisher and then finds the draft gone.
+	d, err := cfg.db.UpdateDraft(r.Context(), database.UpdateDraftParams{
+		Body:           b.Body,
+		MediaIds:       b.mediaIDs(),
+		PublishAt:      b.publishAt(),
+		Visibility:     b.visibility(),
+		ContentWarning: b.contentWarning(),
+		Sensitive:      b.Sensitive,
+		ID:             draftID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondError(w, "Draft not found", 404, err)
+		return
+	}
+
+	respondJSON(w, 200, draftJSON(d))
+}
+
+func (cfg *APIConfig) deleteDraftHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondError(w, "Can't parse draftID", 400, err)
+		return
+	}
+
+	n, err := cfg.db.DeleteDraft(r.Context(), database.DeleteDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondError(w, "Can't delete draft", 500, err)
+		return
+	}
+	if n == 0 {
+		respondError(w, "Draft not found", 404, nil)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// unscheduleDraftHandler cancels a scheduled post, keeping it as a draft.
+func (cfg *APIConfig) unscheduleDraftHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondError(w, "Can't parse draftID", 400, err)
+		return
+	}
+
+	d, err := cfg.db.UnscheduleDraft(r.Context(), database.UnscheduleDraftParams{
+		ID:     draftID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondError(w, "Scheduled draft not found", 404, err)
+		return
+	}
+
+	respondJSON(w, 200, draftJSON(d))
+}
+
+// publishDraftHandler publishes a draft straight away.
+func (cfg *APIConfig) publishDraftHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	draftID, err := uuid.Parse(r.PathValue("draftID"))
+	if err != nil {
+		respondError(w, "Can't parse draftID", 400, err)
+		return
+	}
+
+	_, ch, err := cfg.publishDraft(r.Context(), func(qtx *database.Queries) (database.Draft, error) {
+		return qtx.ClaimDraft(r.Context(), database.ClaimDraftParams{
+			ID:     draftID,
+			UserID: userID,
+		})
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "Draft not found", 404, err)
+		return
+	}
+	if respondRateLimited(w, err) {
+		return
+	}
+	if errors.Is(err, errUserSuspended) || errors.Is(err, errUserBanned) || errors.Is(err, errSpam) {
+		respondError(w, err.Error(), 403, err)
+		return
+	}
+	if errors.Is(err, errInvalidMedia) || errors.As(err, new(*validate.FieldError)) {
+		respondError(w, err.Error(), 400, err)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't publish draft", 500, err)
+		return
+	}
+
+	// Held chirps are accepted but only published once a moderator
+	// approves them.
+	if ch.Held {
+		respondJSON(w, 202, ch)
+		return
+	}
+	respondJSON(w, 201, ch)
+}
+
+// publishDraft turns the draft returned by claim into a chirp and deletes
+// it, all in one transaction. claim must lock the draft so it can't be
+// published twice.
+func (cfg *APIConfig) publishDraft(ctx context.Context, claim func(qtx *database.Queries) (database.Draft, error)) (database.Draft, Chirp, error) {
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return database.Draft{}, Chirp{}, err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	d, err := claim(qtx)
+	if err != nil {
+		return database.Draft{}, Chirp{}, err
+	}
+
+	user, err := qtx.GetUserByID(ctx, d.UserID)
+	if err != nil {
+		return d, Chirp{}, err
+	}
+
+	body, err := cleanChirpBody(d.Body)
+	if err != nil {
+		return d, Chirp{}, err
+	}
+
+	nc := newChirp{
+		Body:           body,
+		MediaIDs:       d.MediaIds,
+		Visibility:     d.Visibility,
+		ContentWarning: d.ContentWarning,
+		Sensitive:      d.Sensitive,
+		Source:         ChirpSourceDraft,
+	}
+	ch, err := cfg.postChirp(ctx, qtx, user, nc)
+	if err != nil {
+		return d, Chirp{}, err
+	}
+
+	_, err = qtx.DeleteDraft(ctx, database.DeleteDraftParams{
+		ID:     d.ID,
+		UserID: d.UserID,
+	})
+	if err != nil {
+		return d, Chirp{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return d, Chirp{}, err
+	}
+	cfg.chirpPosted(ctx, user, nc, ch)
+	return d, ch, nil
+}
+
+// runDraftPublisher publishes scheduled drafts once they are due. It runs
+// until ctx is done.
+func (cfg *APIConfig) runDraftPublisher(ctx context.Context) {
+	ticker := time.NewTicker(draftPublishInterval)
+	defer ticker.Stop()
+
+	for {
+		cfg.publishDueDrafts(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *APIConfig) publishDueDrafts(ctx context.Context) {
+	for {
+		// Drafts are claimed with SKIP LOCKED, so other instances move on
+		// to the next due draft instead of publishing the same one.
+		d, _, err := cfg.publishDraft(ctx, func(qtx *database.Queries) (database.Draft, error) {
+			return qtx.ClaimDueDraft(ctx)
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return
+		}
+		var limited *rateLimitError
+		if errors.As(err, &limited) {
+			// The author has posted too much lately, so the draft waits
+			// until they may post again.
+			err = cfg.db.DelayDraft(ctx, database.DelayDraftParams{
+				PublishAt: time.Now().UTC().Add(limited.Result.RetryAfter),
+				ID:        d.ID,
+			})
+			if err != nil {
+				log.Printf("Can't delay draft %s: %v", d.ID, err)
+				return
+			}
+			continue
+		}
+		if errors.Is(err, errUserSuspended) || errors.Is(err, errUserBanned) || errors.Is(err, errSpam) ||
+			errors.Is(err, errInvalidMedia) || errors.As(err, new(*validate.FieldError)) {
+			// Retrying won't help, so the draft goes back to the author with
+			// the reason.
+			err = cfg.db.FailDraft(ctx, database.FailDraftParams{
+				PublishError: sql.NullString{String: err.Error(), Valid: true},
+				ID:           d.ID,
+			})
+			if err != nil {
+				log.Printf("Can't mark draft %s as failed: %v", d.ID, err)
+				return
+			}
+			continue
+		}
+		if err != nil && d.ID == uuid.Nil {
+			// No draft was claimed, so the database is likely down.
+			log.Printf("Can't claim a due draft: %v", err)
+			return
+		}
+		if err != nil {
+			// Other drafts shouldn't wait behind this one, so it's put
+			// back for later and the publisher moves on.
+			log.Printf("Can't publish draft %s: %v", d.ID, err)
+			err = cfg.retryDraft(ctx, d)
+			if err != nil {
+				log.Printf("Can't reschedule draft %s: %v", d.ID, err)
+				return
+			}
+		}
+	}
+}
+
+// retryDraft records that d failed to publish and schedules it to be tried
+// again, or fails it after maxDraftPublishAttempts.
+func (cfg *APIConfig) retryDraft(ctx context.Context, d database.Draft) error {
+	reason := sql.NullString{String: errDraftNotPublished.Error(), Valid: true}
+	if d.PublishAttempts+1 >= maxDraftPublishAttempts {
+		return cfg.db.FailDraft(ctx, database.FailDraftParams{
+			PublishError: reason,
+			ID:           d.ID,
+		})
+	}
+	return cfg.db.RetryDraft(ctx, database.RetryDraftParams{
+		PublishAt:    time.Now().UTC().Add(draftRetryDelay << d.PublishAttempts),
+		PublishError: reason,
+		ID:           d.ID,
+	})
+}
@@ -0,0 +1,573 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/export"
+	"github.com/google/uuid"
+)
+
+const (
+	// exportCooldown is how long a user waits between export requests.
+	exportCooldown = 24 * time.Hour
+	// exportRetention is how long a finished archive is kept.
+	exportRetention = 7 * 24 * time.Hour
+	// exportLinkTTL is how long a download link works once handed out.
+	exportLinkTTL  = time.Hour
+	exportDeadline = 5 * time.Minute
+	// exportLease is how long an instance keeps an export it claimed. It
+	// outlasts exportDeadline so a running build is never taken over.
+	exportLease = exportDeadline + time.Minute
+	// exportResumeInterval is how often instances look for exports nobody
+	// is building.
+	exportResumeInterval = time.Minute
+)
+
+type DataExport struct {
+	ID          uuid.UUID  `json:"id"`
+	Status      string     `json:"status"`
+	CreatedAt   time.Time  `json:"created_at"`
+	CompletedAt *time.Time `json:"completed_at"`
+	ExpiresAt   *time.Time `json:"expires_at"`
+	SizeBytes   int64      `json:"size_bytes"`
+	DownloadURL string     `json:"download_url,omitempty"`
+}
+
+type exportProfile struct {
+	ID                      uuid.UUID       `json:"id"`
+	CreatedAt               time.Time       `json:"created_at"`
+	UpdatedAt               time.Time       `json:"updated_at"`
+	Email                   string          `json:"email"`
+	IsChirpyRed             bool            `json:"is_chirpy_red"`
+	Role                    string          `json:"role"`
+	DMPolicy                string          `json:"dm_policy"`
+	Protected               bool            `json:"protected"`
+	SensitiveChirps         string          `json:"sensitive_chirps"`
+	NotificationPreferences json.RawMessage `json:"notification_preferences"`
+	SuspendedUntil          *time.Time      `json:"suspended_until"`
+	SuspensionReason        string          `json:"suspension_reason,omitempty"`
+	BannedAt                *time.Time      `json:"banned_at"`
+	BanReason               string          `json:"ban_reason,omitempty"`
+}
+
+type exportChirp struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	UpdatedAt      time.Time  `json:"updated_at"`
+	Body           string     `json:"body"`
+	Visibility     string     `json:"visibility"`
+	ContentWarning string     `json:"content_warning,omitempty"`
+	Sensitive      bool       `json:"sensitive"`
+	HiddenAt       *time.Time `json:"hidden_at"`
+	DeletedAt      *time.Time `json:"deleted_at"`
+}
+
+type exportAttachment struct {
+	ID          uuid.UUID  `json:"id"`
+	CreatedAt   time.Time  `json:"created_at"`
+	ChirpID     *uuid.UUID `json:"chirp_id"`
+	ContentType string     `json:"content_type"`
+	SizeBytes   int64      `json:"size_bytes"`
+	Width       int32      `json:"width"`
+	Height      int32      `json:"height"`
+	AltText     string     `json:"alt_text"`
+}
+
+type exportPollVote struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	PollID    uuid.UUID `json:"poll_id"`
+	Option    string    `json:"option"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportBookmark struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+type exportList struct {
+	List
+	Members []Relationship `json:"members"`
+}
+
+type exportSession struct {
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	RevokedAt *time.Time `json:"revoked_at"`
+}
+
+func (cfg *APIConfig) dataExportJSON(e database.DataExport) DataExport {
+	de := DataExport{
+		ID:          e.ID,
+		Status:      e.Status,
+		CreatedAt:   e.CreatedAt,
+		CompletedAt: nullTimePtr(e.CompletedAt),
+		ExpiresAt:   nullTimePtr(e.ExpiresAt),
+		SizeBytes:   e.SizeBytes,
+	}
+
+	if e.Status == "ready" && e.ExpiresAt.Time.After(time.Now().UTC()) {
+		expires := time.Now().UTC().Add(exportLinkTTL)
+		if expires.After(e.ExpiresAt.Time) {
+			expires = e.ExpiresAt.Time
+		}
+		de.DownloadURL = fmt.Sprintf("/api/exports/%s/download?expires=%d&signature=%s",
+			e.ID, expires.Unix(), auth.SignURL("exports/"+e.ID.String(), expires, cfg.JWTSecret))
+	}
+
+	return de
+}
+
+func (cfg *APIConfig) requestExportHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, "Can't create export", 500, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.LockUserDataExports(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't create export", 500, err)
+		return
+	}
+
+	// Failed exports don't count towards the limit.
+	e, err := qtx.CreateDataExport(r.Context(), database.CreateDataExportParams{
+		UserID: userID,
+		Since:  time.Now().UTC().Add(-exportCooldown),
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		latest, err := qtx.GetLatestDataExport(r.Context(), userID)
+		if err != nil {
+			respondError(w, "Can't check previous exports", 500, err)
+			return
+		}
+		wait := max(time.Until(latest.CreatedAt.Add(exportCooldown)), 0)
+		w.Header().Set("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+		respondError(w, "An export was requested recently", 429, nil)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't create export", 500, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondError(w, "Can't create export", 500, err)
+		return
+	}
+
+	go cfg.buildDataExport(e.ID)
+
+	respondJSON(w, 202, cfg.dataExportJSON(e))
+}
+
+func (cfg *APIConfig) getExportHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondError(w, "Can't parse exportID", 400, err)
+		return
+	}
+
+	e, err := cfg.db.GetDataExport(r.Context(), exportID)
+	if err != nil || e.UserID != userID {
+		respondError(w, "Export not found", 404, err)
+		return
+	}
+
+	respondJSON(w, 200, cfg.dataExportJSON(e))
+}
+
+// downloadExportHandler serves an archive to anyone holding a signed link, so
+// it can be opened straight from a browser.
+func (cfg *APIConfig) downloadExportHandler(w http.ResponseWriter, r *http.Request) {
+	exportID, err := uuid.Parse(r.PathValue("exportID"))
+	if err != nil {
+		respondError(w, "Can't parse exportID", 400, err)
+		return
+	}
+
+	expires, err := strconv.ParseInt(r.URL.Query().Get("expires"), 10, 64)
+	if err != nil {
+		respondError(w, "Download link is invalid", 403, err)
+		return
+	}
+	err = auth.ValidateURLSignature("exports/"+exportID.String(), time.Unix(expires, 0), r.URL.Query().Get("signature"), cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Download link is invalid", 403, err)
+		return
+	}
+
+	e, err := cfg.db.GetDataExport(r.Context(), exportID)
+	if err != nil {
+		respondError(w, "Export not found", 404, err)
+		return
+	}
+	if e.Status != "ready" || !e.ExpiresAt.Time.After(time.Now().UTC()) {
+		respondError(w, "Export is no longer available", 410, nil)
+		return
+	}
+
+	archive, err := cfg.db.GetDataExportArchive(r.Context(), exportID)
+	if err != nil {
+		respondError(w, "Can't get export", 500, err)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/zip")
+	w.Header().Set("Content-Disposition", fmt.Sprintf(`attachment; filename="chirpy-export-%s.zip"`, e.CreatedAt.Format("2006-01-02")))
+	w.Header().Set("Content-Length", strconv.Itoa(len(archive)))
+	w.WriteHeader(200)
+	w.Write(archive)
+}
+
+// buildDataExport claims an export, gathers everything held on its user into
+// an archive and stores it, marking the export failed if anything goes wrong.
+// Nothing happens if another instance holds the export.
+func (cfg *APIConfig) buildDataExport(exportID uuid.UUID) {
+	e, err := cfg.db.ClaimDataExport(context.Background(), database.ClaimDataExportParams{
+		LeaseUntil: time.Now().UTC().Add(exportLease),
+		ID:         exportID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Can't claim export %s: %v", exportID, err)
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), exportDeadline)
+	defer cancel()
+
+	err = cfg.storeDataExport(ctx, e.ID, e.UserID)
+	if err != nil {
+		log.Printf("Can't build export %s: %v", exportID, err)
+		err = cfg.db.FailDataExport(context.Background(), exportID)
+		if err != nil {
+			log.Printf("Can't mark export %s failed: %v", exportID, err)
+		}
+	}
+}
+
+func (cfg *APIConfig) storeDataExport(ctx context.Context, exportID, userID uuid.UUID) error {
+	sections, email, err := cfg.exportSections(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	buf := bytes.Buffer{}
+	err = export.Write(&buf, email, time.Now().UTC(), sections)
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.StoreDataExportArchive(ctx, database.StoreDataExportArchiveParams{
+		ExportID: exportID,
+		Archive:  buf.Bytes(),
+	})
+	if err != nil {
+		return err
+	}
+
+	err = qtx.CompleteDataExport(ctx, database.CompleteDataExportParams{
+		ExpiresAt: time.Now().UTC().Add(exportRetention),
+		SizeBytes: int64(buf.Len()),
+		ID:        exportID,
+	})
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (cfg *APIConfig) exportSections(ctx context.Context, userID uuid.UUID) ([]export.Section, string, error) {
+	user, err := cfg.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	// Shadowbans are left out on purpose: telling the user would defeat them.
+	profile := exportProfile{
+		ID:                      user.ID,
+		CreatedAt:               user.CreatedAt,
+		UpdatedAt:               user.UpdatedAt,
+		Email:                   user.Email,
+		IsChirpyRed:             user.IsChirpyRed,
+		Role:                    user.Role,
+		DMPolicy:                user.DmPolicy,
+		Protected:               user.Protected,
+		SensitiveChirps:         user.SensitiveChirps,
+		NotificationPreferences: user.NotificationPreferences,
+		SuspendedUntil:          nullTimePtr(user.SuspendedUntil),
+		SuspensionReason:        user.SuspensionReason.String,
+		BannedAt:                nullTimePtr(user.BannedAt),
+		BanReason:               user.BanReason.String,
+	}
+
+	dbChirps, err := cfg.db.GetUserChirps(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	chirps := make([]exportChirp, len(dbChirps))
+	for i, ch := range dbChirps {
+		chirps[i] = exportChirp{
+			ID:             ch.ID,
+			CreatedAt:      ch.CreatedAt,
+			UpdatedAt:      ch.UpdatedAt,
+			Body:           ch.Body,
+			Visibility:     ch.Visibility,
+			ContentWarning: ch.ContentWarning.String,
+			Sensitive:      ch.Sensitive,
+			HiddenAt:       nullTimePtr(ch.HiddenAt),
+			DeletedAt:      nullTimePtr(ch.DeletedAt),
+		}
+	}
+
+	dbAttachments, err := cfg.db.GetUserAttachments(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	attachments := make([]exportAttachment, len(dbAttachments))
+	for i, a := range dbAttachments {
+		attachments[i] = exportAttachment{
+			ID:          a.ID,
+			CreatedAt:   a.CreatedAt,
+			ChirpID:     nullUUIDPtr(a.ChirpID),
+			ContentType: a.ContentType,
+			SizeBytes:   a.SizeBytes,
+			Width:       a.Width,
+			Height:      a.Height,
+			AltText:     a.AltText,
+		}
+	}
+
+	dbVotes, err := cfg.db.GetUserPollVotes(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	votes := make([]exportPollVote, len(dbVotes))
+	for i, v := range dbVotes {
+		votes[i] = exportPollVote{
+			ChirpID:   v.ChirpID,
+			PollID:    v.PollID,
+			Option:    v.Text,
+			CreatedAt: v.CreatedAt,
+		}
+	}
+
+	dbSessions, err := cfg.db.GetUserSessions(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	sessions := make([]exportSession, len(dbSessions))
+	for i, s := range dbSessions {
+		sessions[i] = exportSession{
+			CreatedAt: s.CreatedAt,
+			UpdatedAt: s.UpdatedAt,
+			ExpiresAt: s.ExpiresAt,
+			RevokedAt: nullTimePtr(s.RevokedAt),
+		}
+	}
+
+	dbNotifications, err := cfg.db.GetUserNotifications(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	notifications := make([]Notification, len(dbNotifications))
+	for i, n := range dbNotifications {
+		notifications[i] = Notification{
+			ID:        n.ID,
+			CreatedAt: n.CreatedAt,
+			Type:      n.Type,
+			ActorID:   nullUUIDPtr(n.ActorID),
+			ChirpID:   nullUUIDPtr(n.ChirpID),
+		}
+	}
+
+	dbMessages, err := cfg.db.GetSentMessages(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	messages := make([]Message, len(dbMessages))
+	for i, m := range dbMessages {
+		messages[i] = Message{
+			ID:             m.ID,
+			CreatedAt:      m.CreatedAt,
+			ConversationID: m.ConversationID,
+			SenderID:       m.SenderID,
+			Body:           m.Body,
+		}
+	}
+
+	dbBlocks, err := cfg.db.GetBlockedUsers(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	blocks := make([]Relationship, len(dbBlocks))
+	for i, b := range dbBlocks {
+		blocks[i] = Relationship{UserID: b.BlockedID, CreatedAt: b.CreatedAt}
+	}
+
+	dbMutes, err := cfg.db.GetMutedUsers(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	mutes := make([]Relationship, len(dbMutes))
+	for i, m := range dbMutes {
+		mutes[i] = Relationship{UserID: m.MutedID, CreatedAt: m.CreatedAt}
+	}
+
+	dbBookmarks, err := cfg.db.GetUserBookmarks(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	bookmarks := make([]exportBookmark, len(dbBookmarks))
+	for i, b := range dbBookmarks {
+		bookmarks[i] = exportBookmark{ChirpID: b.ChirpID, CreatedAt: b.CreatedAt}
+	}
+
+	dbLists, err := cfg.db.GetUserLists(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	dbMembers, err := cfg.db.GetUserListMembers(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	members := map[uuid.UUID][]Relationship{}
+	for _, m := range dbMembers {
+		members[m.ListID] = append(members[m.ListID], Relationship{UserID: m.UserID, CreatedAt: m.CreatedAt})
+	}
+	lists := make([]exportList, len(dbLists))
+	for i, l := range dbLists {
+		lists[i] = exportList{List: listJSON(l), Members: members[l.ID]}
+		if lists[i].Members == nil {
+			lists[i].Members = []Relationship{}
+		}
+	}
+
+	dbFollowing, err := cfg.db.GetFollowing(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	following := make([]Follow, len(dbFollowing))
+	for i, f := range dbFollowing {
+		following[i] = followJSON(f.FolloweeID, f)
+	}
+
+	dbFollowers, err := cfg.db.GetFollowers(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	followers := make([]Follow, len(dbFollowers))
+	for i, f := range dbFollowers {
+		followers[i] = followJSON(f.FollowerID, f)
+	}
+
+	dbRequests, err := cfg.db.GetFollowRequests(ctx, userID)
+	if err != nil {
+		return nil, "", err
+	}
+	followRequests := make([]Follow, len(dbRequests))
+	for i, f := range dbRequests {
+		followRequests[i] = followJSON(f.FollowerID, f)
+	}
+
+	dbDrafts, err := cfg.db.GetDrafts(ctx, database.GetDraftsParams{UserID: userID})
+	if err != nil {
+		return nil, "", err
+	}
+	drafts := make([]Draft, len(dbDrafts))
+	for i, d := range dbDrafts {
+		drafts[i] = draftJSON(d)
+	}
+
+	return []export.Section{
+		{Name: "profile", Description: "Your account details and settings", Data: profile},
+		{Name: "chirps", Description: "Every chirp you have posted, including hidden and deleted ones not yet purged", Data: chirps},
+		{Name: "attachments", Description: "Images you have uploaded, with the chirps they are attached to", Data: attachments},
+		{Name: "poll_votes", Description: "Your votes in polls", Data: votes},
+		{Name: "drafts", Description: "Drafts and scheduled chirps you haven't published", Data: drafts},
+		{Name: "sessions", Description: "Sign-ins on your account", Data: sessions},
+		{Name: "notifications", Description: "Notifications you have received", Data: notifications},
+		{Name: "messages", Description: "Direct messages you have sent", Data: messages},
+		{Name: "blocks", Description: "Users you have blocked", Data: blocks},
+		{Name: "mutes", Description: "Users you have muted", Data: mutes},
+		{Name: "bookmarks", Description: "Chirps you have bookmarked", Data: bookmarks},
+		{Name: "lists", Description: "Lists you have made and the users on them", Data: lists},
+		{Name: "following", Description: "Users you follow or have asked to follow", Data: following},
+		{Name: "followers", Description: "Users who follow you", Data: followers},
+		{Name: "follow_requests", Description: "Requests to follow you that you haven't answered", Data: followRequests},
+	}, user.Email, nil
+}
+
+// resumeDataExports restarts exports that were still being built when an
+// instance stopped, once their lease has run out, checking every
+// exportResumeInterval. It runs until ctx is done.
+func (cfg *APIConfig) resumeDataExports(ctx context.Context) {
+	ticker := time.NewTicker(exportResumeInterval)
+	defer ticker.Stop()
+
+	for {
+		pending, err := cfg.db.GetClaimableDataExports(ctx)
+		if err != nil {
+			log.Printf("Can't get pending exports: %v", err)
+		}
+		for _, e := range pending {
+			go cfg.buildDataExport(e.ID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,268 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+)
+
+// Follow statuses. Following a protected account starts out as a request
+// that the account has to approve.
+const (
+	FollowStatusFollowing = "following"
+	FollowStatusRequested = "requested"
+)
+
+type Follow struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+	Status    string    `json:"status"`
+}
+
+// followJSON describes f from the follower's side, or from the followee's
+// side for follow requests.
+func followJSON(userID uuid.UUID, f database.Follow) Follow {
+	status := FollowStatusFollowing
+	if !f.ApprovedAt.Valid {
+		status = FollowStatusRequested
+	}
+	return Follow{
+		UserID:    userID,
+		CreatedAt: f.CreatedAt,
+		Status:    status,
+	}
+}
+
+func (cfg *APIConfig) followUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	blocked, err := cfg.db.IsBlocked(r.Context(), database.IsBlockedParams{
+		BlockerID: targetID,
+		BlockedID: userID,
+	})
+	if err != nil {
+		respondError(w, "Can't check blocks", 500, err)
+		return
+	}
+	if blocked {
+		respondError(w, "Can't follow this user", 403, nil)
+		return
+	}
+
+	f, err := cfg.db.CreateFollow(r.Context(), database.CreateFollowParams{
+		FollowerID: userID,
+		FolloweeID: targetID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		// Already following or requested.
+		f, err = cfg.db.GetFollow(r.Context(), database.GetFollowParams{
+			FollowerID: userID,
+			FolloweeID: targetID,
+		})
+		if err != nil {
+			respondError(w, "Can't get follow", 500, err)
+			return
+		}
+		respondJSON(w, 200, followJSON(targetID, f))
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't follow user", 500, err)
+		return
+	}
+
+	notificationType := NotificationFollow
+	if !f.ApprovedAt.Valid {
+		notificationType = NotificationFollowRequest
+	}
+	cfg.notify(r.Context(), targetID, notificationType, uuid.NullUUID{UUID: userID, Valid: true}, uuid.NullUUID{})
+
+	respondJSON(w, 201, followJSON(targetID, f))
+}
+
+// unfollowUserHandler stops following a user, or withdraws a pending
+// follow request.
+func (cfg *APIConfig) unfollowUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.DeleteFollow(r.Context(), database.DeleteFollowParams{
+		FollowerID: userID,
+		FolloweeID: targetID,
+	})
+	if err != nil {
+		respondError(w, "Can't unfollow user", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) getFollowRequestsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	requests, err := cfg.db.GetFollowRequests(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get follow requests", 500, err)
+		return
+	}
+
+	resp := make([]Follow, len(requests))
+	for i, f := range requests {
+		resp[i] = followJSON(f.FollowerID, f)
+	}
+
+	respondJSON(w, 200, resp)
+}
+
+func (cfg *APIConfig) approveFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, requesterID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	f, err := cfg.db.ApproveFollowRequest(r.Context(), database.ApproveFollowRequestParams{
+		FollowerID: requesterID,
+		FolloweeID: userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "Follow request not found", 404, err)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't approve follow request", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, followJSON(requesterID, f))
+}
+
+func (cfg *APIConfig) rejectFollowRequestHandler(w http.ResponseWriter, r *http.Request) {
+	userID, requesterID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	n, err := cfg.db.RejectFollowRequest(r.Context(), database.RejectFollowRequestParams{
+		FollowerID: requesterID,
+		FolloweeID: userID,
+	})
+	if err != nil {
+		respondError(w, "Can't reject follow request", 500, err)
+		return
+	}
+	if n == 0 {
+		respondError(w, "Follow request not found", 404, nil)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) getPrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get user", 404, err)
+		return
+	}
+
+	respondJSON(w, 200, struct {
+		Protected bool `json:"protected"`
+	}{
+		Protected: user.Protected,
+	})
+}
+
+// updatePrivacyHandler protects or unprotects the user's account. Pending
+// follow requests are approved when it stops being protected.
+func (cfg *APIConfig) updatePrivacyHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Protected bool `json:"protected"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, "Can't update privacy settings", 500, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.UpdateProtected(r.Context(), database.UpdateProtectedParams{
+		Protected: b.Protected,
+		ID:        userID,
+	})
+	if err != nil {
+		respondError(w, "Can't update privacy settings", 500, err)
+		return
+	}
+
+	if !b.Protected {
+		err = qtx.ApproveAllFollowRequests(r.Context(), userID)
+		if err != nil {
+			respondError(w, "Can't approve follow requests", 500, err)
+			return
+		}
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondError(w, "Can't update privacy settings", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, b)
+}
@@ -0,0 +1,392 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/chirpimport"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxImportItems  = 10000
+	importBatchSize = 100
+	maxImportErrors = 1000
+	// importLease is how long an instance keeps an import after claiming it
+	// or finishing a batch. Another instance takes the import over if the
+	// lease runs out.
+	importLease = 2 * time.Minute
+	// importResumeInterval is how often instances look for imports nobody
+	// is working on.
+	importResumeInterval = time.Minute
+)
+
+var errImportLeaseLost = errors.New("Chirp import was taken over by another instance")
+
+type ChirpImport struct {
+	ID        uuid.UUID          `json:"id"`
+	CreatedAt time.Time          `json:"created_at"`
+	UpdatedAt time.Time          `json:"updated_at"`
+	Status    string             `json:"status"`
+	Total     int32              `json:"total"`
+	Processed int32              `json:"processed"`
+	Imported  int32              `json:"imported"`
+	Skipped   int32              `json:"skipped"`
+	Failed    int32              `json:"failed"`
+	Errors    []ChirpImportError `json:"errors,omitempty"`
+}
+
+type ChirpImportError struct {
+	Item  int32  `json:"item"`
+	Error string `json:"error"`
+}
+
+func chirpImportJSON(ci database.ChirpImport) ChirpImport {
+	return ChirpImport{
+		ID:        ci.ID,
+		CreatedAt: ci.CreatedAt,
+		UpdatedAt: ci.UpdatedAt,
+		Status:    ci.Status,
+		Total:     ci.Total,
+		Processed: ci.Processed,
+		Imported:  ci.Imported,
+		Skipped:   ci.Skipped,
+		Failed:    ci.Failed,
+	}
+}
+
+// importChirpsHandler accepts a JSON Lines or ZIP archive of chirps and
+// imports it in the background. Progress is reported by
+// getChirpImportHandler.
+func (cfg *APIConfig) importChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+	if user.BannedAt.Valid {
+		respondError(w, errUserBanned.Error(), 403, nil)
+		return
+	}
+	if user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now().UTC()) {
+		respondError(w, errUserSuspended.Error(), 403, nil)
+		return
+	}
+
+	unfinished, err := cfg.db.CountUnfinishedChirpImports(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't check running imports", 500, err)
+		return
+	}
+	if unfinished > 0 {
+		respondError(w, "An import is already running", 409, nil)
+		return
+	}
+
+	data, err := io.ReadAll(http.MaxBytesReader(w, r.Body, chirpimport.MaxSize))
+	if err != nil {
+		respondError(w, "Archive is too large", 413, err)
+		return
+	}
+
+	records, err := chirpimport.Records(data)
+	if err != nil {
+		respondError(w, "Can't read archive: "+err.Error(), 400, err)
+		return
+	}
+	total := bytes.Count(records, []byte{'\n'}) + 1
+	if total > maxImportItems {
+		respondError(w, "Archive has more than 10000 chirps", 400, nil)
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, "Can't create import", 500, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	ci, err := qtx.CreateChirpImport(r.Context(), database.CreateChirpImportParams{
+		UserID: userID,
+		Total:  int32(total),
+	})
+	if err != nil {
+		respondError(w, "Can't create import", 500, err)
+		return
+	}
+
+	err = qtx.StoreChirpImportRecords(r.Context(), database.StoreChirpImportRecordsParams{
+		ImportID: ci.ID,
+		Records:  records,
+	})
+	if err != nil {
+		respondError(w, "Can't create import", 500, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondError(w, "Can't create import", 500, err)
+		return
+	}
+
+	go cfg.runChirpImport(ci.ID)
+
+	respondJSON(w, 202, chirpImportJSON(ci))
+}
+
+func (cfg *APIConfig) getChirpImportHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	importID, err := uuid.Parse(r.PathValue("importID"))
+	if err != nil {
+		respondError(w, "Can't parse importID", 400, err)
+		return
+	}
+
+	ci, err := cfg.db.GetChirpImport(r.Context(), importID)
+	if err != nil || ci.UserID != userID {
+		respondError(w, "Import not found", 404, err)
+		return
+	}
+
+	importErrors, err := cfg.db.GetChirpImportErrors(r.Context(), database.GetChirpImportErrorsParams{
+		ImportID: ci.ID,
+		Limit:    maxImportErrors,
+	})
+	if err != nil {
+		respondError(w, "Can't get import errors", 500, err)
+		return
+	}
+
+	resp := chirpImportJSON(ci)
+	for _, e := range importErrors {
+		resp.Errors = append(resp.Errors, ChirpImportError{Item: e.Item, Error: e.Error})
+	}
+
+	respondJSON(w, 200, resp)
+}
+
+// runChirpImport claims an import and works through its records in batches,
+// saving its progress after each one so an interrupted import resumes where
+// it stopped. Nothing happens if another instance holds the import.
+func (cfg *APIConfig) runChirpImport(importID uuid.UUID) {
+	ctx := context.Background()
+
+	ci, err := cfg.db.ClaimChirpImport(ctx, database.ClaimChirpImportParams{
+		LeaseUntil: time.Now().UTC().Add(importLease),
+		ID:         importID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Can't claim chirp import %s: %v", importID, err)
+		return
+	}
+
+	err = cfg.importChirps(ctx, ci)
+	if errors.Is(err, errImportLeaseLost) {
+		log.Printf("Stopped chirp import %s: %v", ci.ID, err)
+		return
+	}
+	if err != nil {
+		log.Printf("Chirp import %s failed: %v", ci.ID, err)
+		err = cfg.db.FinishChirpImport(ctx, database.FinishChirpImportParams{
+			Status: "failed",
+			ID:     ci.ID,
+		})
+		if err != nil {
+			log.Printf("Can't mark chirp import %s failed: %v", ci.ID, err)
+		}
+	}
+}
+
+func (cfg *APIConfig) importChirps(ctx context.Context, ci database.ChirpImport) error {
+	records, err := cfg.db.GetChirpImportRecords(ctx, ci.ID)
+	if err != nil {
+		return err
+	}
+	lines := bytes.Split(records, []byte{'\n'})
+
+	for start := int(ci.Processed); start < len(lines); start += importBatchSize {
+		end := min(start+importBatchSize, len(lines))
+		err = cfg.importChirpBatch(ctx, ci, lines[start:end], start)
+		if err != nil {
+			return err
+		}
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	err = qtx.FinishChirpImport(ctx, database.FinishChirpImportParams{
+		Status: "completed",
+		ID:     ci.ID,
+	})
+	if err != nil {
+		return err
+	}
+
+	err = qtx.DeleteChirpImportRecords(ctx, ci.ID)
+	if err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// importChirpBatch imports records, the first of which is item offset+1, and
+// records the progress in the same transaction, renewing the lease on the
+// import. It returns errImportLeaseLost if the lease has run out.
+func (cfg *APIConfig) importChirpBatch(ctx context.Context, ci database.ChirpImport, records [][]byte, offset int) error {
+	user, err := cfg.db.GetUserByID(ctx, ci.UserID)
+	if err != nil {
+		return err
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	progress := database.UpdateChirpImportProgressParams{
+		Processed: int32(offset + len(records)),
+		ID:        ci.ID,
+	}
+	now := time.Now().UTC()
+	var posted []newChirp
+	var chirps []Chirp
+
+	for i, record := range records {
+		item := int32(offset + i + 1)
+
+		nc, ch, itemErr := cfg.importChirp(ctx, qtx, user, record, now)
+		if errors.Is(itemErr, sql.ErrNoRows) {
+			progress.Skipped++
+			continue
+		}
+		if itemErr != nil {
+			progress.Failed++
+			err = qtx.CreateChirpImportError(ctx, database.CreateChirpImportErrorParams{
+				ImportID: ci.ID,
+				Item:     item,
+				Error:    itemErr.Error(),
+			})
+			if err != nil {
+				return err
+			}
+			continue
+		}
+		progress.Imported++
+		posted = append(posted, nc)
+		chirps = append(chirps, ch)
+	}
+
+	progress.LeaseUntil = time.Now().UTC().Add(importLease)
+	updated, err := qtx.UpdateChirpImportProgress(ctx, progress)
+	if err != nil {
+		return err
+	}
+	if updated == 0 {
+		return errImportLeaseLost
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return err
+	}
+	for i, nc := range posted {
+		cfg.chirpPosted(ctx, user, nc, chirps[i])
+	}
+	return nil
+}
+
+// importChirp validates one record with the same rules as chirpHandler and
+// posts it through postChirp with its original timestamp, so it gets the
+// same spam checks, links and mentions as any other chirp. See newChirp for
+// what imports skip. It returns sql.ErrNoRows when the chirp was already
+// imported.
+func (cfg *APIConfig) importChirp(ctx context.Context, qtx *database.Queries, user database.User, record []byte, now time.Time) (newChirp, Chirp, error) {
+	item, err := chirpimport.Decode(record, now)
+	if err != nil {
+		return newChirp{}, Chirp{}, err
+	}
+
+	body, err := cleanChirpBody(item.Body)
+	if err != nil {
+		return newChirp{}, Chirp{}, err
+	}
+
+	nc := newChirp{
+		Body:       body,
+		Visibility: VisibilityPublic,
+		Source:     ChirpSourceImport,
+		CreatedAt:  item.CreatedAt,
+		ImportKey:  sql.NullString{String: item.Key(), Valid: true},
+	}
+	ch, err := cfg.postChirp(ctx, qtx, user, nc)
+	return nc, ch, err
+}
+
+// resumeChirpImports restarts imports that were interrupted when an instance
+// stopped, once their lease has run out, checking every
+// importResumeInterval. It runs until ctx is done.
+func (cfg *APIConfig) resumeChirpImports(ctx context.Context) {
+	ticker := time.NewTicker(importResumeInterval)
+	defer ticker.Stop()
+
+	for {
+		imports, err := cfg.db.GetClaimableChirpImports(ctx)
+		if err != nil {
+			log.Printf("Can't get unfinished chirp imports: %v", err)
+		}
+		for _, ci := range imports {
+			go cfg.runChirpImport(ci.ID)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
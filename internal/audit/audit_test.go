@@ -0,0 +1,71 @@
+package audit
+
+import (
+	"testing"
+	"time"
+)
+
+func chain(n int) []Entry {
+	entries := make([]Entry, n)
+	prev := GenesisHash
+	for i := range entries {
+		entries[i] = Entry{
+			ID:        int64(i + 1),
+			CreatedAt: time.Now(),
+			ActorID:   "actor",
+			Action:    "login.succeeded",
+			Metadata:  "{}",
+			PrevHash:  prev,
+		}
+		entries[i].Hash = ComputeHash(prev, entries[i])
+		prev = entries[i].Hash
+	}
+	return entries
+}
+
+func TestVerifyChain(t *testing.T) {
+	entries := chain(5)
+	last, err := Verify(GenesisHash, entries)
+	if err != nil {
+		t.Errorf("Valid chain failed verification: %v", err)
+	}
+	if last != entries[4].Hash {
+		t.Errorf("Wrong last hash")
+	}
+
+	// Verifying in batches gives the same result.
+	mid, err := Verify(GenesisHash, entries[:2])
+	if err != nil {
+		t.Errorf("Valid chain failed verification: %v", err)
+	}
+	_, err = Verify(mid, entries[2:])
+	if err != nil {
+		t.Errorf("Valid chain failed verification: %v", err)
+	}
+}
+
+func TestVerifyDetectsEdit(t *testing.T) {
+	entries := chain(5)
+	entries[2].Action = "login.failed"
+	_, err := Verify(GenesisHash, entries)
+	if err == nil {
+		t.Errorf("Edited entry wasn't detected")
+	}
+}
+
+func TestVerifyDetectsRemoval(t *testing.T) {
+	entries := chain(5)
+	entries = append(entries[:2], entries[3:]...)
+	_, err := Verify(GenesisHash, entries)
+	if err == nil {
+		t.Errorf("Removed entry wasn't detected")
+	}
+}
+
+func TestFieldBoundaries(t *testing.T) {
+	a := Entry{Action: "ab", TargetType: "c"}
+	b := Entry{Action: "a", TargetType: "bc"}
+	if ComputeHash(GenesisHash, a) == ComputeHash(GenesisHash, b) {
+		t.Errorf("Different entries have the same hash")
+	}
+}
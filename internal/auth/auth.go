@@ -1,10 +1,13 @@
 package auth
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
+	"crypto/sha256"
 	"encoding/hex"
 	"fmt"
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -86,3 +89,24 @@ func GetAPIKey(headers http.Header) (string, error) {
 	key := strings.Split(authHeader, " ")[1]
 	return key, nil
 }
+
+// SignURL returns a signature that grants access to resource until expiresAt,
+// for links that have to work without an Authorization header.
+func SignURL(resource string, expiresAt time.Time, secret string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(resource))
+	mac.Write([]byte{0})
+	mac.Write([]byte(strconv.FormatInt(expiresAt.Unix(), 10)))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func ValidateURLSignature(resource string, expiresAt time.Time, signature, secret string) error {
+	if time.Now().After(expiresAt) {
+		return fmt.Errorf("Link has expired")
+	}
+	expected := SignURL(resource, expiresAt, secret)
+	if !hmac.Equal([]byte(expected), []byte(signature)) {
+		return fmt.Errorf("Signature is invalid")
+	}
+	return nil
+}
@@ -62,3 +62,30 @@ func TestGetAPIKey(t *testing.T) {
 		t.Errorf("Can't parse API Key")
 	}
 }
+
+func TestURLSignature(t *testing.T) {
+	secret := "thisisthesecret"
+	expiresAt := time.Now().Add(time.Hour)
+	signature := SignURL("exports/123", expiresAt, secret)
+
+	err := ValidateURLSignature("exports/123", expiresAt, signature, secret)
+	if err != nil {
+		t.Errorf("Can't validate signature: %v", err)
+	}
+
+	err = ValidateURLSignature("exports/456", expiresAt, signature, secret)
+	if err == nil {
+		t.Errorf("Signature is valid for another resource")
+	}
+
+	err = ValidateURLSignature("exports/123", expiresAt.Add(time.Hour), signature, secret)
+	if err == nil {
+		t.Errorf("Signature is valid for another expiry")
+	}
+
+	expired := time.Now().Add(-time.Minute)
+	err = ValidateURLSignature("exports/123", expired, SignURL("exports/123", expired, secret), secret)
+	if err == nil {
+		t.Errorf("Expired signature is valid")
+	}
+}
@@ -0,0 +1,173 @@
+// Package chirpimport reads chirp archives exported from other platforms.
+// An archive is either JSON Lines, one chirp per line, or a ZIP holding
+// .jsonl files and/or a chirps.json array like the one in our own exports.
+package chirpimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"sort"
+	"strings"
+	"time"
+)
+
+// MaxSize is the most data an archive may hold once uncompressed.
+const MaxSize = 10 << 20
+
+var (
+	ErrTooLarge    = errors.New("Archive is too large")
+	ErrNoChirps    = errors.New("Archive doesn't contain any chirps")
+	ErrMissingBody = errors.New("body is missing")
+	ErrMissingTime = errors.New("created_at is missing")
+	ErrFutureChirp = errors.New("created_at is in the future")
+)
+
+var zipSignature = []byte("PK\x03\x04")
+
+type Item struct {
+	// ID is the chirp's ID on the platform it came from, if it had one.
+	ID        string    `json:"id"`
+	Body      string    `json:"body"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// Key identifies an item across imports so re-importing an archive doesn't
+// duplicate chirps. Items without an ID are identified by their content.
+func (i Item) Key() string {
+	if i.ID != "" {
+		return "id:" + i.ID
+	}
+	h := sha256.New()
+	h.Write([]byte(i.CreatedAt.UTC().Format(time.RFC3339Nano)))
+	h.Write([]byte{0})
+	h.Write([]byte(i.Body))
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
+
+// Records splits an archive into one compact JSON record per line, so the
+// result can be stored and worked through line by line.
+func Records(data []byte) ([]byte, error) {
+	if len(data) > MaxSize {
+		return nil, ErrTooLarge
+	}
+
+	var records [][]byte
+	var err error
+	if bytes.HasPrefix(data, zipSignature) {
+		records, err = zipRecords(data)
+	} else {
+		records, err = lineRecords(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if len(records) == 0 {
+		return nil, ErrNoChirps
+	}
+
+	return bytes.Join(records, []byte{'\n'}), nil
+}
+
+func lineRecords(data []byte) ([][]byte, error) {
+	var records [][]byte
+	for _, line := range bytes.Split(data, []byte{'\n'}) {
+		line = bytes.TrimSpace(line)
+		if len(line) == 0 {
+			continue
+		}
+		records = append(records, compact(line))
+	}
+	return records, nil
+}
+
+func zipRecords(data []byte) ([][]byte, error) {
+	zr, err := zip.NewReader(bytes.NewReader(data), int64(len(data)))
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]*zip.File, 0, len(zr.File))
+	for _, f := range zr.File {
+		name := path.Base(f.Name)
+		if strings.HasSuffix(name, ".jsonl") || name == "chirps.json" {
+			files = append(files, f)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].Name < files[j].Name
+	})
+
+	var records [][]byte
+	remaining := int64(MaxSize)
+	for _, f := range files {
+		rc, err := f.Open()
+		if err != nil {
+			return nil, err
+		}
+		// Read one byte past the limit to tell a full archive from a zip bomb.
+		content, err := io.ReadAll(io.LimitReader(rc, remaining+1))
+		rc.Close()
+		if err != nil {
+			return nil, err
+		}
+		remaining -= int64(len(content))
+		if remaining < 0 {
+			return nil, ErrTooLarge
+		}
+
+		if strings.HasSuffix(f.Name, ".jsonl") {
+			lines, _ := lineRecords(content)
+			records = append(records, lines...)
+			continue
+		}
+
+		items := []json.RawMessage{}
+		err = json.Unmarshal(content, &items)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", f.Name, err)
+		}
+		for _, item := range items {
+			records = append(records, compact(item))
+		}
+	}
+
+	return records, nil
+}
+
+// compact puts a record on one line. Records that aren't valid JSON are
+// kept as they are so Decode can report them against the right line.
+func compact(record []byte) []byte {
+	buf := bytes.Buffer{}
+	if json.Compact(&buf, record) != nil {
+		return bytes.Clone(record)
+	}
+	return buf.Bytes()
+}
+
+// Decode parses and checks one record. It doesn't check the body against the
+// chirp rules, which belong to the caller.
+func Decode(record []byte, now time.Time) (Item, error) {
+	item := Item{}
+	err := json.Unmarshal(record, &item)
+	if err != nil {
+		return Item{}, fmt.Errorf("invalid JSON: %w", err)
+	}
+	if strings.TrimSpace(item.Body) == "" {
+		return Item{}, ErrMissingBody
+	}
+	if item.CreatedAt.IsZero() {
+		return Item{}, ErrMissingTime
+	}
+	if item.CreatedAt.After(now) {
+		return Item{}, ErrFutureChirp
+	}
+	item.CreatedAt = item.CreatedAt.UTC()
+	return item, nil
+}
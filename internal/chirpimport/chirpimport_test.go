@@ -0,0 +1,100 @@
+package chirpimport
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRecordsJSONLines(t *testing.T) {
+	data := []byte("{\"body\": \"first\", \"created_at\": \"2020-01-01T00:00:00Z\"}\n\n  {\"body\":\"second\", \"created_at\":\"2020-01-02T00:00:00Z\"}\nnot json\n")
+	records, err := Records(data)
+	if err != nil {
+		t.Fatalf("Can't read records: %v", err)
+	}
+
+	lines := strings.Split(string(records), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("Expected 3 records, got %d: %q", len(lines), lines)
+	}
+	if lines[0] != `{"body":"first","created_at":"2020-01-01T00:00:00Z"}` {
+		t.Errorf("Record isn't compacted: %s", lines[0])
+	}
+	if lines[2] != "not json" {
+		t.Errorf("Invalid record should be kept for reporting: %s", lines[2])
+	}
+}
+
+func TestRecordsZip(t *testing.T) {
+	buf := bytes.Buffer{}
+	zw := zip.NewWriter(&buf)
+	f, _ := zw.Create("export/chirps.json")
+	f.Write([]byte(`[{"id": "1", "body": "from export", "created_at": "2020-01-01T00:00:00Z"}]`))
+	f, _ = zw.Create("a.jsonl")
+	f.Write([]byte(`{"body": "from lines", "created_at": "2020-01-01T00:00:00Z"}`))
+	f, _ = zw.Create("profile.json")
+	f.Write([]byte(`{"email": "walt@example.com"}`))
+	zw.Close()
+
+	records, err := Records(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Can't read records: %v", err)
+	}
+	lines := strings.Split(string(records), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], "from lines") || !strings.Contains(lines[1], "from export") {
+		t.Errorf("Unexpected records: %q", lines)
+	}
+}
+
+func TestRecordsEmpty(t *testing.T) {
+	_, err := Records([]byte("\n\n"))
+	if !errors.Is(err, ErrNoChirps) {
+		t.Errorf("Expected ErrNoChirps, got %v", err)
+	}
+}
+
+func TestDecode(t *testing.T) {
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		record string
+		err    error
+	}{
+		{`{"body": "hello", "created_at": "2020-01-01T00:00:00+02:00"}`, nil},
+		{`{"created_at": "2020-01-01T00:00:00Z"}`, ErrMissingBody},
+		{`{"body": "hello"}`, ErrMissingTime},
+		{`{"body": "hello", "created_at": "2030-01-01T00:00:00Z"}`, ErrFutureChirp},
+	}
+
+	for _, tt := range tests {
+		item, err := Decode([]byte(tt.record), now)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("Decode(%s): expected %v, got %v", tt.record, tt.err, err)
+		}
+		if err == nil && item.CreatedAt.Location() != time.UTC {
+			t.Errorf("Decode(%s): created_at isn't UTC", tt.record)
+		}
+	}
+
+	_, err := Decode([]byte("not json"), now)
+	if err == nil {
+		t.Errorf("Decode accepted invalid JSON")
+	}
+}
+
+func TestKey(t *testing.T) {
+	at := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+	a := Item{Body: "hello", CreatedAt: at}
+	b := Item{Body: "hello", CreatedAt: at.In(time.FixedZone("X", 3600))}
+	if a.Key() != b.Key() {
+		t.Errorf("Same chirp has different keys")
+	}
+	if a.Key() == (Item{Body: "hello!", CreatedAt: at}).Key() {
+		t.Errorf("Different chirps have the same key")
+	}
+	if (Item{ID: "42", Body: "hello", CreatedAt: at}).Key() != "id:42" {
+		t.Errorf("Key doesn't use the original ID")
+	}
+}
@@ -7,25 +7,59 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
+	"github.com/lib/pq"
 )
 
+const anonymizeUserChirps = `-- name: AnonymizeUserChirps :execrows
+UPDATE chirps
+SET user_id = $1,
+    updated_at = NOW()
+WHERE user_id = $2
+`
+
+type AnonymizeUserChirpsParams struct {
+	AnonymousID uuid.UUID
+	UserID      uuid.UUID
+}
+
+func (q *Queries) AnonymizeUserChirps(ctx context.Context, arg AnonymizeUserChirpsParams) (int64, error) {
+	result, err := q.db.ExecContext(ctx, anonymizeUserChirps, arg.AnonymousID, arg.UserID)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
 const createChirp = `-- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quoted_chirp_id, visibility, content_warning, sensitive)
 VALUES (
-  gen_random_uuid(), NOW(), NOW(), $1, $2
+  gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6
 )
-RETURNING id, created_at, updated_at, body, user_id
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, deleted_at, import_key, quoted_chirp_id, visibility, content_warning, sensitive
 `
 
 type CreateChirpParams struct {
-	Body   string
-	UserID uuid.UUID
+	Body           string
+	UserID         uuid.UUID
+	QuotedChirpID  uuid.NullUUID
+	Visibility     string
+	ContentWarning sql.NullString
+	Sensitive      bool
 }
 
 func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp, error) {
-	row := q.db.QueryRowContext(ctx, createChirp, arg.Body, arg.UserID)
+	row := q.db.QueryRowContext(ctx, createChirp,
+		arg.Body,
+		arg.UserID,
+		arg.QuotedChirpID,
+		arg.Visibility,
+		arg.ContentWarning,
+		arg.Sensitive,
+	)
 	var i Chirp
 	err := row.Scan(
 		&i.ID,
@@ -33,13 +67,27 @@ func (q *Queries) CreateChirp(ctx context.Context, arg CreateChirpParams) (Chirp
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.ImportKey,
+		&i.QuotedChirpID,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
 	)
 	return i, err
 }
 
 const deleteChirp = `-- name: DeleteChirp :exec
-DELETE FROM chirps
+WITH unpinned AS (
+  DELETE FROM pinned_chirps
+  WHERE chirp_id = $1
+)
+UPDATE chirps
+SET deleted_at = NOW(),
+    updated_at = NOW()
 WHERE id = $1
+AND deleted_at IS NULL
 `
 
 func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
@@ -48,8 +96,9 @@ func (q *Queries) DeleteChirp(ctx context.Context, id uuid.UUID) error {
 }
 
 const getChirp = `-- name: GetChirp :one
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
+SELECT id, created_at, updated_at, body, user_id, hidden_at, deleted_at, import_key, quoted_chirp_id, visibility, content_warning, sensitive FROM chirps 
 WHERE id = $1
+AND deleted_at IS NULL
 `
 
 func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
@@ -61,23 +110,34 @@ func (q *Queries) GetChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
 		&i.UpdatedAt,
 		&i.Body,
 		&i.UserID,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.ImportKey,
+		&i.QuotedChirpID,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
 	)
 	return i, err
 }
 
 const getChirps = `-- name: GetChirps :many
-SELECT id, created_at, updated_at, body, user_id FROM chirps 
-WHERE ( user_id = $1 OR NOT $2 )
+SELECT id, created_at, updated_at, body, user_id, hidden_at, deleted_at, import_key, quoted_chirp_id, visibility, content_warning, sensitive FROM chirps 
+WHERE deleted_at IS NULL
+AND ( user_id = $1 OR NOT $2 )
+AND chirp_visible(id, user_id, visibility, hidden_at, $3)
+AND NOT viewer_hides_author($3, user_id)
 ORDER BY created_at
 `
 
 type GetChirpsParams struct {
 	UserID         uuid.UUID
 	FilterByUserID interface{}
+	ViewerID       uuid.UUID
 }
 
 func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp, error) {
-	rows, err := q.db.QueryContext(ctx, getChirps, arg.UserID, arg.FilterByUserID)
+	rows, err := q.db.QueryContext(ctx, getChirps, arg.UserID, arg.FilterByUserID, arg.ViewerID)
 	if err != nil {
 		return nil, err
 	}
@@ -91,6 +151,13 @@ func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp,
 			&i.UpdatedAt,
 			&i.Body,
 			&i.UserID,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.ImportKey,
+			&i.QuotedChirpID,
+			&i.Visibility,
+			&i.ContentWarning,
+			&i.Sensitive,
 		); err != nil {
 			return nil, err
 		}
@@ -104,3 +171,362 @@ func (q *Queries) GetChirps(ctx context.Context, arg GetChirpsParams) ([]Chirp,
 	}
 	return items, nil
 }
+
+const getDeletedChirp = `-- name: GetDeletedChirp :one
+SELECT id, created_at, updated_at, body, user_id, hidden_at, deleted_at, import_key, quoted_chirp_id, visibility, content_warning, sensitive FROM chirps
+WHERE id = $1
+AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) GetDeletedChirp(ctx context.Context, id uuid.UUID) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedChirp, id)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.ImportKey,
+		&i.QuotedChirpID,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
+
+const getQuoteCounts = `-- name: GetQuoteCounts :many
+SELECT quoted_chirp_id::uuid AS chirp_id, COUNT(*) AS quotes
+FROM chirps
+WHERE quoted_chirp_id = ANY($1::uuid[])
+AND deleted_at IS NULL
+AND chirp_visible(id, user_id, visibility, hidden_at, $2)
+AND NOT viewer_hides_author($2, user_id)
+GROUP BY quoted_chirp_id
+`
+
+type GetQuoteCountsParams struct {
+	ChirpIds []uuid.UUID
+	ViewerID uuid.UUID
+}
+
+type GetQuoteCountsRow struct {
+	ChirpID uuid.UUID
+	Quotes  int64
+}
+
+func (q *Queries) GetQuoteCounts(ctx context.Context, arg GetQuoteCountsParams) ([]GetQuoteCountsRow, error) {
+	rows, err := q.db.QueryContext(ctx, getQuoteCounts, pq.Array(arg.ChirpIds), arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []GetQuoteCountsRow
+	for rows.Next() {
+		var i GetQuoteCountsRow
+		if err := rows.Scan(&i.ChirpID, &i.Quotes); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getQuotes = `-- name: GetQuotes :many
+SELECT id, created_at, updated_at, body, user_id, hidden_at, deleted_at, import_key, quoted_chirp_id, visibility, content_warning, sensitive FROM chirps
+WHERE quoted_chirp_id = $1
+AND deleted_at IS NULL
+AND chirp_visible(id, user_id, visibility, hidden_at, $2)
+AND NOT viewer_hides_author($2, user_id)
+ORDER BY created_at
+`
+
+type GetQuotesParams struct {
+	QuotedChirpID uuid.NullUUID
+	ViewerID      uuid.UUID
+}
+
+func (q *Queries) GetQuotes(ctx context.Context, arg GetQuotesParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getQuotes, arg.QuotedChirpID, arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.ImportKey,
+			&i.QuotedChirpID,
+			&i.Visibility,
+			&i.ContentWarning,
+			&i.Sensitive,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getUserChirps = `-- name: GetUserChirps :many
+SELECT id, created_at, updated_at, body, user_id, hidden_at, deleted_at, import_key, quoted_chirp_id, visibility, content_warning, sensitive FROM chirps
+WHERE user_id = $1
+ORDER BY created_at
+`
+
+func (q *Queries) GetUserChirps(ctx context.Context, userID uuid.UUID) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getUserChirps, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.ImportKey,
+			&i.QuotedChirpID,
+			&i.Visibility,
+			&i.ContentWarning,
+			&i.Sensitive,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const getVisibleChirp = `-- name: GetVisibleChirp :one
+SELECT id, created_at, updated_at, body, user_id, hidden_at, deleted_at, import_key, quoted_chirp_id, visibility, content_warning, sensitive FROM chirps
+WHERE id = $1
+AND deleted_at IS NULL
+AND chirp_visible(id, user_id, visibility, hidden_at, $2)
+`
+
+type GetVisibleChirpParams struct {
+	ID       uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetVisibleChirp(ctx context.Context, arg GetVisibleChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, getVisibleChirp, arg.ID, arg.ViewerID)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.ImportKey,
+		&i.QuotedChirpID,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
+
+const getVisibleChirpsByIDs = `-- name: GetVisibleChirpsByIDs :many
+SELECT id, created_at, updated_at, body, user_id, hidden_at, deleted_at, import_key, quoted_chirp_id, visibility, content_warning, sensitive FROM chirps
+WHERE id = ANY($1::uuid[])
+AND deleted_at IS NULL
+AND chirp_visible(id, user_id, visibility, hidden_at, $2)
+`
+
+type GetVisibleChirpsByIDsParams struct {
+	Ids      []uuid.UUID
+	ViewerID uuid.UUID
+}
+
+func (q *Queries) GetVisibleChirpsByIDs(ctx context.Context, arg GetVisibleChirpsByIDsParams) ([]Chirp, error) {
+	rows, err := q.db.QueryContext(ctx, getVisibleChirpsByIDs, pq.Array(arg.Ids), arg.ViewerID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var items []Chirp
+	for rows.Next() {
+		var i Chirp
+		if err := rows.Scan(
+			&i.ID,
+			&i.CreatedAt,
+			&i.UpdatedAt,
+			&i.Body,
+			&i.UserID,
+			&i.HiddenAt,
+			&i.DeletedAt,
+			&i.ImportKey,
+			&i.QuotedChirpID,
+			&i.Visibility,
+			&i.ContentWarning,
+			&i.Sensitive,
+		); err != nil {
+			return nil, err
+		}
+		items = append(items, i)
+	}
+	if err := rows.Close(); err != nil {
+		return nil, err
+	}
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+	return items, nil
+}
+
+const hideChirp = `-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) HideChirp(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, hideChirp, id)
+	return err
+}
+
+const importChirp = `-- name: ImportChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, import_key)
+VALUES (
+  gen_random_uuid(), $1, NOW(), $2, $3, $4
+)
+ON CONFLICT (user_id, import_key) WHERE import_key IS NOT NULL DO NOTHING
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, deleted_at, import_key, quoted_chirp_id, visibility, content_warning, sensitive
+`
+
+type ImportChirpParams struct {
+	CreatedAt time.Time
+	Body      string
+	UserID    uuid.UUID
+	ImportKey sql.NullString
+}
+
+func (q *Queries) ImportChirp(ctx context.Context, arg ImportChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, importChirp,
+		arg.CreatedAt,
+		arg.Body,
+		arg.UserID,
+		arg.ImportKey,
+	)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.ImportKey,
+		&i.QuotedChirpID,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
+
+const markChirpSensitive = `-- name: MarkChirpSensitive :exec
+UPDATE chirps
+SET sensitive = true,
+    content_warning = COALESCE($1, content_warning),
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type MarkChirpSensitiveParams struct {
+	ContentWarning sql.NullString
+	ID             uuid.UUID
+}
+
+func (q *Queries) MarkChirpSensitive(ctx context.Context, arg MarkChirpSensitiveParams) error {
+	_, err := q.db.ExecContext(ctx, markChirpSensitive, arg.ContentWarning, arg.ID)
+	return err
+}
+
+const purgeDeletedChirps = `-- name: PurgeDeletedChirps :execrows
+DELETE FROM chirps
+WHERE deleted_at < $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedChirps(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedChirps, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreChirp = `-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+AND deleted_at > $2::timestamp
+RETURNING id, created_at, updated_at, body, user_id, hidden_at, deleted_at, import_key, quoted_chirp_id, visibility, content_warning, sensitive
+`
+
+type RestoreChirpParams struct {
+	ID           uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) RestoreChirp(ctx context.Context, arg RestoreChirpParams) (Chirp, error) {
+	row := q.db.QueryRowContext(ctx, restoreChirp, arg.ID, arg.DeletedAfter)
+	var i Chirp
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Body,
+		&i.UserID,
+		&i.HiddenAt,
+		&i.DeletedAt,
+		&i.ImportKey,
+		&i.QuotedChirpID,
+		&i.Visibility,
+		&i.ContentWarning,
+		&i.Sensitive,
+	)
+	return i, err
+}
@@ -6,17 +6,264 @@ package database
 
 import (
 	"database/sql"
+	"encoding/json"
 	"time"
 
 	"github.com/google/uuid"
 )
 
+type Attachment struct {
+	ID           uuid.UUID
+	CreatedAt    time.Time
+	UserID       uuid.UUID
+	ChirpID      uuid.NullUUID
+	Position     int32
+	ContentType  string
+	SizeBytes    int64
+	Width        int32
+	Height       int32
+	AltText      string
+	StorageKey   string
+	ThumbnailKey string
+}
+
+type AuditLog struct {
+	ID         int64
+	CreatedAt  time.Time
+	ActorID    uuid.NullUUID
+	Action     string
+	TargetType string
+	TargetID   string
+	Ip         string
+	RequestID  string
+	Metadata   json.RawMessage
+	PrevHash   string
+	Hash       string
+}
+
+type Bookmark struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+}
+
 type Chirp struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	UpdatedAt      time.Time
+	Body           string
+	UserID         uuid.UUID
+	HiddenAt       sql.NullTime
+	DeletedAt      sql.NullTime
+	ImportKey      sql.NullString
+	QuotedChirpID  uuid.NullUUID
+	Visibility     string
+	ContentWarning sql.NullString
+	Sensitive      bool
+}
+
+type ChirpEvent struct {
+	ID        int64
+	CreatedAt time.Time
+	EventType string
+	ChirpID   uuid.UUID
+	UserID    uuid.UUID
+	Payload   json.RawMessage
+}
+
+type ChirpImport struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	UserID     uuid.UUID
+	Status     string
+	Total      int32
+	Processed  int32
+	Imported   int32
+	Skipped    int32
+	Failed     int32
+	LeaseUntil sql.NullTime
+}
+
+type ChirpImportError struct {
+	ImportID uuid.UUID
+	Item     int32
+	Error    string
+}
+
+type ChirpImportRecord struct {
+	ImportID uuid.UUID
+	Records  []byte
+}
+
+type ChirpLink struct {
+	ChirpID     uuid.UUID
+	Position    int32
+	Url         string
+	StartOffset int32
+	EndOffset   int32
+}
+
+type ChirpMention struct {
+	ChirpID uuid.UUID
+	UserID  uuid.UUID
+}
+
+type Conversation struct {
 	ID        uuid.UUID
 	CreatedAt time.Time
 	UpdatedAt time.Time
-	Body      string
+	CreatedBy uuid.UUID
+	IsGroup   bool
+}
+
+type ConversationMember struct {
+	ConversationID uuid.UUID
+	UserID         uuid.UUID
+	JoinedAt       time.Time
+	LastReadAt     sql.NullTime
+	ClearedAt      sql.NullTime
+	Hidden         bool
+}
+
+type DataExport struct {
+	ID          uuid.UUID
+	CreatedAt   time.Time
+	UserID      uuid.UUID
+	Status      string
+	CompletedAt sql.NullTime
+	ExpiresAt   sql.NullTime
+	SizeBytes   int64
+	LeaseUntil  sql.NullTime
+}
+
+type DataExportArchive struct {
+	ExportID uuid.UUID
+	Archive  []byte
+}
+
+type Draft struct {
+	ID              uuid.UUID
+	CreatedAt       time.Time
+	UpdatedAt       time.Time
+	UserID          uuid.UUID
+	Body            string
+	MediaIds        []uuid.UUID
+	PublishAt       sql.NullTime
+	PublishError    sql.NullString
+	Visibility      string
+	ContentWarning  sql.NullString
+	Sensitive       bool
+	PublishAttempts int32
+}
+
+type Follow struct {
+	FollowerID uuid.UUID
+	FolloweeID uuid.UUID
+	CreatedAt  time.Time
+	ApprovedAt sql.NullTime
+}
+
+type HeldChirp struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	Score     float64
+	Reasons   []string
+}
+
+type LinkPreview struct {
+	Url         string
+	CreatedAt   time.Time
+	RequestedAt time.Time
+	ClaimedAt   sql.NullTime
+	FetchedAt   sql.NullTime
+	Title       string
+	Description string
+	ImageUrl    string
+	SiteName    string
+	Error       sql.NullString
+}
+
+type List struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UpdatedAt time.Time
+	OwnerID   uuid.UUID
+	Name      string
+	IsPublic  bool
+}
+
+type ListMember struct {
+	ListID    uuid.UUID
+	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type Message struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ConversationID uuid.UUID
+	SenderID       uuid.UUID
+	Body           string
+}
+
+type ModerationDecision struct {
+	ID             uuid.UUID
+	CreatedAt      time.Time
+	ReportID       uuid.UUID
+	ModeratorID    uuid.UUID
+	Action         string
+	Note           string
+	UserID         uuid.UUID
+	ChirpID        uuid.NullUUID
+	ChirpBody      sql.NullString
+	Reason         string
+	SuspendedUntil sql.NullTime
+}
+
+type Notification struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	UserID    uuid.UUID
+	ActorID   uuid.NullUUID
+	Type      string
+	ChirpID   uuid.NullUUID
+	ReadAt    sql.NullTime
+}
+
+type PinnedChirp struct {
+	UserID    uuid.UUID
+	ChirpID   uuid.UUID
+	Position  int32
+	CreatedAt time.Time
+}
+
+type Poll struct {
+	ID        uuid.UUID
+	CreatedAt time.Time
+	ChirpID   uuid.UUID
+	ClosesAt  time.Time
+	ClosedAt  sql.NullTime
+}
+
+type PollOption struct {
+	ID       uuid.UUID
+	PollID   uuid.UUID
+	Position int32
+	Text     string
+}
+
+type PollVote struct {
+	PollID    uuid.UUID
+	OptionID  uuid.UUID
 	UserID    uuid.UUID
+	CreatedAt time.Time
+}
+
+type RateLimitBucket struct {
+	Key       string
+	Tokens    float64
+	UpdatedAt time.Time
 }
 
 type RefreshToken struct {
@@ -28,11 +275,56 @@ type RefreshToken struct {
 	RevokedAt sql.NullTime
 }
 
+type Report struct {
+	ID         uuid.UUID
+	CreatedAt  time.Time
+	UpdatedAt  time.Time
+	ReporterID uuid.UUID
+	UserID     uuid.UUID
+	ChirpID    uuid.NullUUID
+	Reason     string
+	Details    string
+	Status     string
+	ClaimedBy  uuid.NullUUID
+	ClaimedAt  sql.NullTime
+	ResolvedAt sql.NullTime
+}
+
+type SpamExample struct {
+	ChirpID   uuid.UUID
+	CreatedAt time.Time
+	Body      string
+	Spam      bool
+}
+
 type User struct {
-	ID             uuid.UUID
-	CreatedAt      time.Time
-	UpdatedAt      time.Time
-	Email          string
-	HashedPassword string
-	IsChirpyRed    bool
+	ID                      uuid.UUID
+	CreatedAt               time.Time
+	UpdatedAt               time.Time
+	Email                   string
+	HashedPassword          string
+	IsChirpyRed             bool
+	NotificationPreferences json.RawMessage
+	DmPolicy                string
+	Role                    string
+	SuspendedUntil          sql.NullTime
+	SuspensionReason        sql.NullString
+	BannedAt                sql.NullTime
+	BanReason               sql.NullString
+	ShadowbannedAt          sql.NullTime
+	DeletedAt               sql.NullTime
+	Protected               bool
+	SensitiveChirps         string
+}
+
+type UserBlock struct {
+	BlockerID uuid.UUID
+	BlockedID uuid.UUID
+	CreatedAt time.Time
+}
+
+type UserMute struct {
+	MuterID   uuid.UUID
+	MutedID   uuid.UUID
+	CreatedAt time.Time
 }
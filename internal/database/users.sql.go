@@ -7,16 +7,54 @@ package database
 
 import (
 	"context"
+	"database/sql"
+	"time"
 
 	"github.com/google/uuid"
 )
 
+const banUser = `-- name: BanUser :exec
+UPDATE users
+SET banned_at = NOW(),
+    ban_reason = $1,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type BanUserParams struct {
+	BanReason sql.NullString
+	ID        uuid.UUID
+}
+
+func (q *Queries) BanUser(ctx context.Context, arg BanUserParams) error {
+	_, err := q.db.ExecContext(ctx, banUser, arg.BanReason, arg.ID)
+	return err
+}
+
+const createAnonymousUser = `-- name: CreateAnonymousUser :exec
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (
+  $1, NOW(), NOW(), $2, ''
+)
+ON CONFLICT (id) DO NOTHING
+`
+
+type CreateAnonymousUserParams struct {
+	ID    uuid.UUID
+	Email string
+}
+
+func (q *Queries) CreateAnonymousUser(ctx context.Context, arg CreateAnonymousUserParams) error {
+	_, err := q.db.ExecContext(ctx, createAnonymousUser, arg.ID, arg.Email)
+	return err
+}
+
 const createUser = `-- name: CreateUser :one
 INSERT INTO users (id, created_at, updated_at, email, hashed_password)
 VALUES (
   gen_random_uuid(), NOW(), NOW(), $1, $2
 )
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, notification_preferences, dm_policy, role, suspended_until, suspension_reason, banned_at, ban_reason, shadowbanned_at, deleted_at, protected, sensitive_chirps
 `
 
 type CreateUserParams struct {
@@ -34,6 +72,17 @@ func (q *Queries) CreateUser(ctx context.Context, arg CreateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.NotificationPreferences,
+		&i.DmPolicy,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.BanReason,
+		&i.ShadowbannedAt,
+		&i.DeletedAt,
+		&i.Protected,
+		&i.SensitiveChirps,
 	)
 	return i, err
 }
@@ -47,8 +96,51 @@ func (q *Queries) DeleteAllUsers(ctx context.Context) error {
 	return err
 }
 
+const downgradeUser = `-- name: DowngradeUser :exec
+UPDATE users
+SET is_chirpy_red = false,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) DowngradeUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, downgradeUser, id)
+	return err
+}
+
+const getDeletedUser = `-- name: GetDeletedUser :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, notification_preferences, dm_policy, role, suspended_until, suspension_reason, banned_at, ban_reason, shadowbanned_at, deleted_at, protected, sensitive_chirps FROM users
+WHERE email = $1
+AND deleted_at IS NOT NULL
+`
+
+func (q *Queries) GetDeletedUser(ctx context.Context, email string) (User, error) {
+	row := q.db.QueryRowContext(ctx, getDeletedUser, email)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.NotificationPreferences,
+		&i.DmPolicy,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.BanReason,
+		&i.ShadowbannedAt,
+		&i.DeletedAt,
+		&i.Protected,
+		&i.SensitiveChirps,
+	)
+	return i, err
+}
+
 const getUser = `-- name: GetUser :one
-SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red FROM users WHERE email = $1
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, notification_preferences, dm_policy, role, suspended_until, suspension_reason, banned_at, ban_reason, shadowbanned_at, deleted_at, protected, sensitive_chirps FROM users WHERE email = $1 AND deleted_at IS NULL
 `
 
 func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
@@ -61,16 +153,229 @@ func (q *Queries) GetUser(ctx context.Context, email string) (User, error) {
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.NotificationPreferences,
+		&i.DmPolicy,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.BanReason,
+		&i.ShadowbannedAt,
+		&i.DeletedAt,
+		&i.Protected,
+		&i.SensitiveChirps,
+	)
+	return i, err
+}
+
+const getUserByID = `-- name: GetUserByID :one
+SELECT id, created_at, updated_at, email, hashed_password, is_chirpy_red, notification_preferences, dm_policy, role, suspended_until, suspension_reason, banned_at, ban_reason, shadowbanned_at, deleted_at, protected, sensitive_chirps FROM users WHERE id = $1 AND deleted_at IS NULL
+`
+
+func (q *Queries) GetUserByID(ctx context.Context, id uuid.UUID) (User, error) {
+	row := q.db.QueryRowContext(ctx, getUserByID, id)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.NotificationPreferences,
+		&i.DmPolicy,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.BanReason,
+		&i.ShadowbannedAt,
+		&i.DeletedAt,
+		&i.Protected,
+		&i.SensitiveChirps,
+	)
+	return i, err
+}
+
+const purgeDeletedUsers = `-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < $1::timestamp
+`
+
+func (q *Queries) PurgeDeletedUsers(ctx context.Context, deletedBefore time.Time) (int64, error) {
+	result, err := q.db.ExecContext(ctx, purgeDeletedUsers, deletedBefore)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
+
+const restoreUser = `-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+AND deleted_at > $2::timestamp
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, notification_preferences, dm_policy, role, suspended_until, suspension_reason, banned_at, ban_reason, shadowbanned_at, deleted_at, protected, sensitive_chirps
+`
+
+type RestoreUserParams struct {
+	ID           uuid.UUID
+	DeletedAfter time.Time
+}
+
+func (q *Queries) RestoreUser(ctx context.Context, arg RestoreUserParams) (User, error) {
+	row := q.db.QueryRowContext(ctx, restoreUser, arg.ID, arg.DeletedAfter)
+	var i User
+	err := row.Scan(
+		&i.ID,
+		&i.CreatedAt,
+		&i.UpdatedAt,
+		&i.Email,
+		&i.HashedPassword,
+		&i.IsChirpyRed,
+		&i.NotificationPreferences,
+		&i.DmPolicy,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.BanReason,
+		&i.ShadowbannedAt,
+		&i.DeletedAt,
+		&i.Protected,
+		&i.SensitiveChirps,
 	)
 	return i, err
 }
 
+const shadowbanUser = `-- name: ShadowbanUser :exec
+UPDATE users
+SET shadowbanned_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) ShadowbanUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, shadowbanUser, id)
+	return err
+}
+
+const softDeleteUser = `-- name: SoftDeleteUser :exec
+UPDATE users
+SET deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+AND deleted_at IS NULL
+`
+
+func (q *Queries) SoftDeleteUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, softDeleteUser, id)
+	return err
+}
+
+const suspendUser = `-- name: SuspendUser :exec
+UPDATE users
+SET suspended_until = $1,
+    suspension_reason = $2,
+    updated_at = NOW()
+WHERE id = $3
+`
+
+type SuspendUserParams struct {
+	SuspendedUntil   sql.NullTime
+	SuspensionReason sql.NullString
+	ID               uuid.UUID
+}
+
+func (q *Queries) SuspendUser(ctx context.Context, arg SuspendUserParams) error {
+	_, err := q.db.ExecContext(ctx, suspendUser, arg.SuspendedUntil, arg.SuspensionReason, arg.ID)
+	return err
+}
+
+const unbanUser = `-- name: UnbanUser :exec
+UPDATE users
+SET banned_at = NULL,
+    ban_reason = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) UnbanUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unbanUser, id)
+	return err
+}
+
+const unshadowbanUser = `-- name: UnshadowbanUser :exec
+UPDATE users
+SET shadowbanned_at = NULL,
+    updated_at = NOW()
+WHERE id = $1
+`
+
+func (q *Queries) UnshadowbanUser(ctx context.Context, id uuid.UUID) error {
+	_, err := q.db.ExecContext(ctx, unshadowbanUser, id)
+	return err
+}
+
+const updateDMPolicy = `-- name: UpdateDMPolicy :exec
+UPDATE users
+SET dm_policy = $1,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type UpdateDMPolicyParams struct {
+	DmPolicy string
+	ID       uuid.UUID
+}
+
+func (q *Queries) UpdateDMPolicy(ctx context.Context, arg UpdateDMPolicyParams) error {
+	_, err := q.db.ExecContext(ctx, updateDMPolicy, arg.DmPolicy, arg.ID)
+	return err
+}
+
+const updateProtected = `-- name: UpdateProtected :exec
+UPDATE users
+SET protected = $1,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type UpdateProtectedParams struct {
+	Protected bool
+	ID        uuid.UUID
+}
+
+func (q *Queries) UpdateProtected(ctx context.Context, arg UpdateProtectedParams) error {
+	_, err := q.db.ExecContext(ctx, updateProtected, arg.Protected, arg.ID)
+	return err
+}
+
+const updateSensitiveChirps = `-- name: UpdateSensitiveChirps :exec
+UPDATE users
+SET sensitive_chirps = $1,
+    updated_at = NOW()
+WHERE id = $2
+`
+
+type UpdateSensitiveChirpsParams struct {
+	SensitiveChirps string
+	ID              uuid.UUID
+}
+
+func (q *Queries) UpdateSensitiveChirps(ctx context.Context, arg UpdateSensitiveChirpsParams) error {
+	_, err := q.db.ExecContext(ctx, updateSensitiveChirps, arg.SensitiveChirps, arg.ID)
+	return err
+}
+
 const updateUser = `-- name: UpdateUser :one
 UPDATE users
 SET hashed_password = $1,
     email = $2
 WHERE id = $3
-RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red
+AND deleted_at IS NULL
+RETURNING id, created_at, updated_at, email, hashed_password, is_chirpy_red, notification_preferences, dm_policy, role, suspended_until, suspension_reason, banned_at, ban_reason, shadowbanned_at, deleted_at, protected, sensitive_chirps
 `
 
 type UpdateUserParams struct {
@@ -89,6 +394,17 @@ func (q *Queries) UpdateUser(ctx context.Context, arg UpdateUserParams) (User, e
 		&i.Email,
 		&i.HashedPassword,
 		&i.IsChirpyRed,
+		&i.NotificationPreferences,
+		&i.DmPolicy,
+		&i.Role,
+		&i.SuspendedUntil,
+		&i.SuspensionReason,
+		&i.BannedAt,
+		&i.BanReason,
+		&i.ShadowbannedAt,
+		&i.DeletedAt,
+		&i.Protected,
+		&i.SensitiveChirps,
 	)
 	return i, err
 }
@@ -97,6 +413,7 @@ const upgradeUser = `-- name: UpgradeUser :exec
 UPDATE users
 SET is_chirpy_red = true
 WHERE id = $1
+AND deleted_at IS NULL
 `
 
 func (q *Queries) UpgradeUser(ctx context.Context, id uuid.UUID) error {
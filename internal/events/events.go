@@ -0,0 +1,136 @@
+package events
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	ChirpCreated = "chirp.created"
+	ChirpDeleted = "chirp.deleted"
+
+	NotificationCreated = "notification.created"
+	MessageCreated      = "message.created"
+	ConversationRead    = "conversation.read"
+
+	// Ephemeral events are never stored and have no ID.
+	Typing   = "typing"
+	Presence = "presence"
+)
+
+const (
+	// Channel is the Postgres NOTIFY channel the chirp_events trigger publishes on.
+	Channel = "chirp_events"
+	// RealtimeChannel carries ephemeral events that are not persisted.
+	RealtimeChannel = "realtime_events"
+)
+
+// subscriberBuffer is how many events a subscriber may fall behind before it
+// is dropped. Dropped subscribers are expected to reconnect and resume from
+// their last event ID.
+const subscriberBuffer = 64
+
+type Event struct {
+	ID        int64           `json:"id"`
+	EventType string          `json:"event_type"`
+	ChirpID   uuid.UUID       `json:"chirp_id"`
+	UserID    uuid.UUID       `json:"user_id"`
+	Topic     string          `json:"topic,omitempty"`
+	Payload   json.RawMessage `json:"payload"`
+}
+
+// Broker fans events out to every subscriber on this instance.
+type Broker struct {
+	mu   sync.Mutex
+	subs map[chan Event]struct{}
+}
+
+func NewBroker() *Broker {
+	return &Broker{
+		subs: make(map[chan Event]struct{}),
+	}
+}
+
+// Subscribe returns a channel of events and a function that releases it. The
+// channel is closed if the subscriber falls too far behind.
+func (b *Broker) Subscribe() (<-chan Event, func()) {
+	ch := make(chan Event, subscriberBuffer)
+	b.mu.Lock()
+	b.subs[ch] = struct{}{}
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		if _, ok := b.subs[ch]; ok {
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+func (b *Broker) Publish(e Event) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		select {
+		case ch <- e:
+		default:
+			delete(b.subs, ch)
+			close(ch)
+		}
+	}
+}
+
+// dropAll closes every subscriber so clients reconnect and resume from the
+// database, used when notifications may have been missed.
+func (b *Broker) dropAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs {
+		delete(b.subs, ch)
+		close(ch)
+	}
+}
+
+// Listen receives events published by any server instance through Postgres
+// LISTEN/NOTIFY and forwards them to local subscribers. It blocks forever.
+func (b *Broker) Listen(dbURL string) error {
+	l := pq.NewListener(dbURL, 10*time.Second, time.Minute, func(ev pq.ListenerEventType, err error) {
+		if err != nil {
+			log.Println(err)
+		}
+	})
+	for _, channel := range []string{Channel, RealtimeChannel} {
+		err := l.Listen(channel)
+		if err != nil {
+			return err
+		}
+	}
+
+	for {
+		select {
+		case n := <-l.Notify:
+			if n == nil {
+				// The connection was re-established and notifications may
+				// have been lost in between.
+				b.dropAll()
+				continue
+			}
+			e := Event{}
+			err := json.Unmarshal([]byte(n.Extra), &e)
+			if err != nil {
+				log.Printf("Can't decode chirp event: %v", err)
+				continue
+			}
+			b.Publish(e)
+		case <-time.After(90 * time.Second):
+			go l.Ping()
+		}
+	}
+}
@@ -0,0 +1,46 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestPublish(t *testing.T) {
+	b := NewBroker()
+	ch, cancel := b.Subscribe()
+	defer cancel()
+
+	e := Event{ID: 1, EventType: ChirpCreated, ChirpID: uuid.New(), UserID: uuid.New()}
+	b.Publish(e)
+
+	got := <-ch
+	if got.ID != e.ID || got.ChirpID != e.ChirpID {
+		t.Errorf("Received wrong event: %v", got)
+	}
+}
+
+func TestSlowSubscriberDropped(t *testing.T) {
+	b := NewBroker()
+	ch, cancel := b.Subscribe()
+	defer cancel()
+
+	for i := 0; i <= subscriberBuffer; i++ {
+		b.Publish(Event{ID: int64(i)})
+	}
+
+	n := 0
+	for range ch {
+		n++
+	}
+	if n != subscriberBuffer {
+		t.Errorf("Expected %d buffered events, got %d", subscriberBuffer, n)
+	}
+}
+
+func TestCancelTwice(t *testing.T) {
+	b := NewBroker()
+	_, cancel := b.Subscribe()
+	cancel()
+	cancel()
+}
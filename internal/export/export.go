@@ -0,0 +1,105 @@
+// Package export writes personal data archives: a ZIP with one JSON file per
+// section and an index.html that describes them for people who don't read
+// JSON.
+package export
+
+import (
+	"archive/zip"
+	"encoding/json"
+	"html/template"
+	"io"
+	"reflect"
+	"time"
+)
+
+type Section struct {
+	// Name is used as the file name, so it should be a plain lowercase word.
+	Name        string
+	Description string
+	Data        any
+}
+
+type indexSection struct {
+	Section
+	File  string
+	Count int
+}
+
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Your Chirpy data</title>
+</head>
+<body>
+<h1>Your Chirpy data</h1>
+<p>Exported for {{.Email}} on {{.GeneratedAt.Format "2 January 2006 at 15:04 MST"}}.</p>
+<table>
+<tr><th>File</th><th>Contents</th><th>Items</th></tr>
+{{range .Sections}}<tr><td><a href="{{.File}}">{{.File}}</a></td><td>{{.Description}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+// Write writes an archive containing sections to w.
+func Write(w io.Writer, email string, generatedAt time.Time, sections []Section) error {
+	zw := zip.NewWriter(w)
+
+	index := make([]indexSection, len(sections))
+	for i, s := range sections {
+		index[i] = indexSection{
+			Section: s,
+			File:    s.Name + ".json",
+			Count:   count(s.Data),
+		}
+
+		f, err := zw.CreateHeader(&zip.FileHeader{
+			Name:     index[i].File,
+			Method:   zip.Deflate,
+			Modified: generatedAt,
+		})
+		if err != nil {
+			return err
+		}
+		enc := json.NewEncoder(f)
+		enc.SetIndent("", "  ")
+		err = enc.Encode(s.Data)
+		if err != nil {
+			return err
+		}
+	}
+
+	f, err := zw.CreateHeader(&zip.FileHeader{
+		Name:     "index.html",
+		Method:   zip.Deflate,
+		Modified: generatedAt,
+	})
+	if err != nil {
+		return err
+	}
+	err = indexTemplate.Execute(f, struct {
+		Email       string
+		GeneratedAt time.Time
+		Sections    []indexSection
+	}{
+		Email:       email,
+		GeneratedAt: generatedAt,
+		Sections:    index,
+	})
+	if err != nil {
+		return err
+	}
+
+	return zw.Close()
+}
+
+// count returns how many items a section holds: the length of a list, or one
+// for anything else.
+func count(data any) int {
+	v := reflect.ValueOf(data)
+	if v.Kind() == reflect.Slice || v.Kind() == reflect.Array {
+		return v.Len()
+	}
+	return 1
+}
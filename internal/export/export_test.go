@@ -0,0 +1,59 @@
+package export
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWrite(t *testing.T) {
+	type chirp struct {
+		Body string `json:"body"`
+	}
+
+	buf := bytes.Buffer{}
+	err := Write(&buf, "<walt>@example.com", time.Now(), []Section{
+		{Name: "profile", Description: "Your account", Data: map[string]string{"email": "walt@example.com"}},
+		{Name: "chirps", Description: "Chirps you posted", Data: []chirp{{Body: "one"}, {Body: "two"}}},
+	})
+	if err != nil {
+		t.Fatalf("Can't write archive: %v", err)
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		t.Fatalf("Can't read archive: %v", err)
+	}
+
+	files := map[string]string{}
+	for _, f := range zr.File {
+		rc, err := f.Open()
+		if err != nil {
+			t.Fatalf("Can't open %s: %v", f.Name, err)
+		}
+		data, _ := io.ReadAll(rc)
+		rc.Close()
+		files[f.Name] = string(data)
+	}
+
+	chirps := []chirp{}
+	err = json.Unmarshal([]byte(files["chirps.json"]), &chirps)
+	if err != nil || len(chirps) != 2 || chirps[1].Body != "two" {
+		t.Errorf("chirps.json doesn't hold the chirps: %q", files["chirps.json"])
+	}
+	if _, ok := files["profile.json"]; !ok {
+		t.Errorf("Archive is missing profile.json")
+	}
+
+	index := files["index.html"]
+	if !strings.Contains(index, `<a href="chirps.json">`) || !strings.Contains(index, "<td>2</td>") {
+		t.Errorf("Index doesn't list chirps.json with its count: %s", index)
+	}
+	if strings.Contains(index, "<walt>") {
+		t.Errorf("Index doesn't escape the email: %s", index)
+	}
+}
@@ -0,0 +1,77 @@
+// Package links finds the URLs in chirps and fetches Open Graph previews of
+// the pages they point to.
+package links
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/aobatake/goserver/internal/validate"
+)
+
+// ShortURLLength is how many characters a URL counts for in a chirp,
+// however long it really is.
+const ShortURLLength = 23
+
+// MaxURLLength is the longest URL, in bytes, that Find recognises. Longer
+// ones are left as text, so they count in full towards a chirp's length.
+const MaxURLLength = 2048
+
+var urlPattern = regexp.MustCompile(`(?i)\bhttps?://[^\s<>"]+`)
+
+// Entity is a URL found in a chirp body. Start and End are byte offsets.
+type Entity struct {
+	URL   string
+	Start int
+	End   int
+}
+
+// Find returns the http and https URLs in body, in order. Trailing
+// punctuation and unbalanced closing parentheses are left out, so "see
+// (https://example.com)." finds https://example.com. URLs longer than
+// MaxURLLength aren't found.
+func Find(body string) []Entity {
+	var entities []Entity
+	for _, m := range urlPattern.FindAllStringIndex(body, -1) {
+		raw := trimURL(body[m[0]:m[1]])
+		if len(raw) > MaxURLLength {
+			continue
+		}
+		u, err := url.Parse(raw)
+		if err != nil || u.Host == "" {
+			continue
+		}
+		entities = append(entities, Entity{
+			URL:   raw,
+			Start: m[0],
+			End:   m[0] + len(raw),
+		})
+	}
+	return entities
+}
+
+func trimURL(raw string) string {
+	for raw != "" {
+		last := raw[len(raw)-1]
+		switch {
+		case strings.IndexByte(".,:;!?'", last) >= 0:
+			raw = raw[:len(raw)-1]
+		case last == ')' && strings.Count(raw, "(") < strings.Count(raw, ")"):
+			raw = raw[:len(raw)-1]
+		default:
+			return raw
+		}
+	}
+	return raw
+}
+
+// Length is the length of body in user-perceived characters, with every
+// URL counted as ShortURLLength.
+func Length(body string) int {
+	n := validate.Graphemes(body)
+	for _, e := range Find(body) {
+		n += ShortURLLength - validate.Graphemes(e.URL)
+	}
+	return n
+}
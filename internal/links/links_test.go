@@ -0,0 +1,202 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+)
+
+func TestFind(t *testing.T) {
+	body := "See (https://example.com/a_(b)) and http://go.dev/doc?x=1, not ftp://x.org or https://."
+	entities := Find(body)
+
+	want := []string{"https://example.com/a_(b)", "http://go.dev/doc?x=1"}
+	if len(entities) != len(want) {
+		t.Fatalf("Found %d URLs, want %d: %+v", len(entities), len(want), entities)
+	}
+	for i, e := range entities {
+		if e.URL != want[i] {
+			t.Errorf("URL %d is %q, want %q", i, e.URL, want[i])
+		}
+		if body[e.Start:e.End] != e.URL {
+			t.Errorf("Offsets of %q point at %q", e.URL, body[e.Start:e.End])
+		}
+	}
+}
+
+func TestLength(t *testing.T) {
+	long := "https://example.com/" + strings.Repeat("a", 200)
+	body := "Read this " + long
+	if got, want := Length(body), len("Read this ")+ShortURLLength; got != want {
+		t.Errorf("Length is %d, want %d", got, want)
+	}
+	if got := Length("no links here"); got != len("no links here") {
+		t.Errorf("Length without links is %d", got)
+	}
+	if got := Length("cafe\u0301 \U0001F1EF\U0001F1F5 " + long); got != 7+ShortURLLength {
+		t.Errorf("Length with combining marks and a flag is %d, want %d", got, 7+ShortURLLength)
+	}
+
+	tooLong := "https://example.com/" + strings.Repeat("a", MaxURLLength)
+	if entities := Find(tooLong); len(entities) != 0 {
+		t.Errorf("Found a URL longer than MaxURLLength: %d bytes", len(entities[0].URL))
+	}
+	if got := Length(tooLong); got != len(tooLong) {
+		t.Errorf("Length with a URL longer than MaxURLLength is %d, want %d", got, len(tooLong))
+	}
+}
+
+func TestParse(t *testing.T) {
+	page := `<!DOCTYPE html><html><head>
+<title>Fallback &amp; title</title>
+<meta property="og:title" content="Open &quot;Graph&quot; title">
+<meta name=description content='A description'>
+<meta property="og:image" content="/img/card.png" />
+<meta property="og:site_name" content="Example">
+</head><body><meta property="og:title" content="Ignored"></body></html>`
+	base, _ := url.Parse("https://example.com/posts/1")
+
+	p := Parse(page, base)
+	if p.Title != `Open "Graph" title` {
+		t.Errorf("Title is %q", p.Title)
+	}
+	if p.Description != "A description" {
+		t.Errorf("Description is %q", p.Description)
+	}
+	if p.ImageURL != "https://example.com/img/card.png" {
+		t.Errorf("ImageURL is %q", p.ImageURL)
+	}
+	if p.SiteName != "Example" {
+		t.Errorf("SiteName is %q", p.SiteName)
+	}
+
+	p = Parse(`<head><title>Only a title</title><meta property="og:image" content="javascript:alert(1)"></head>`, base)
+	if p.Title != "Only a title" || p.ImageURL != "" {
+		t.Errorf("Unexpected fallback preview: %+v", p)
+	}
+
+	// A Latin-1 page, with a NUL byte in its title.
+	p = Parse("<head><title>Caf\xe9\x00 cr\xe8me</title><meta name=description content=\"na\xefve\"></head>", base)
+	if p.Title != "Caf\uFFFD cr\uFFFDme" || p.Description != "na\uFFFDve" {
+		t.Errorf("Invalid text wasn't cleaned: %+v", p)
+	}
+}
+
+// testFetcher returns a fetcher that may connect to httptest servers.
+func testFetcher() *Fetcher {
+	f := NewFetcher()
+	f.allow = func(netip.AddrPort) bool { return true }
+	return f
+}
+
+func TestFetch(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/page":
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			fmt.Fprint(w, `<html><head><meta property="og:title" content="Hello"><meta property="og:image" content="card.png"></head></html>`)
+		case "/redirect":
+			http.Redirect(w, r, "/page", http.StatusFound)
+		case "/json":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{}`)
+		case "/huge":
+			w.Header().Set("Content-Type", "text/html")
+			fmt.Fprint(w, "<html><head>"+strings.Repeat(" ", MaxPageSize)+`<title>Too far</title></head></html>`)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer srv.Close()
+
+	f := testFetcher()
+	p, err := f.Fetch(context.Background(), srv.URL+"/redirect")
+	if err != nil {
+		t.Fatalf("Can't fetch preview: %v", err)
+	}
+	if p.Title != "Hello" || p.ImageURL != srv.URL+"/card.png" {
+		t.Errorf("Unexpected preview: %+v", p)
+	}
+
+	_, err = f.Fetch(context.Background(), srv.URL+"/json")
+	if !errors.Is(err, ErrNotHTML) {
+		t.Errorf("Fetching JSON returned %v, want ErrNotHTML", err)
+	}
+	_, err = f.Fetch(context.Background(), srv.URL+"/huge")
+	if !errors.Is(err, ErrNoPreview) {
+		t.Errorf("Metadata past MaxPageSize returned %v, want ErrNoPreview", err)
+	}
+	_, err = f.Fetch(context.Background(), srv.URL+"/missing")
+	if err == nil {
+		t.Errorf("Fetching a missing page succeeded")
+	}
+	_, err = f.Fetch(context.Background(), "file:///etc/passwd")
+	if !errors.Is(err, ErrUnsupportedURL) {
+		t.Errorf("Fetching a file URL returned %v, want ErrUnsupportedURL", err)
+	}
+}
+
+func TestFetchRefusesPrivateAddresses(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Request reached the loopback server")
+	}))
+	defer srv.Close()
+
+	_, err := NewFetcher().Fetch(context.Background(), srv.URL)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Fetching a loopback URL returned %v, want ErrForbiddenAddress", err)
+	}
+}
+
+func TestFetchRefusesRedirectToPrivateAddress(t *testing.T) {
+	internal := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("Request reached the internal server")
+	}))
+	defer internal.Close()
+	internalAddr := netip.MustParseAddrPort(internal.Listener.Addr().String())
+
+	public := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, internal.URL, http.StatusFound)
+	}))
+	defer public.Close()
+
+	// Only the "public" server is reachable.
+	f := NewFetcher()
+	f.allow = func(ap netip.AddrPort) bool { return ap != internalAddr }
+
+	_, err := f.Fetch(context.Background(), public.URL)
+	if !errors.Is(err, ErrForbiddenAddress) {
+		t.Errorf("Following a redirect to an internal URL returned %v, want ErrForbiddenAddress", err)
+	}
+}
+
+func TestPublicAddr(t *testing.T) {
+	tests := map[string]bool{
+		"93.184.216.34:443":          true,
+		"93.184.216.34:80":           true,
+		"93.184.216.34:8080":         false,
+		"127.0.0.1:80":               false,
+		"10.1.2.3:443":               false,
+		"172.16.0.1:443":             false,
+		"192.168.1.1:80":             false,
+		"169.254.169.254:80":         false,
+		"100.64.0.1:80":              false,
+		"0.0.0.0:80":                 false,
+		"[::1]:443":                  false,
+		"[fd00::1]:443":              false,
+		"[fe80::1]:443":              false,
+		"[::ffff:127.0.0.1]:443":     false,
+		"[2606:4700::6810:85e5]:443": true,
+	}
+	for addr, want := range tests {
+		if got := publicAddr(netip.MustParseAddrPort(addr)); got != want {
+			t.Errorf("publicAddr(%s) = %v, want %v", addr, got, want)
+		}
+	}
+}
@@ -0,0 +1,333 @@
+package links
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"html"
+	"io"
+	"mime"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strings"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// MaxPageSize is how much of a page is read looking for its metadata.
+	MaxPageSize = 512 << 10
+	// FetchTimeout bounds a whole fetch, redirects included.
+	FetchTimeout = 5 * time.Second
+	MaxRedirects = 3
+
+	maxTitleLength       = 200
+	maxDescriptionLength = 500
+)
+
+var (
+	ErrUnsupportedURL   = errors.New("Only http and https URLs can be previewed")
+	ErrForbiddenAddress = errors.New("URL points to a private or reserved address")
+	ErrNotHTML          = errors.New("URL isn't an HTML page")
+	ErrNoPreview        = errors.New("Page has no preview metadata")
+)
+
+// Preview is the Open Graph metadata of a page, falling back to its <title>
+// and description.
+type Preview struct {
+	Title       string
+	Description string
+	ImageURL    string
+	SiteName    string
+}
+
+// Fetcher fetches previews. Its requests can only reach public addresses on
+// the standard HTTP ports; the check happens when connecting, so a host
+// name or redirect can't be used to reach the internal network.
+type Fetcher struct {
+	client *http.Client
+	// allow reports whether the fetcher may connect to an address. Tests
+	// replace it to reach httptest servers on the loopback interface.
+	allow func(netip.AddrPort) bool
+}
+
+func NewFetcher() *Fetcher {
+	f := &Fetcher{allow: publicAddr}
+	dialer := &net.Dialer{
+		Timeout: FetchTimeout,
+		Control: func(network, address string, _ syscall.RawConn) error {
+			ap, err := netip.ParseAddrPort(address)
+			if err != nil || !f.allow(ap) {
+				return ErrForbiddenAddress
+			}
+			return nil
+		},
+	}
+	f.client = &http.Client{
+		Timeout: FetchTimeout,
+		Transport: &http.Transport{
+			// A proxy would make the connection on our behalf, bypassing
+			// the address check.
+			Proxy:                 nil,
+			DialContext:           dialer.DialContext,
+			TLSHandshakeTimeout:   FetchTimeout,
+			ResponseHeaderTimeout: FetchTimeout,
+			MaxIdleConns:          10,
+			IdleConnTimeout:       30 * time.Second,
+		},
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) > MaxRedirects {
+				return fmt.Errorf("Stopped after %d redirects", MaxRedirects)
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return ErrUnsupportedURL
+			}
+			return nil
+		},
+	}
+	return f
+}
+
+var reservedPrefixes = []netip.Prefix{
+	netip.MustParsePrefix("0.0.0.0/8"),
+	netip.MustParsePrefix("100.64.0.0/10"),
+	netip.MustParsePrefix("192.0.0.0/24"),
+	netip.MustParsePrefix("192.0.2.0/24"),
+	netip.MustParsePrefix("198.18.0.0/15"),
+	netip.MustParsePrefix("198.51.100.0/24"),
+	netip.MustParsePrefix("203.0.113.0/24"),
+	netip.MustParsePrefix("240.0.0.0/4"),
+	netip.MustParsePrefix("64:ff9b::/96"),
+	netip.MustParsePrefix("2001:db8::/32"),
+}
+
+// publicAddr reports whether ap is a public unicast address on port 80 or
+// 443.
+func publicAddr(ap netip.AddrPort) bool {
+	if ap.Port() != 80 && ap.Port() != 443 {
+		return false
+	}
+	addr := ap.Addr().Unmap()
+	if !addr.IsGlobalUnicast() || addr.IsPrivate() {
+		return false
+	}
+	for _, p := range reservedPrefixes {
+		if p.Contains(addr) {
+			return false
+		}
+	}
+	return true
+}
+
+// Fetch downloads the page at rawURL and returns its preview.
+func (f *Fetcher) Fetch(ctx context.Context, rawURL string) (Preview, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return Preview{}, ErrUnsupportedURL
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return Preview{}, err
+	}
+	req.Header.Set("User-Agent", "Chirpy-LinkPreview/1.0")
+	req.Header.Set("Accept", "text/html,application/xhtml+xml")
+
+	resp, err := f.client.Do(req)
+	if err != nil {
+		if errors.Is(err, ErrForbiddenAddress) {
+			return Preview{}, ErrForbiddenAddress
+		}
+		return Preview{}, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return Preview{}, fmt.Errorf("Page returned status %d", resp.StatusCode)
+	}
+	mediaType, _, _ := mime.ParseMediaType(resp.Header.Get("Content-Type"))
+	if mediaType != "text/html" && mediaType != "application/xhtml+xml" {
+		return Preview{}, ErrNotHTML
+	}
+
+	page, err := io.ReadAll(io.LimitReader(resp.Body, MaxPageSize))
+	if err != nil {
+		return Preview{}, err
+	}
+
+	p := Parse(string(page), resp.Request.URL)
+	if p.Title == "" {
+		return Preview{}, ErrNoPreview
+	}
+	return p, nil
+}
+
+// Parse reads the preview metadata from the <head> of an HTML page. base
+// is the page's URL, used to resolve a relative image.
+func Parse(page string, base *url.URL) Preview {
+	p := Preview{}
+	var title, description string
+
+	for {
+		i := strings.IndexByte(page, '<')
+		if i < 0 {
+			break
+		}
+		page = page[i+1:]
+		name, attrs, rest := parseTag(page)
+		page = rest
+
+		switch name {
+		case "meta":
+			key := strings.ToLower(attrs["property"])
+			if key == "" {
+				key = strings.ToLower(attrs["name"])
+			}
+			content := strings.TrimSpace(attrs["content"])
+			switch key {
+			case "og:title":
+				p.Title = content
+			case "og:description":
+				p.Description = content
+			case "og:image":
+				p.ImageURL = content
+			case "og:site_name":
+				p.SiteName = content
+			case "description":
+				description = content
+			}
+		case "title":
+			end := indexFold(page, "</title")
+			if end >= 0 {
+				title = strings.TrimSpace(html.UnescapeString(page[:end]))
+				page = page[end:]
+			}
+		case "/head", "body":
+			page = ""
+		}
+	}
+
+	if p.Title == "" {
+		p.Title = title
+	}
+	if p.Description == "" {
+		p.Description = description
+	}
+	p.Title = truncate(p.Title, maxTitleLength)
+	p.Description = truncate(p.Description, maxDescriptionLength)
+	p.SiteName = truncate(p.SiteName, maxTitleLength)
+	p.ImageURL = resolveImage(p.ImageURL, base)
+	return p
+}
+
+// parseTag parses the tag at the start of s, just after its '<'. It returns
+// the lowercased tag name, its attributes and what follows the tag.
+func parseTag(s string) (string, map[string]string, string) {
+	end := 0
+	for end < len(s) && !isSpace(s[end]) && s[end] != '>' && !(s[end] == '/' && end > 0) {
+		end++
+	}
+	name := strings.ToLower(s[:end])
+	s = s[end:]
+
+	attrs := map[string]string{}
+	for {
+		s = strings.TrimLeft(s, " \t\r\n\f/")
+		if s == "" {
+			return name, attrs, s
+		}
+		if s[0] == '>' {
+			return name, attrs, s[1:]
+		}
+
+		i := 0
+		for i < len(s) && !isSpace(s[i]) && s[i] != '=' && s[i] != '>' && s[i] != '/' {
+			i++
+		}
+		if i == 0 {
+			// A stray character, e.g. a quote; skip it.
+			s = s[1:]
+			continue
+		}
+		key := strings.ToLower(s[:i])
+		s = strings.TrimLeft(s[i:], " \t\r\n\f")
+
+		value := ""
+		if s != "" && s[0] == '=' {
+			s = strings.TrimLeft(s[1:], " \t\r\n\f")
+			if s != "" && (s[0] == '"' || s[0] == '\'') {
+				q := s[0]
+				j := strings.IndexByte(s[1:], q)
+				if j < 0 {
+					return name, attrs, ""
+				}
+				value, s = s[1:j+1], s[j+2:]
+			} else {
+				j := 0
+				for j < len(s) && !isSpace(s[j]) && s[j] != '>' {
+					j++
+				}
+				value, s = s[:j], s[j:]
+			}
+		}
+		if _, ok := attrs[key]; !ok {
+			attrs[key] = html.UnescapeString(value)
+		}
+	}
+}
+
+// indexFold is strings.Index ignoring case. Lowercasing the page
+// instead would change its byte offsets wherever it isn't valid UTF-8.
+func indexFold(s, substr string) int {
+	for i := 0; i+len(substr) <= len(s); i++ {
+		if strings.EqualFold(s[i:i+len(substr)], substr) {
+			return i
+		}
+	}
+	return -1
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n' || c == '\f'
+}
+
+// truncate collapses the whitespace in s and cuts it to at most n bytes.
+// Pages can be in any charset and hold any bytes, so invalid UTF-8 is
+// replaced and NUL bytes, which Postgres can't store in text, are dropped.
+func truncate(s string, n int) string {
+	s = strings.ToValidUTF8(s, "\uFFFD")
+	s = strings.ReplaceAll(s, "\x00", "")
+	s = strings.Join(strings.Fields(s), " ")
+	if len(s) <= n {
+		return s
+	}
+	// Don't cut a multi-byte character in half.
+	for n > 0 && s[n]&0xC0 == 0x80 {
+		n--
+	}
+	return s[:n]
+}
+
+func resolveImage(ref string, base *url.URL) string {
+	if ref == "" {
+		return ""
+	}
+	u, err := url.Parse(ref)
+	if err != nil {
+		return ""
+	}
+	if base != nil {
+		u = base.ResolveReference(u)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return ""
+	}
+	ref = u.String()
+	if !utf8.ValidString(ref) || strings.ContainsRune(ref, 0) {
+		return ""
+	}
+	return ref
+}
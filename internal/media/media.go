@@ -0,0 +1,436 @@
+// Package media checks uploaded images, strips their metadata and makes
+// thumbnails, using only the standard library's decoders.
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"net/http"
+)
+
+const (
+	// MaxPixels bounds the decoded size of an image, so a small file can't
+	// claim dimensions that exhaust memory once decoded.
+	MaxPixels = 25_000_000
+	// MaxFrames bounds the number of frames in an animated GIF. The pixels
+	// of all its frames together must also fit in MaxPixels.
+	MaxFrames     = 500
+	ThumbnailSize = 320
+)
+
+var (
+	ErrUnsupportedType = errors.New("Only JPEG, PNG and GIF images are supported")
+	ErrTooManyPixels   = errors.New("Image dimensions are too large")
+	ErrCorrupt         = errors.New("Image is corrupt")
+)
+
+type Image struct {
+	ContentType   string
+	Data          []byte
+	Width         int
+	Height        int
+	Thumbnail     []byte
+	ThumbnailType string
+}
+
+// Process sniffs the type of an uploaded image, strips EXIF and other
+// metadata from it and renders a thumbnail no larger than ThumbnailSize on
+// either side.
+func Process(data []byte) (Image, error) {
+	contentType := http.DetectContentType(data)
+
+	cfg, _, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		if contentType != "image/jpeg" && contentType != "image/png" && contentType != "image/gif" {
+			return Image{}, ErrUnsupportedType
+		}
+		return Image{}, ErrCorrupt
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > MaxPixels {
+		return Image{}, ErrTooManyPixels
+	}
+
+	var stripped []byte
+	orientation := 1
+	switch contentType {
+	case "image/jpeg":
+		stripped, orientation, err = stripJPEG(data)
+	case "image/png":
+		stripped, err = stripPNG(data)
+	case "image/gif":
+		stripped, err = stripGIF(data)
+	default:
+		return Image{}, ErrUnsupportedType
+	}
+	if err != nil {
+		return Image{}, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(stripped))
+	if err != nil {
+		return Image{}, ErrCorrupt
+	}
+
+	// The stripped JPEG keeps its Orientation tag for viewers to apply, but
+	// the thumbnail is re-encoded without one, so it's rotated here.
+	thumb := orient(Thumbnail(img, ThumbnailSize), orientation)
+	width, height := cfg.Width, cfg.Height
+	if orientation >= 5 {
+		width, height = height, width
+	}
+	buf := bytes.Buffer{}
+	thumbType := "image/png"
+	if contentType == "image/jpeg" {
+		thumbType = "image/jpeg"
+		err = jpeg.Encode(&buf, thumb, &jpeg.Options{Quality: 80})
+	} else {
+		err = png.Encode(&buf, thumb)
+	}
+	if err != nil {
+		return Image{}, err
+	}
+
+	return Image{
+		ContentType:   contentType,
+		Data:          stripped,
+		Width:         width,
+		Height:        height,
+		Thumbnail:     buf.Bytes(),
+		ThumbnailType: thumbType,
+	}, nil
+}
+
+// stripJPEG drops the APP and COM segments that carry EXIF, XMP, IPTC and
+// comments, keeping JFIF, ICC colour profiles and the Adobe segment that
+// decoders need for CMYK images. The image data itself isn't re-encoded.
+// An EXIF segment is replaced by one holding only its Orientation tag, which
+// is also returned, so photos taken sideways still display upright.
+func stripJPEG(data []byte) ([]byte, int, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, 0, ErrCorrupt
+	}
+
+	out := bytes.Buffer{}
+	out.Write(data[:2])
+	orientation := 1
+	i := 2
+	for i+4 <= len(data) {
+		if data[i] != 0xFF {
+			return nil, 0, ErrCorrupt
+		}
+		marker := data[i+1]
+		if marker == 0xFF {
+			// Fill byte before a marker.
+			i++
+			continue
+		}
+		if marker == 0xD8 || marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) {
+			out.Write(data[i : i+2])
+			i += 2
+			continue
+		}
+
+		length := int(binary.BigEndian.Uint16(data[i+2:]))
+		end := i + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, 0, ErrCorrupt
+		}
+
+		if marker == 0xDA {
+			// Start of scan: the rest is entropy-coded data.
+			out.Write(data[i:])
+			return out.Bytes(), orientation, nil
+		}
+
+		segment := data[i:end]
+		payload := data[i+4 : end]
+		keep := true
+		switch {
+		case marker == 0xE0:
+			keep = bytes.HasPrefix(payload, []byte("JFIF\x00")) || bytes.HasPrefix(payload, []byte("JFXX\x00"))
+		case marker == 0xE2:
+			keep = bytes.HasPrefix(payload, []byte("ICC_PROFILE\x00"))
+		case marker == 0xEE:
+			keep = bytes.HasPrefix(payload, []byte("Adobe"))
+		case marker == 0xE1 && orientation == 1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")):
+			keep = false
+			orientation = exifOrientation(payload[6:])
+			if orientation != 1 {
+				out.Write(orientationSegment(orientation))
+			}
+		case marker >= 0xE1 && marker <= 0xEF, marker == 0xFE:
+			keep = false
+		}
+		if keep {
+			out.Write(segment)
+		}
+		i = end
+	}
+
+	return nil, 0, ErrCorrupt
+}
+
+// exifOrientation reads the Orientation tag from the first IFD of the TIFF
+// data in an EXIF segment, returning 1, the identity, if it's missing or
+// invalid.
+func exifOrientation(tiff []byte) int {
+	if len(tiff) < 8 {
+		return 1
+	}
+	var order binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		order = binary.LittleEndian
+	case "MM":
+		order = binary.BigEndian
+	default:
+		return 1
+	}
+
+	ifd := int(order.Uint32(tiff[4:]))
+	if ifd < 8 || ifd+2 > len(tiff) {
+		return 1
+	}
+	n := int(order.Uint16(tiff[ifd:]))
+	for e := ifd + 2; e+12 <= len(tiff) && n > 0; e, n = e+12, n-1 {
+		// A SHORT with a count of 1 is stored at the start of the value.
+		if order.Uint16(tiff[e:]) == 0x0112 && order.Uint16(tiff[e+2:]) == 3 {
+			if o := int(order.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			return 1
+		}
+	}
+	return 1
+}
+
+// orientationSegment builds an APP1 EXIF segment with a single IFD holding
+// only the Orientation tag.
+func orientationSegment(orientation int) []byte {
+	seg := []byte{0xFF, 0xE1, 0, 0}
+	seg = append(seg, "Exif\x00\x00MM\x00\x2A\x00\x00\x00\x08"...)
+	seg = binary.BigEndian.AppendUint16(seg, 1)
+	seg = binary.BigEndian.AppendUint16(seg, 0x0112)
+	seg = binary.BigEndian.AppendUint16(seg, 3)
+	seg = binary.BigEndian.AppendUint32(seg, 1)
+	seg = binary.BigEndian.AppendUint16(seg, uint16(orientation))
+	seg = append(seg, 0, 0, 0, 0, 0, 0)
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(seg)-2))
+	return seg
+}
+
+// orient applies an EXIF orientation to img, turning it upright.
+// Orientations 5 to 8 swap the width and height.
+func orient(img *image.NRGBA, orientation int) *image.NRGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			dx, dy := x, y
+			switch orientation {
+			case 2:
+				dx = w - 1 - x
+			case 3:
+				dx, dy = w-1-x, h-1-y
+			case 4:
+				dy = h - 1 - y
+			case 5:
+				dx, dy = y, x
+			case 6:
+				dx, dy = h-1-y, x
+			case 7:
+				dx, dy = h-1-y, w-1-x
+			case 8:
+				dx, dy = y, w-1-x
+			}
+			dst.SetNRGBA(dx, dy, img.NRGBAAt(img.Bounds().Min.X+x, img.Bounds().Min.Y+y))
+		}
+	}
+	return dst
+}
+
+var pngSignature = []byte("\x89PNG\r\n\x1a\n")
+
+// pngMetadataChunks are the ancillary chunks that can hold EXIF data, text or
+// timestamps. Every other chunk is kept as is.
+var pngMetadataChunks = map[string]bool{
+	"eXIf": true,
+	"tEXt": true,
+	"zTXt": true,
+	"iTXt": true,
+	"tIME": true,
+}
+
+func stripPNG(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, pngSignature) {
+		return nil, ErrCorrupt
+	}
+
+	out := bytes.Buffer{}
+	out.Write(pngSignature)
+	i := len(pngSignature)
+	for i+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[i:]))
+		end := i + 12 + length
+		if length < 0 || end > len(data) {
+			return nil, ErrCorrupt
+		}
+
+		chunkType := string(data[i+4 : i+8])
+		if !pngMetadataChunks[chunkType] {
+			out.Write(data[i:end])
+		}
+		i = end
+
+		if chunkType == "IEND" {
+			return out.Bytes(), nil
+		}
+	}
+
+	return nil, ErrCorrupt
+}
+
+// stripGIF re-encodes a GIF, which drops comment and application extensions
+// while keeping every frame and the loop count.
+func stripGIF(data []byte) ([]byte, error) {
+	// DecodeAll decodes every frame at once, so they're counted first.
+	err := checkGIFFrames(data)
+	if err != nil {
+		return nil, err
+	}
+
+	g, err := gif.DecodeAll(bytes.NewReader(data))
+	if err != nil {
+		return nil, ErrCorrupt
+	}
+
+	buf := bytes.Buffer{}
+	err = gif.EncodeAll(&buf, g)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// checkGIFFrames walks the blocks of a GIF without decoding them, and
+// returns ErrTooManyPixels if it has more than MaxFrames frames or they add
+// up to more than MaxPixels.
+func checkGIFFrames(data []byte) error {
+	if len(data) < 13 {
+		return ErrCorrupt
+	}
+	i := 13
+	if data[10]&0x80 != 0 {
+		i += 3 << (data[10]&7 + 1)
+	}
+
+	frames, pixels := 0, 0
+	for i < len(data) {
+		switch data[i] {
+		case 0x21:
+			// Extension introducer and label.
+			i += 2
+		case 0x2C:
+			if i+10 > len(data) {
+				return ErrCorrupt
+			}
+			w := int(binary.LittleEndian.Uint16(data[i+5:]))
+			h := int(binary.LittleEndian.Uint16(data[i+7:]))
+			flags := data[i+9]
+			frames++
+			pixels += w * h
+			if frames > MaxFrames || pixels > MaxPixels {
+				return ErrTooManyPixels
+			}
+			i += 10
+			if flags&0x80 != 0 {
+				i += 3 << (flags&7 + 1)
+			}
+			// LZW minimum code size.
+			i++
+		case 0x3B:
+			return nil
+		default:
+			return ErrCorrupt
+		}
+
+		// Both are followed by data sub-blocks, ending with an empty one.
+		for {
+			if i >= len(data) {
+				return ErrCorrupt
+			}
+			n := int(data[i])
+			i += 1 + n
+			if n == 0 {
+				break
+			}
+		}
+	}
+
+	// DecodeAll decides whether a missing trailer is acceptable.
+	return nil
+}
+
+// Thumbnail scales img down to fit in a size by size square, averaging the
+// source pixels under each thumbnail pixel. Images that already fit are
+// copied unscaled.
+func Thumbnail(img image.Image, size int) *image.NRGBA {
+	b := img.Bounds()
+	sw, sh := b.Dx(), b.Dy()
+	dw, dh := sw, sh
+	if sw > size || sh > size {
+		if sw >= sh {
+			dw, dh = size, max(1, sh*size/sw)
+		} else {
+			dw, dh = max(1, sw*size/sh), size
+		}
+	}
+
+	dst := image.NewNRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		sy0 := b.Min.Y + y*sh/dh
+		sy1 := max(sy0+1, b.Min.Y+(y+1)*sh/dh)
+		for x := 0; x < dw; x++ {
+			sx0 := b.Min.X + x*sw/dw
+			sx1 := max(sx0+1, b.Min.X+(x+1)*sw/dw)
+
+			var r, g, bl, a, n uint64
+			for sy := sy0; sy < sy1; sy++ {
+				for sx := sx0; sx < sx1; sx++ {
+					pr, pg, pb, pa := img.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					bl += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+
+			// The sums are premultiplied by alpha, NRGBA isn't.
+			c := color.NRGBA{}
+			if a > 0 {
+				c.R = uint8(r * 0xFF / a)
+				c.G = uint8(g * 0xFF / a)
+				c.B = uint8(bl * 0xFF / a)
+				c.A = uint8((a / n) >> 8)
+			}
+			dst.SetNRGBA(x, y, c)
+		}
+	}
+
+	return dst
+}
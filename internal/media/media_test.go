@@ -0,0 +1,242 @@
+package media
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/gif"
+	"image/jpeg"
+	"image/png"
+	"testing"
+)
+
+func solid(w, h int, c color.Color) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.Set(x, y, c)
+		}
+	}
+	return img
+}
+
+func pngChunk(chunkType string, data []byte) []byte {
+	chunk := make([]byte, 8, 12+len(data))
+	binary.BigEndian.PutUint32(chunk, uint32(len(data)))
+	copy(chunk[4:], chunkType)
+	chunk = append(chunk, data...)
+	return binary.BigEndian.AppendUint32(chunk, crc32.ChecksumIEEE(chunk[4:]))
+}
+
+func TestProcessJPEGStripsExif(t *testing.T) {
+	buf := bytes.Buffer{}
+	jpeg.Encode(&buf, solid(40, 20, color.NRGBA{200, 10, 10, 255}), nil)
+	raw := buf.Bytes()
+
+	exif := []byte("Exif\x00\x00GPS 51.5N 0.1W")
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(exif)+2))
+	data := append(append(append([]byte{}, raw[:2]...), append(app1, exif...)...), raw[2:]...)
+
+	img, err := Process(data)
+	if err != nil {
+		t.Fatalf("Can't process JPEG: %v", err)
+	}
+	if img.ContentType != "image/jpeg" || img.Width != 40 || img.Height != 20 {
+		t.Errorf("Unexpected image info: %s %dx%d", img.ContentType, img.Width, img.Height)
+	}
+	if bytes.Contains(img.Data, []byte("GPS")) {
+		t.Errorf("EXIF data wasn't stripped")
+	}
+	if _, err := jpeg.Decode(bytes.NewReader(img.Data)); err != nil {
+		t.Errorf("Stripped JPEG doesn't decode: %v", err)
+	}
+}
+
+func TestProcessPNGStripsText(t *testing.T) {
+	buf := bytes.Buffer{}
+	png.Encode(&buf, solid(10, 10, color.NRGBA{0, 0, 255, 128}))
+	raw := buf.Bytes()
+
+	// Insert a tEXt chunk straight after IHDR, which is 8+25 bytes in.
+	ihdrEnd := 8 + 25
+	text := pngChunk("tEXt", []byte("Comment\x00taken at home"))
+	data := append(append(append([]byte{}, raw[:ihdrEnd]...), text...), raw[ihdrEnd:]...)
+
+	img, err := Process(data)
+	if err != nil {
+		t.Fatalf("Can't process PNG: %v", err)
+	}
+	if bytes.Contains(img.Data, []byte("taken at home")) {
+		t.Errorf("tEXt chunk wasn't stripped")
+	}
+	if img.ThumbnailType != "image/png" {
+		t.Errorf("PNG thumbnail should stay PNG, got %s", img.ThumbnailType)
+	}
+}
+
+func TestProcessGIF(t *testing.T) {
+	buf := bytes.Buffer{}
+	gif.Encode(&buf, solid(8, 8, color.Black), nil)
+
+	img, err := Process(buf.Bytes())
+	if err != nil {
+		t.Fatalf("Can't process GIF: %v", err)
+	}
+	if img.ContentType != "image/gif" {
+		t.Errorf("Expected image/gif, got %s", img.ContentType)
+	}
+}
+
+func TestProcessJPEGKeepsOrientation(t *testing.T) {
+	buf := bytes.Buffer{}
+	jpeg.Encode(&buf, solid(40, 20, color.NRGBA{200, 10, 10, 255}), nil)
+	raw := buf.Bytes()
+
+	// Little-endian TIFF with a GPS note and Orientation 6, rotate 90° clockwise.
+	tiff := []byte("II\x2A\x00\x08\x00\x00\x00")
+	tiff = binary.LittleEndian.AppendUint16(tiff, 1)
+	tiff = append(tiff, 0x12, 0x01, 3, 0, 1, 0, 0, 0, 6, 0, 0, 0)
+	tiff = append(tiff, "\x00\x00\x00\x00GPS 51.5N 0.1W"...)
+	exif := append([]byte("Exif\x00\x00"), tiff...)
+	app1 := []byte{0xFF, 0xE1, 0, 0}
+	binary.BigEndian.PutUint16(app1[2:], uint16(len(exif)+2))
+	data := append(append(append([]byte{}, raw[:2]...), append(app1, exif...)...), raw[2:]...)
+
+	img, err := Process(data)
+	if err != nil {
+		t.Fatalf("Can't process JPEG: %v", err)
+	}
+	if bytes.Contains(img.Data, []byte("GPS")) {
+		t.Errorf("EXIF data wasn't stripped")
+	}
+	if _, o, err := stripJPEG(img.Data); err != nil || o != 6 {
+		t.Errorf("Stripped JPEG has orientation %d, %v, want 6", o, err)
+	}
+	if img.Width != 20 || img.Height != 40 {
+		t.Errorf("Expected upright 20x40, got %dx%d", img.Width, img.Height)
+	}
+	thumb, err := jpeg.Decode(bytes.NewReader(img.Thumbnail))
+	if err != nil || thumb.Bounds().Dx() != 20 || thumb.Bounds().Dy() != 40 {
+		t.Errorf("Expected an upright 20x40 thumbnail, got %v, %v", thumb.Bounds(), err)
+	}
+}
+
+func TestOrient(t *testing.T) {
+	img := image.NewNRGBA(image.Rect(0, 0, 2, 1))
+	red, blue := color.NRGBA{255, 0, 0, 255}, color.NRGBA{0, 0, 255, 255}
+	img.SetNRGBA(0, 0, red)
+	img.SetNRGBA(1, 0, blue)
+
+	// Where the red pixel, the original top left, ends up.
+	tests := []struct {
+		orientation int
+		w, h        int
+		x, y        int
+	}{
+		{1, 2, 1, 0, 0},
+		{2, 2, 1, 1, 0},
+		{3, 2, 1, 1, 0},
+		{4, 2, 1, 0, 0},
+		{5, 1, 2, 0, 0},
+		{6, 1, 2, 0, 0},
+		{7, 1, 2, 0, 1},
+		{8, 1, 2, 0, 1},
+	}
+	for _, tt := range tests {
+		got := orient(img, tt.orientation)
+		if got.Bounds().Dx() != tt.w || got.Bounds().Dy() != tt.h {
+			t.Errorf("Orientation %d: expected %dx%d, got %v", tt.orientation, tt.w, tt.h, got.Bounds())
+			continue
+		}
+		if got.NRGBAAt(tt.x, tt.y) != red {
+			t.Errorf("Orientation %d: red isn't at %d,%d", tt.orientation, tt.x, tt.y)
+		}
+	}
+}
+
+func TestProcessRejectsLongGIF(t *testing.T) {
+	frame := image.NewPaletted(image.Rect(0, 0, 1000, 1000), color.Palette{color.Black, color.White})
+	g := &gif.GIF{}
+	for range MaxPixels/(1000*1000) + 1 {
+		g.Image = append(g.Image, frame)
+		g.Delay = append(g.Delay, 10)
+	}
+	buf := bytes.Buffer{}
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Process(buf.Bytes()); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Expected ErrTooManyPixels for %d frames, got %v", len(g.Image), err)
+	}
+
+	small := image.NewPaletted(image.Rect(0, 0, 1, 1), color.Palette{color.Black, color.White})
+	g = &gif.GIF{}
+	for range MaxFrames + 1 {
+		g.Image = append(g.Image, small)
+		g.Delay = append(g.Delay, 10)
+	}
+	buf.Reset()
+	if err := gif.EncodeAll(&buf, g); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := Process(buf.Bytes()); !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Expected ErrTooManyPixels for %d frames, got %v", len(g.Image), err)
+	}
+
+	g.Image, g.Delay = g.Image[:3], g.Delay[:3]
+	buf.Reset()
+	gif.EncodeAll(&buf, g)
+	if _, err := Process(buf.Bytes()); err != nil {
+		t.Errorf("Short GIF was rejected: %v", err)
+	}
+}
+
+func TestProcessRejects(t *testing.T) {
+	_, err := Process([]byte("RIFF\x00\x00\x00\x00WEBPVP8 "))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for WebP, got %v", err)
+	}
+
+	_, err = Process([]byte("<svg xmlns=\"http://www.w3.org/2000/svg\"></svg>"))
+	if !errors.Is(err, ErrUnsupportedType) {
+		t.Errorf("Expected ErrUnsupportedType for SVG, got %v", err)
+	}
+
+	// A valid PNG header claiming to be 10000x10000.
+	buf := bytes.Buffer{}
+	png.Encode(&buf, solid(1, 1, color.White))
+	data := buf.Bytes()
+	ihdr := make([]byte, 13)
+	copy(ihdr, data[16:29])
+	binary.BigEndian.PutUint32(ihdr[0:], 10000)
+	binary.BigEndian.PutUint32(ihdr[4:], 10000)
+	data = append(append(append([]byte{}, data[:8]...), pngChunk("IHDR", ihdr)...), data[33:]...)
+	_, err = Process(data)
+	if !errors.Is(err, ErrTooManyPixels) {
+		t.Errorf("Expected ErrTooManyPixels, got %v", err)
+	}
+}
+
+func TestThumbnail(t *testing.T) {
+	thumb := Thumbnail(solid(1000, 500, color.NRGBA{10, 200, 30, 255}), 320)
+	if thumb.Bounds().Dx() != 320 || thumb.Bounds().Dy() != 160 {
+		t.Errorf("Expected 320x160, got %v", thumb.Bounds())
+	}
+	if c := thumb.NRGBAAt(100, 100); c != (color.NRGBA{10, 200, 30, 255}) {
+		t.Errorf("Thumbnail changed the colour: %v", c)
+	}
+
+	small := Thumbnail(solid(50, 80, color.White), 320)
+	if small.Bounds().Dx() != 50 || small.Bounds().Dy() != 80 {
+		t.Errorf("Small images shouldn't be scaled, got %v", small.Bounds())
+	}
+
+	tall := Thumbnail(solid(10, 5000, color.White), 320)
+	if tall.Bounds().Dx() != 1 || tall.Bounds().Dy() != 320 {
+		t.Errorf("Expected 1x320, got %v", tall.Bounds())
+	}
+}
@@ -0,0 +1,144 @@
+// Package ratelimit limits how often something may happen with token
+// buckets. Each key has a bucket holding up to Limit.Burst tokens that
+// refills at Limit.Burst tokens per Limit.Per; every request takes a token
+// and is refused when there are none left.
+package ratelimit
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+type Limit struct {
+	Burst int
+	Per   time.Duration
+}
+
+// Rate is how many tokens a bucket regains a second.
+func (l Limit) Rate() float64 {
+	return float64(l.Burst) / l.Per.Seconds()
+}
+
+// Refill returns how many tokens a bucket holding tokens has after elapsed.
+func (l Limit) Refill(tokens float64, elapsed time.Duration) float64 {
+	return math.Min(float64(l.Burst), tokens+elapsed.Seconds()*l.Rate())
+}
+
+// Result is the outcome of taking a token.
+type Result struct {
+	Allowed   bool
+	Limit     Limit
+	Remaining int
+	// Reset is how long until the bucket is full again.
+	Reset time.Duration
+	// RetryAfter is how long until a token is available, if none was.
+	RetryAfter time.Duration
+}
+
+// NewResult describes a bucket left with tokens after a request was allowed
+// or refused.
+func NewResult(l Limit, tokens float64, allowed bool) Result {
+	res := Result{
+		Allowed:   allowed,
+		Limit:     l,
+		Remaining: max(int(math.Floor(tokens)), 0),
+		Reset:     seconds((float64(l.Burst) - tokens) / l.Rate()),
+	}
+	if !allowed {
+		res.RetryAfter = seconds((1 - tokens) / l.Rate())
+	}
+	return res
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(math.Max(s, 0) * float64(time.Second))
+}
+
+// SetHeaders sets the RateLimit-* headers describing res, and Retry-After
+// if the request was refused. Durations are rounded up to whole seconds.
+func (res Result) SetHeaders(h http.Header) {
+	h.Set("RateLimit-Limit", strconv.Itoa(res.Limit.Burst))
+	h.Set("RateLimit-Remaining", strconv.Itoa(res.Remaining))
+	h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(res.Reset)))
+	h.Set("RateLimit-Policy", fmt.Sprintf("%d;w=%d", res.Limit.Burst, ceilSeconds(res.Limit.Per)))
+	if !res.Allowed {
+		h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(res.RetryAfter), 1)))
+	}
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
+
+// Store keeps the buckets.
+type Store interface {
+	// Take takes a token from the bucket for key, which is full if it
+	// doesn't exist yet.
+	Take(ctx context.Context, key string, l Limit) (Result, error)
+}
+
+type bucket struct {
+	tokens  float64
+	updated time.Time
+	limit   Limit
+}
+
+// Memory keeps buckets in memory, so each instance of the server limits
+// requests separately.
+type Memory struct {
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+	now       func() time.Time
+}
+
+// sweepInterval is how often Memory forgets buckets that have refilled.
+const sweepInterval = time.Minute
+
+func NewMemory() *Memory {
+	return &Memory{
+		buckets: map[string]*bucket{},
+		now:     time.Now,
+	}
+}
+
+func (m *Memory) Take(ctx context.Context, key string, l Limit) (Result, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	now := m.now()
+	if now.Sub(m.lastSweep) >= sweepInterval {
+		m.sweep(now)
+	}
+
+	b := m.buckets[key]
+	if b == nil {
+		b = &bucket{tokens: float64(l.Burst), updated: now}
+		m.buckets[key] = b
+	}
+	b.tokens = l.Refill(b.tokens, now.Sub(b.updated))
+	b.updated = now
+	b.limit = l
+
+	if b.tokens < 1 {
+		return NewResult(l, b.tokens, false), nil
+	}
+	b.tokens--
+	return NewResult(l, b.tokens, true), nil
+}
+
+// sweep forgets the buckets that are full again, since they are the same
+// as missing ones.
+func (m *Memory) sweep(now time.Time) {
+	for key, b := range m.buckets {
+		if b.limit.Refill(b.tokens, now.Sub(b.updated)) >= float64(b.limit.Burst) {
+			delete(m.buckets, key)
+		}
+	}
+	m.lastSweep = now
+}
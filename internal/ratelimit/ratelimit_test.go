@@ -0,0 +1,94 @@
+package ratelimit
+
+import (
+	"context"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func testMemory() (*Memory, *time.Time) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	m := NewMemory()
+	m.now = func() time.Time { return now }
+	return m, &now
+}
+
+func TestMemoryTake(t *testing.T) {
+	m, now := testMemory()
+	l := Limit{Burst: 3, Per: time.Minute}
+	ctx := context.Background()
+
+	for i := range 3 {
+		res, err := m.Take(ctx, "a", l)
+		if err != nil || !res.Allowed {
+			t.Fatalf("Request %d was refused: %+v, %v", i+1, res, err)
+		}
+		if res.Remaining != 2-i {
+			t.Errorf("Request %d left %d remaining, want %d", i+1, res.Remaining, 2-i)
+		}
+	}
+
+	res, _ := m.Take(ctx, "a", l)
+	if res.Allowed {
+		t.Fatal("Request over the burst was allowed")
+	}
+	if res.RetryAfter != 20*time.Second || res.Reset != time.Minute {
+		t.Errorf("Refused request has RetryAfter %v and Reset %v, want 20s and 1m", res.RetryAfter, res.Reset)
+	}
+
+	if res, _ := m.Take(ctx, "b", l); !res.Allowed {
+		t.Error("Another key shares the bucket")
+	}
+
+	*now = now.Add(20 * time.Second)
+	if res, _ := m.Take(ctx, "a", l); !res.Allowed || res.Remaining != 0 {
+		t.Errorf("Request after a refill gave %+v", res)
+	}
+	if res, _ := m.Take(ctx, "a", l); res.Allowed {
+		t.Error("Refused requests refilled the bucket")
+	}
+}
+
+func TestMemorySweep(t *testing.T) {
+	m, now := testMemory()
+	ctx := context.Background()
+
+	m.Take(ctx, "short", Limit{Burst: 1, Per: time.Second})
+	m.Take(ctx, "long", Limit{Burst: 1, Per: time.Hour})
+
+	*now = now.Add(2 * sweepInterval)
+	m.Take(ctx, "other", Limit{Burst: 1, Per: time.Second})
+
+	if _, ok := m.buckets["short"]; ok {
+		t.Error("Full bucket wasn't swept")
+	}
+	if _, ok := m.buckets["long"]; !ok {
+		t.Error("Refilling bucket was swept")
+	}
+}
+
+func TestSetHeaders(t *testing.T) {
+	l := Limit{Burst: 10, Per: time.Minute}
+	h := http.Header{}
+	NewResult(l, 0.5, false).SetHeaders(h)
+
+	want := map[string]string{
+		"RateLimit-Limit":     "10",
+		"RateLimit-Remaining": "0",
+		"RateLimit-Reset":     "57",
+		"RateLimit-Policy":    "10;w=60",
+		"Retry-After":         "3",
+	}
+	for k, v := range want {
+		if got := h.Get(k); got != v {
+			t.Errorf("%s is %q, want %q", k, got, v)
+		}
+	}
+
+	h = http.Header{}
+	NewResult(l, 9, true).SetHeaders(h)
+	if h.Get("Retry-After") != "" {
+		t.Error("Allowed request has Retry-After")
+	}
+}
@@ -0,0 +1,145 @@
+package spam
+
+import (
+	"math"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/aobatake/goserver/internal/links"
+)
+
+const (
+	// MinDocuments is how many spam and how many ham examples the
+	// classifier needs before it classifies anything.
+	MinDocuments = 20
+
+	maxTokensPerDocument = 200
+	maxTokenLength       = 30
+)
+
+// Example is a chirp a moderator has decided is spam or not.
+type Example struct {
+	Text string
+	Spam bool
+}
+
+// Classifier is a naive Bayes classifier over the words and link hosts in
+// chirps. It is safe for concurrent use.
+type Classifier struct {
+	mu sync.RWMutex
+	// counts holds how many spam and ham documents each token appeared in.
+	counts map[string]*[2]int
+	docs   [2]int
+}
+
+func NewClassifier() *Classifier {
+	return &Classifier{counts: map[string]*[2]int{}}
+}
+
+func class(spam bool) int {
+	if spam {
+		return 1
+	}
+	return 0
+}
+
+// Train adds one example to the classifier.
+func (c *Classifier) Train(text string, spam bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.train(text, class(spam))
+}
+
+func (c *Classifier) train(text string, k int) {
+	c.docs[k]++
+	for _, t := range tokens(text) {
+		n := c.counts[t]
+		if n == nil {
+			n = &[2]int{}
+			c.counts[t] = n
+		}
+		n[k]++
+	}
+}
+
+// Load replaces everything the classifier has learned with examples.
+func (c *Classifier) Load(examples []Example) {
+	fresh := NewClassifier()
+	for _, e := range examples {
+		fresh.train(e.Text, class(e.Spam))
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.counts = fresh.counts
+	c.docs = fresh.docs
+}
+
+// SpamProbability returns how likely text is to be spam. ok is false if the
+// classifier hasn't seen MinDocuments of both spam and ham yet.
+func (c *Classifier) SpamProbability(text string) (p float64, ok bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+
+	if c.docs[0] < MinDocuments || c.docs[1] < MinDocuments {
+		return 0, false
+	}
+
+	total := float64(c.docs[0] + c.docs[1])
+	logOdds := math.Log(float64(c.docs[1])/total) - math.Log(float64(c.docs[0])/total)
+	for _, t := range tokens(text) {
+		n := c.counts[t]
+		if n == nil {
+			// Tokens never seen in training say nothing either way.
+			continue
+		}
+		// Laplace smoothing keeps tokens seen in only one class from
+		// deciding on their own.
+		pSpam := (float64(n[1]) + 1) / (float64(c.docs[1]) + 2)
+		pHam := (float64(n[0]) + 1) / (float64(c.docs[0]) + 2)
+		logOdds += math.Log(pSpam) - math.Log(pHam)
+	}
+	return 1 / (1 + math.Exp(-logOdds)), true
+}
+
+// tokens returns the distinct lowercase words in text, and the hosts of
+// its links as "host:example.com".
+func tokens(text string) []string {
+	seen := map[string]bool{}
+	var tt []string
+	add := func(t string) {
+		if len(tt) >= maxTokensPerDocument || seen[t] {
+			return
+		}
+		seen[t] = true
+		tt = append(tt, t)
+	}
+
+	prev := 0
+	var words []string
+	for _, e := range links.Find(text) {
+		words = append(words, text[prev:e.Start])
+		prev = e.End
+		host := e.URL
+		if i := strings.Index(host, "://"); i >= 0 {
+			host = host[i+3:]
+		}
+		if i := strings.IndexAny(host, "/?#"); i >= 0 {
+			host = host[:i]
+		}
+		add("host:" + strings.ToLower(host))
+	}
+	words = append(words, text[prev:])
+
+	for _, w := range words {
+		for _, word := range strings.FieldsFunc(strings.ToLower(w), func(c rune) bool {
+			return !unicode.IsLetter(c) && !unicode.IsDigit(c)
+		}) {
+			if len(word) >= 2 && len(word) <= maxTokenLength {
+				add(word)
+			}
+		}
+	}
+	return tt
+}
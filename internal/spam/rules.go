@@ -0,0 +1,243 @@
+package spam
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/aobatake/goserver/internal/links"
+)
+
+// shingleSize is the length in characters of the shingles near-duplicates
+// are compared by.
+const shingleSize = 4
+
+// Duplicates scores chirps that are near-identical to ones the author
+// posted within Window, comparing their shingles. Each near-duplicate
+// scores 0.5, so posting the same thing twice is fine but a third time is
+// held.
+type Duplicates struct {
+	// Threshold is the Jaccard similarity from which two chirps are
+	// near-identical.
+	Threshold float64
+	Window    time.Duration
+}
+
+func (Duplicates) Name() string { return "duplicates" }
+
+func (d Duplicates) Check(s Submission) (float64, string) {
+	sh := shingles(s.Body)
+	n := 0
+	for _, p := range s.Recent {
+		if s.Now.Sub(p.CreatedAt) > d.Window {
+			continue
+		}
+		// A chirp without letters or digits, e.g. only emoji, has no
+		// shingles, so only the same text counts as a duplicate.
+		if len(sh) == 0 && normalize(p.Body) == normalize(s.Body) {
+			n++
+		} else if len(sh) > 0 && jaccard(sh, shingles(p.Body)) >= d.Threshold {
+			n++
+		}
+	}
+	if n == 0 {
+		return 0, ""
+	}
+	return 0.5 * float64(n), fmt.Sprintf("%d near-identical chirps in the last %d hours", n, int(d.Window.Hours()))
+}
+
+// shingles returns the overlapping shingleSize-character pieces of body,
+// ignoring case, punctuation and spacing, so chirps that differ only in
+// those or in a word or two share most of them. A body without letters or
+// digits has none.
+func shingles(body string) map[string]bool {
+	var b strings.Builder
+	space := false
+	for _, c := range strings.ToLower(body) {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			if space && b.Len() > 0 {
+				b.WriteByte(' ')
+			}
+			b.WriteRune(c)
+			space = false
+		} else {
+			space = true
+		}
+	}
+
+	text := []rune(b.String())
+	sh := map[string]bool{}
+	if len(text) == 0 {
+		return sh
+	}
+	if len(text) <= shingleSize {
+		sh[string(text)] = true
+		return sh
+	}
+	for i := 0; i+shingleSize <= len(text); i++ {
+		sh[string(text[i:i+shingleSize])] = true
+	}
+	return sh
+}
+
+// normalize lowercases body and collapses its spacing.
+func normalize(body string) string {
+	return strings.Join(strings.Fields(strings.ToLower(body)), " ")
+}
+
+// jaccard is the size of the intersection of a and b over the size of
+// their union.
+func jaccard(a, b map[string]bool) float64 {
+	if len(a) == 0 && len(b) == 0 {
+		return 1
+	}
+	shared := 0
+	for s := range a {
+		if b[s] {
+			shared++
+		}
+	}
+	return float64(shared) / float64(len(a)+len(b)-shared)
+}
+
+// LinkDensity scores chirps with more than MaxLinks links, chirps that are
+// little more than links and links posted by accounts younger than
+// NewAccountAge.
+type LinkDensity struct {
+	MaxLinks      int
+	NewAccountAge time.Duration
+}
+
+// minTextAroundLinks is how many letters and digits a chirp with links
+// needs outside them not to count as mostly links.
+const minTextAroundLinks = 10
+
+func (LinkDensity) Name() string { return "link_density" }
+
+func (l LinkDensity) Check(s Submission) (float64, string) {
+	found := links.Find(s.Body)
+	if len(found) == 0 {
+		return 0, ""
+	}
+
+	score := 0.0
+	var reasons []string
+	if len(found) > l.MaxLinks {
+		score++
+		reasons = append(reasons, fmt.Sprintf("%d links", len(found)))
+	}
+
+	text, prev := 0, 0
+	for _, e := range found {
+		text += alphanumerics(s.Body[prev:e.Start])
+		prev = e.End
+	}
+	text += alphanumerics(s.Body[prev:])
+	if text < minTextAroundLinks {
+		score += 0.5
+		reasons = append(reasons, "mostly links")
+	}
+
+	if s.AccountAge < l.NewAccountAge {
+		score += 0.5
+		reasons = append(reasons, "links from a new account")
+	}
+	return score, strings.Join(reasons, ", ")
+}
+
+func alphanumerics(s string) int {
+	n := 0
+	for _, c := range s {
+		if unicode.IsLetter(c) || unicode.IsDigit(c) {
+			n++
+		}
+	}
+	return n
+}
+
+// MentionFlood scores chirps mentioning more than MaxMentions users, and
+// rejects those mentioning more than twice as many.
+type MentionFlood struct {
+	MaxMentions int
+}
+
+func (MentionFlood) Name() string { return "mention_flood" }
+
+func (m MentionFlood) Check(s Submission) (float64, string) {
+	mentions := map[string]bool{}
+	for _, word := range strings.Fields(s.Body) {
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+		name := strings.ToLower(strings.TrimRight(word[1:], ".,:;!?)"))
+		if name != "" {
+			mentions[name] = true
+		}
+	}
+
+	n := len(mentions)
+	switch {
+	case n > 2*m.MaxMentions:
+		return 2, fmt.Sprintf("%d mentions", n)
+	case n > m.MaxMentions:
+		return 1, fmt.Sprintf("%d mentions", n)
+	}
+	return 0, ""
+}
+
+// Velocity rejects chirps from authors who have already posted PerHour
+// chirps in the last hour, or NewAccountPerHour if their account is
+// younger than NewAccountAge.
+type Velocity struct {
+	NewAccountAge     time.Duration
+	NewAccountPerHour int
+	PerHour           int
+}
+
+func (Velocity) Name() string { return "velocity" }
+
+func (v Velocity) Check(s Submission) (float64, string) {
+	limit := v.PerHour
+	if s.AccountAge < v.NewAccountAge {
+		limit = v.NewAccountPerHour
+	}
+
+	n := 0
+	for _, p := range s.Recent {
+		if s.Now.Sub(p.CreatedAt) <= time.Hour {
+			n++
+		}
+	}
+	if n < limit {
+		return 0, ""
+	}
+	return 2, fmt.Sprintf("already posted %d chirps in the last hour", n)
+}
+
+// Bayes scores chirps the classifier rates as likely spam. It does nothing
+// until the classifier has been trained enough.
+type Bayes struct {
+	Classifier *Classifier
+}
+
+func (Bayes) Name() string { return "bayes" }
+
+func (b Bayes) Check(s Submission) (float64, string) {
+	if b.Classifier == nil {
+		return 0, ""
+	}
+	p, ok := b.Classifier.SpamProbability(s.Body)
+	if !ok {
+		return 0, ""
+	}
+
+	reason := fmt.Sprintf("classified as spam with %.0f%% probability", 100*p)
+	switch {
+	case p >= 0.99:
+		return 1.5, reason
+	case p >= 0.9:
+		return 1, reason
+	}
+	return 0, ""
+}
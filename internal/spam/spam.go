@@ -0,0 +1,110 @@
+// Package spam scores chirps for spam and abuse. A Pipeline runs a set of
+// rules over a submission and turns their combined score into a verdict:
+// allow the chirp, hold it for a moderator to review or reject it.
+package spam
+
+import (
+	"time"
+)
+
+type Verdict string
+
+const (
+	Allow  Verdict = "allow"
+	Hold   Verdict = "hold"
+	Reject Verdict = "reject"
+)
+
+// Post is a chirp the author posted recently.
+type Post struct {
+	Body      string
+	CreatedAt time.Time
+}
+
+// Submission is a chirp about to be posted, with what is known about its
+// author.
+type Submission struct {
+	Body       string
+	AccountAge time.Duration
+	// Recent are the author's recent chirps, newest first.
+	Recent []Post
+	Now    time.Time
+}
+
+// Rule scores one aspect of a submission. A score of 0 means the rule has
+// nothing against it; reason explains a positive score.
+type Rule interface {
+	Name() string
+	Check(s Submission) (score float64, reason string)
+}
+
+// Signal is a rule that scored a submission.
+type Signal struct {
+	Rule   string
+	Score  float64
+	Reason string
+}
+
+type Result struct {
+	Verdict Verdict
+	Score   float64
+	Signals []Signal
+}
+
+// Reasons returns the reasons of the signals in r.
+func (r Result) Reasons() []string {
+	reasons := make([]string, len(r.Signals))
+	for i, s := range r.Signals {
+		reasons[i] = s.Reason
+	}
+	return reasons
+}
+
+// Pipeline adds up the scores of its rules. Submissions scoring at least
+// HoldAt are held, and those scoring at least RejectAt are rejected.
+type Pipeline struct {
+	Rules    []Rule
+	HoldAt   float64
+	RejectAt float64
+}
+
+// NewPipeline returns a pipeline with the default rules and thresholds.
+// Each rule scores 1 for something worth a moderator's look on its own and
+// 2 for something that is spam on its own.
+func NewPipeline(c *Classifier) *Pipeline {
+	return &Pipeline{
+		Rules: []Rule{
+			Duplicates{Threshold: 0.8, Window: 24 * time.Hour},
+			LinkDensity{MaxLinks: 3, NewAccountAge: 24 * time.Hour},
+			MentionFlood{MaxMentions: 5},
+			Velocity{
+				NewAccountAge:     24 * time.Hour,
+				NewAccountPerHour: 10,
+				PerHour:           60,
+			},
+			Bayes{Classifier: c},
+		},
+		HoldAt:   1,
+		RejectAt: 2,
+	}
+}
+
+func (p *Pipeline) Evaluate(s Submission) Result {
+	res := Result{Verdict: Allow}
+	for _, rule := range p.Rules {
+		score, reason := rule.Check(s)
+		if score <= 0 {
+			continue
+		}
+		res.Score += score
+		res.Signals = append(res.Signals, Signal{Rule: rule.Name(), Score: score, Reason: reason})
+	}
+
+	switch {
+	case res.Score >= p.RejectAt:
+		res.Verdict = Reject
+	case res.Score >= p.HoldAt:
+		res.Verdict = Hold
+	}
+	return res
+}
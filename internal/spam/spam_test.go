@@ -0,0 +1,164 @@
+package spam
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+)
+
+var now = time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func submission(body string) Submission {
+	return Submission{Body: body, AccountAge: 30 * 24 * time.Hour, Now: now}
+}
+
+func posts(body string, n int, ago time.Duration) []Post {
+	pp := make([]Post, n)
+	for i := range pp {
+		pp[i] = Post{Body: body, CreatedAt: now.Add(-ago)}
+	}
+	return pp
+}
+
+func TestEvaluateAllowsOrdinaryChirps(t *testing.T) {
+	s := submission("Had a lovely walk by the river this morning, see https://example.com/photos for pictures")
+	s.Recent = append(posts("Coffee first, then work", 3, time.Hour), posts("Anyone watching the game tonight?", 1, 2*time.Hour)...)
+
+	res := NewPipeline(NewClassifier()).Evaluate(s)
+	if res.Verdict != Allow {
+		t.Errorf("Verdict is %s, want allow: %+v", res.Verdict, res.Signals)
+	}
+}
+
+func TestDuplicates(t *testing.T) {
+	d := Duplicates{Threshold: 0.8, Window: 24 * time.Hour}
+
+	s := submission("BUY cheap followers now!!! Limited offer")
+	s.Recent = posts("buy cheap followers now - limited offer", 2, time.Hour)
+	s.Recent = append(s.Recent, posts("buy cheap followers now, limited offer", 5, 48*time.Hour)...)
+	s.Recent = append(s.Recent, posts("Something else entirely", 3, time.Hour)...)
+
+	score, _ := d.Check(s)
+	if score != 1 {
+		t.Errorf("Score is %v, want 1 for 2 near-duplicates in the window", score)
+	}
+
+	if j := jaccard(shingles("the quick brown fox"), shingles("a slow green turtle")); j > 0.2 {
+		t.Errorf("Unrelated chirps have similarity %v", j)
+	}
+
+	s = submission("👍")
+	s.Recent = posts("🎉🎉", 2, time.Hour)
+	s.Recent = append(s.Recent, posts("!!!", 2, time.Hour)...)
+	if score, reason := d.Check(s); score != 0 {
+		t.Errorf("Different emoji-only chirps scored %v: %s", score, reason)
+	}
+
+	s.Recent = append(s.Recent, posts(" 👍 ", 2, time.Hour)...)
+	if score, _ := d.Check(s); score != 1 {
+		t.Errorf("Score is %v, want 1 for the same emoji twice", score)
+	}
+}
+
+func TestLinkDensity(t *testing.T) {
+	l := LinkDensity{MaxLinks: 3, NewAccountAge: 24 * time.Hour}
+
+	score, _ := l.Check(submission("An article worth reading: https://example.com/a"))
+	if score != 0 {
+		t.Errorf("One link with text scored %v", score)
+	}
+
+	score, reason := l.Check(submission("https://a.example https://b.example https://c.example https://d.example"))
+	if score != 1.5 {
+		t.Errorf("Four bare links scored %v (%s), want 1.5", score, reason)
+	}
+
+	s := submission("An article worth reading: https://example.com/a")
+	s.AccountAge = time.Hour
+	score, _ = l.Check(s)
+	if score != 0.5 {
+		t.Errorf("A link from a new account scored %v, want 0.5", score)
+	}
+}
+
+func TestMentionFlood(t *testing.T) {
+	m := MentionFlood{MaxMentions: 5}
+	mentions := func(n int) string {
+		var b strings.Builder
+		for i := range n {
+			fmt.Fprintf(&b, "@user%d@example.com ", i)
+		}
+		return b.String() + "check this out"
+	}
+
+	for n, want := range map[int]float64{5: 0, 6: 1, 11: 2} {
+		if score, _ := m.Check(submission(mentions(n))); score != want {
+			t.Errorf("%d mentions scored %v, want %v", n, score, want)
+		}
+	}
+	if score, _ := m.Check(submission("@a @a @A @a @a @a @a")); score != 0 {
+		t.Errorf("Repeating one mention scored %v", score)
+	}
+}
+
+func TestVelocity(t *testing.T) {
+	v := Velocity{NewAccountAge: 24 * time.Hour, NewAccountPerHour: 10, PerHour: 60}
+
+	s := submission("hello")
+	s.Recent = append(posts("hi", 10, 30*time.Minute), posts("hi", 100, 2*time.Hour)...)
+	if score, _ := v.Check(s); score != 0 {
+		t.Errorf("10 chirps an hour from an established account scored %v", score)
+	}
+
+	s.AccountAge = time.Hour
+	if score, _ := v.Check(s); score != 2 {
+		t.Errorf("10 chirps an hour from a new account scored %v, want 2", score)
+	}
+}
+
+func TestClassifier(t *testing.T) {
+	c := NewClassifier()
+	if _, ok := c.SpamProbability("anything"); ok {
+		t.Fatal("Untrained classifier classified a chirp")
+	}
+
+	var examples []Example
+	for i := range MinDocuments {
+		examples = append(examples,
+			Example{Text: fmt.Sprintf("Win free crypto now at https://scam%d.example claim your prize", i), Spam: true},
+			Example{Text: fmt.Sprintf("Lunch with friends at the new place on %d street, great pasta", i), Spam: false},
+		)
+	}
+	c.Load(examples)
+
+	p, ok := c.SpamProbability("claim your free crypto prize")
+	if !ok || p < 0.99 {
+		t.Errorf("Spammy chirp has spam probability %v, %v", p, ok)
+	}
+	p, _ = c.SpamProbability("great pasta with friends")
+	if p > 0.01 {
+		t.Errorf("Ordinary chirp has spam probability %v", p)
+	}
+
+	c.Load(nil)
+	if _, ok := c.SpamProbability("claim your free crypto prize"); ok {
+		t.Error("Load didn't replace what the classifier had learned")
+	}
+}
+
+func TestEvaluateVerdicts(t *testing.T) {
+	p := NewPipeline(nil)
+
+	s := submission("@a @b @c @d @e @f look at my profile")
+	if res := p.Evaluate(s); res.Verdict != Hold || len(res.Reasons()) != 1 {
+		t.Errorf("Mention flood gave %+v, want one reason to hold", res)
+	}
+
+	s = submission("Follow me for more")
+	s.AccountAge = time.Minute
+	s.Recent = posts("Follow me for more", 10, time.Minute)
+	if res := p.Evaluate(s); res.Verdict != Reject {
+		t.Errorf("Repeated chirps from a new account gave %+v, want reject", res)
+	}
+}
@@ -0,0 +1,22 @@
+// Package storage keeps uploaded files. Local stores them on disk; S3 talks
+// to any S3-compatible object store.
+package storage
+
+import (
+	"context"
+	"errors"
+	"io"
+)
+
+var (
+	ErrNotFound   = errors.New("Object not found")
+	ErrInvalidKey = errors.New("Object key is invalid")
+)
+
+type Storage interface {
+	Put(ctx context.Context, key string, data []byte, contentType string) error
+	// Get returns the object's contents. The caller closes the reader.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Delete removes an object. Deleting a missing object isn't an error.
+	Delete(ctx context.Context, key string) error
+}
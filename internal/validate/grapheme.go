@@ -0,0 +1,151 @@
+package validate
+
+import "unicode"
+
+// Grapheme cluster break properties from UAX #29, as far as they matter
+// for counting. Prepend is left out; it only affects a handful of scripts
+// and at worst makes a cluster count as two.
+type breakProperty int
+
+const (
+	gbOther breakProperty = iota
+	gbCR
+	gbLF
+	gbControl
+	gbExtend
+	gbZWJ
+	gbRegionalIndicator
+	gbSpacingMark
+	gbL
+	gbV
+	gbT
+	gbLV
+	gbLVT
+	gbExtendedPictographic
+)
+
+var extendedPictographic = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{0x00A9, 0x00A9, 1}, {0x00AE, 0x00AE, 1}, {0x203C, 0x203C, 1},
+		{0x2049, 0x2049, 1}, {0x2122, 0x2122, 1}, {0x2139, 0x2139, 1},
+		{0x2194, 0x2199, 1}, {0x21A9, 0x21AA, 1}, {0x231A, 0x231B, 1},
+		{0x2328, 0x2328, 1}, {0x2388, 0x2388, 1}, {0x23CF, 0x23CF, 1},
+		{0x23E9, 0x23F3, 1}, {0x23F8, 0x23FA, 1}, {0x24C2, 0x24C2, 1},
+		{0x25AA, 0x25AB, 1}, {0x25B6, 0x25B6, 1}, {0x25C0, 0x25C0, 1},
+		{0x25FB, 0x25FE, 1}, {0x2600, 0x27BF, 1}, {0x2934, 0x2935, 1},
+		{0x2B05, 0x2B07, 1}, {0x2B1B, 0x2B1C, 1}, {0x2B50, 0x2B50, 1},
+		{0x2B55, 0x2B55, 1}, {0x3030, 0x3030, 1}, {0x303D, 0x303D, 1},
+		{0x3297, 0x3297, 1}, {0x3299, 0x3299, 1},
+	},
+	R32: []unicode.Range32{
+		{0x1F000, 0x1F1E5, 1}, {0x1F200, 0x1F3FA, 1}, {0x1F400, 0x1FAFF, 1},
+		{0x1FC00, 0x1FFFD, 1},
+	},
+}
+
+func property(r rune) breakProperty {
+	switch {
+	case r == '\r':
+		return gbCR
+	case r == '\n':
+		return gbLF
+	case r == 0x200D:
+		return gbZWJ
+	case r == 0x200C:
+		return gbExtend
+	case r >= 0x1F1E6 && r <= 0x1F1FF:
+		return gbRegionalIndicator
+	case r >= 0x1F3FB && r <= 0x1F3FF, r >= 0xE0020 && r <= 0xE007F:
+		// Emoji skin tone modifiers and tag characters.
+		return gbExtend
+	case r >= 0x1100 && r <= 0x115F, r >= 0xA960 && r <= 0xA97C:
+		return gbL
+	case r >= 0x1160 && r <= 0x11A7, r >= 0xD7B0 && r <= 0xD7C6:
+		return gbV
+	case r >= 0x11A8 && r <= 0x11FF, r >= 0xD7CB && r <= 0xD7FB:
+		return gbT
+	case r >= 0xAC00 && r <= 0xD7A3:
+		if (r-0xAC00)%28 == 0 {
+			return gbLV
+		}
+		return gbLVT
+	case unicode.In(r, unicode.Mn, unicode.Me):
+		return gbExtend
+	case unicode.Is(unicode.Mc, r):
+		return gbSpacingMark
+	case unicode.In(r, unicode.Cc, unicode.Cf, unicode.Zl, unicode.Zp):
+		return gbControl
+	case unicode.Is(extendedPictographic, r):
+		return gbExtendedPictographic
+	}
+	return gbOther
+}
+
+// Graphemes counts the user-perceived characters (extended grapheme
+// clusters) in s, so "é" written with a combining accent, a flag and a
+// family emoji each count as one.
+func Graphemes(s string) int {
+	n := 0
+	prev := gbOther
+	// emojiZWJ is set while in an Extended_Pictographic Extend* ZWJ
+	// sequence, which the next pictograph joins (GB11).
+	inPictographic, emojiZWJ := false, false
+	// riCount is the number of regional indicators in a row (GB12/13).
+	riCount := 0
+	first := true
+
+	for _, r := range s {
+		cur := property(r)
+		if first || breaks(prev, cur, emojiZWJ, riCount) {
+			n++
+		}
+		first = false
+
+		switch cur {
+		case gbExtendedPictographic:
+			inPictographic = true
+			emojiZWJ = false
+		case gbExtend:
+			emojiZWJ = false
+		case gbZWJ:
+			emojiZWJ = inPictographic
+			inPictographic = false
+		default:
+			inPictographic, emojiZWJ = false, false
+		}
+		if cur == gbRegionalIndicator {
+			riCount++
+		} else {
+			riCount = 0
+		}
+		prev = cur
+	}
+	return n
+}
+
+// breaks reports whether there is a cluster boundary between runes with
+// properties prev and cur.
+func breaks(prev, cur breakProperty, emojiZWJ bool, riCount int) bool {
+	switch {
+	case prev == gbCR && cur == gbLF:
+		return false
+	case prev == gbCR, prev == gbLF, prev == gbControl:
+		return true
+	case cur == gbCR, cur == gbLF, cur == gbControl:
+		return true
+	case prev == gbL && (cur == gbL || cur == gbV || cur == gbLV || cur == gbLVT):
+		return false
+	case (prev == gbLV || prev == gbV) && (cur == gbV || cur == gbT):
+		return false
+	case (prev == gbLVT || prev == gbT) && cur == gbT:
+		return false
+	case cur == gbExtend, cur == gbZWJ, cur == gbSpacingMark:
+		return false
+	case prev == gbZWJ && cur == gbExtendedPictographic && emojiZWJ:
+		return false
+	case prev == gbRegionalIndicator && cur == gbRegionalIndicator:
+		// Flags are pairs of regional indicators.
+		return riCount%2 == 0
+	}
+	return true
+}
@@ -0,0 +1,153 @@
+// Package validate checks text submitted by users: it normalises it to NFC,
+// measures it in user-perceived characters and reports problems as
+// field-level errors that can be shown next to the field they are about.
+package validate
+
+import (
+	"fmt"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// Error codes, stable for clients to match on.
+const (
+	CodeRequired          = "required"
+	CodeTooLong           = "too_long"
+	CodeInvalidCharacters = "invalid_characters"
+	CodeInvalid           = "invalid"
+)
+
+// FieldError is a problem with one field of a request.
+type FieldError struct {
+	Field   string `json:"field"`
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *FieldError) Error() string {
+	return e.Message
+}
+
+// Errorf returns a FieldError with code CodeInvalid, for checks that don't
+// fit the others.
+func Errorf(field, format string, args ...any) *FieldError {
+	return &FieldError{Field: field, Code: CodeInvalid, Message: fmt.Sprintf(format, args...)}
+}
+
+// Errors collects the problems with a request.
+type Errors []*FieldError
+
+func (e Errors) Error() string {
+	msgs := make([]string, len(e))
+	for i, fe := range e {
+		msgs[i] = fe.Message
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Add records err if it is a *FieldError and reports whether it was. Other
+// errors, including nil, are left for the caller.
+func (e *Errors) Add(err error) bool {
+	fe, ok := err.(*FieldError)
+	if ok {
+		*e = append(*e, fe)
+	}
+	return ok
+}
+
+// Err returns e as an error, or nil if it is empty.
+func (e Errors) Err() error {
+	if len(e) == 0 {
+		return nil
+	}
+	return e
+}
+
+// Rules are the checks Text applies.
+type Rules struct {
+	Required bool
+	// MaxLength is the most characters the text may have, measured by
+	// Length. Zero means no limit.
+	MaxLength int
+	// Length measures the text. It defaults to Graphemes.
+	Length func(string) int
+	// MaxBytes is the most bytes the text may have after normalisation.
+	// It bounds text that is short in characters but long in bytes, such
+	// as a letter with thousands of combining marks. Zero means no limit.
+	MaxBytes int
+	// Multiline allows newlines and tabs.
+	Multiline bool
+}
+
+// Text normalises value to NFC, with Windows line endings turned into
+// newlines, and checks it against r. It returns the normalised text.
+// Failures are *FieldError.
+func Text(field, value string, r Rules) (string, error) {
+	if !utf8.ValidString(value) {
+		return "", &FieldError{Field: field, Code: CodeInvalidCharacters, Message: field + " must be valid UTF-8"}
+	}
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	value = norm.NFC.String(value)
+
+	if r.Required && Blank(value) {
+		return "", &FieldError{Field: field, Code: CodeRequired, Message: field + " can't be empty"}
+	}
+
+	for _, c := range value {
+		if (c == '\n' || c == '\t') && r.Multiline {
+			continue
+		}
+		if forbidden(c) {
+			return "", &FieldError{
+				Field:   field,
+				Code:    CodeInvalidCharacters,
+				Message: fmt.Sprintf("%s can't contain the control character %U", field, c),
+			}
+		}
+	}
+
+	if r.MaxBytes > 0 && len(value) > r.MaxBytes {
+		return "", &FieldError{
+			Field:   field,
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("%s must be at most %d bytes", field, r.MaxBytes),
+		}
+	}
+
+	length := r.Length
+	if length == nil {
+		length = Graphemes
+	}
+	if r.MaxLength > 0 && length(value) > r.MaxLength {
+		return "", &FieldError{
+			Field:   field,
+			Code:    CodeTooLong,
+			Message: fmt.Sprintf("%s must be at most %d characters", field, r.MaxLength),
+		}
+	}
+
+	return value, nil
+}
+
+// Blank reports whether s has nothing visible in it: only whitespace and
+// invisible formatting characters such as zero-width spaces.
+func Blank(s string) bool {
+	return strings.TrimFunc(s, func(c rune) bool {
+		return unicode.IsSpace(c) || unicode.Is(unicode.Cf, c) || c == 0x2800
+	}) == ""
+}
+
+// forbidden reports whether c is a control character, or a bidirectional
+// override that could make text display differently from how it reads.
+func forbidden(c rune) bool {
+	switch {
+	case unicode.Is(unicode.Cc, c):
+		return true
+	case c >= 0x202A && c <= 0x202E, c >= 0x2066 && c <= 0x2069:
+		return true
+	}
+	return false
+}
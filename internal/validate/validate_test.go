@@ -0,0 +1,125 @@
+package validate
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestGraphemes(t *testing.T) {
+	tests := []struct {
+		name string
+		s    string
+		want int
+	}{
+		{"ascii", "hello", 5},
+		{"empty", "", 0},
+		{"combining accent", "e\u0301te\u0301", 3},
+		{"crlf", "a\r\nb", 3},
+		{"emoji", "😀😀😀", 3},
+		{"skin tone", "👍🏽", 1},
+		{"zwj family", "👨‍👩‍👧‍👦", 1},
+		{"variation selector", "❤️", 1},
+		{"flags", "🇬🇧🇫🇷🇯", 3},
+		{"hangul jamo", "한", 1},
+		{"hangul syllables", "한국어", 3},
+		{"devanagari spacing mark", "कि", 1},
+		{"tag sequence", "🏴\U000E0067\U000E0062\U000E0065\U000E006E\U000E0067\U000E007F", 1},
+	}
+	for _, tt := range tests {
+		if got := Graphemes(tt.s); got != tt.want {
+			t.Errorf("%s: Graphemes(%q) = %d, want %d", tt.name, tt.s, got, tt.want)
+		}
+	}
+}
+
+func TestTextNormalizes(t *testing.T) {
+	got, err := Text("body", "Cafe\u0301\r\nbar", Rules{Multiline: true})
+	if err != nil {
+		t.Fatalf("Unexpected error: %v", err)
+	}
+	if got != "Caf\u00e9\nbar" {
+		t.Errorf("Text returned %q, want NFC with a plain newline", got)
+	}
+}
+
+func TestTextLength(t *testing.T) {
+	_, err := Text("body", strings.Repeat("😀", 140), Rules{MaxLength: 140})
+	if err != nil {
+		t.Errorf("140 emoji were rejected: %v", err)
+	}
+
+	_, err = Text("body", strings.Repeat("a", 141), Rules{MaxLength: 140})
+	fe := &FieldError{}
+	if !errors.As(err, &fe) || fe.Code != CodeTooLong || fe.Field != "body" {
+		t.Errorf("141 characters returned %v, want a too_long error for body", err)
+	}
+}
+
+func TestTextMaxBytes(t *testing.T) {
+	// One grapheme of a letter and many combining marks, which NFC can't
+	// fold into a single code point.
+	zalgo := "a" + strings.Repeat("\u0301\u0302", 50000)
+	_, err := Text("body", zalgo, Rules{MaxLength: 140, MaxBytes: 560})
+	fe := &FieldError{}
+	if !errors.As(err, &fe) || fe.Code != CodeTooLong {
+		t.Errorf("%d bytes returned %v, want a too_long error", len(zalgo), err)
+	}
+
+	_, err = Text("body", strings.Repeat("😀", 140), Rules{MaxLength: 140, MaxBytes: 560})
+	if err != nil {
+		t.Errorf("140 emoji were rejected: %v", err)
+	}
+}
+
+func TestTextRejects(t *testing.T) {
+	tests := []struct {
+		name  string
+		value string
+		rules Rules
+		code  string
+	}{
+		{"empty", "", Rules{Required: true}, CodeRequired},
+		{"whitespace", " \n\t ", Rules{Required: true, Multiline: true}, CodeRequired},
+		{"zero width", "\u200b\u200b", Rules{Required: true}, CodeRequired},
+		{"nul", "a\x00b", Rules{}, CodeInvalidCharacters},
+		{"escape", "\x1b[31mred", Rules{}, CodeInvalidCharacters},
+		{"newline when single line", "a\nb", Rules{}, CodeInvalidCharacters},
+		{"bidi override", "abc\u202edcba", Rules{}, CodeInvalidCharacters},
+		{"invalid utf-8", "a\xffb", Rules{}, CodeInvalidCharacters},
+	}
+	for _, tt := range tests {
+		_, err := Text("body", tt.value, tt.rules)
+		fe := &FieldError{}
+		if !errors.As(err, &fe) || fe.Code != tt.code {
+			t.Errorf("%s: got %v, want code %s", tt.name, err, tt.code)
+		}
+	}
+
+	if _, err := Text("body", "", Rules{}); err != nil {
+		t.Errorf("Optional empty text was rejected: %v", err)
+	}
+}
+
+func TestErrors(t *testing.T) {
+	errs := Errors{}
+	if errs.Err() != nil {
+		t.Errorf("Empty Errors isn't nil")
+	}
+
+	_, err := Text("body", "", Rules{Required: true})
+	if !errs.Add(err) {
+		t.Errorf("FieldError wasn't added")
+	}
+	if errs.Add(nil) || errs.Add(errors.New("other")) {
+		t.Errorf("Non-field error was added")
+	}
+	errs.Add(Errorf("visibility", "visibility is unknown"))
+
+	if len(errs) != 2 || errs.Err() == nil {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+	if errs.Error() != "body can't be empty; visibility is unknown" {
+		t.Errorf("Unexpected message %q", errs.Error())
+	}
+}
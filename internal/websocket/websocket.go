@@ -0,0 +1,342 @@
+// Package websocket implements the server side of the WebSocket protocol
+// (RFC 6455) on top of net/http.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	continuationFrame = 0
+	TextMessage       = 1
+	BinaryMessage     = 2
+	CloseMessage      = 8
+	PingMessage       = 9
+	PongMessage       = 10
+)
+
+const (
+	CloseNormalClosure   = 1000
+	CloseGoingAway       = 1001
+	CloseProtocolError   = 1002
+	CloseInvalidPayload  = 1007
+	ClosePolicyViolation = 1008
+	CloseMessageTooBig   = 1009
+	CloseTryAgainLater   = 1013
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+var ErrMessageTooBig = errors.New("websocket: message too big")
+
+// CloseError is returned by ReadMessage when the peer closes the connection.
+type CloseError struct {
+	Code int
+	Text string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket: close %d %s", e.Code, e.Text)
+}
+
+type Conn struct {
+	conn      net.Conn
+	br        *bufio.Reader
+	readLimit int64
+
+	writeMu sync.Mutex
+	// closeSent is set once a close frame is written, as the protocol
+	// allows only one. It's guarded by writeMu.
+	closeSent bool
+
+	pongHandler func()
+}
+
+// AcceptKey computes the Sec-WebSocket-Accept value for a client key.
+func AcceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContains(headers http.Header, name, token string) bool {
+	for _, v := range headers.Values(name) {
+		for _, t := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Upgrade performs the opening handshake and takes over the connection. On
+// failure an HTTP error has already been written to w.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "Method not allowed", 405)
+		return nil, fmt.Errorf("websocket: method %s not allowed", r.Method)
+	}
+	if !headerContains(r.Header, "Connection", "upgrade") || !headerContains(r.Header, "Upgrade", "websocket") {
+		http.Error(w, "Not a websocket handshake", 400)
+		return nil, fmt.Errorf("websocket: missing upgrade headers")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "Unsupported websocket version", 426)
+		return nil, fmt.Errorf("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "Missing Sec-WebSocket-Key", 400)
+		return nil, fmt.Errorf("websocket: missing key")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "Websocket unsupported", 500)
+		return nil, fmt.Errorf("websocket: response does not support hijacking")
+	}
+	netConn, brw, err := hj.Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = fmt.Fprintf(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: websocket\r\nConnection: Upgrade\r\nSec-WebSocket-Accept: %s\r\n\r\n", AcceptKey(key))
+	if err == nil {
+		err = brw.Flush()
+	}
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return newConn(netConn, brw.Reader), nil
+}
+
+func newConn(netConn net.Conn, br *bufio.Reader) *Conn {
+	if br == nil {
+		br = bufio.NewReader(netConn)
+	}
+	return &Conn{
+		conn:      netConn,
+		br:        br,
+		readLimit: 1 << 20,
+	}
+}
+
+// SetReadLimit sets the maximum size of a message read from the peer.
+func (c *Conn) SetReadLimit(limit int64) {
+	c.readLimit = limit
+}
+
+// SetPongHandler sets a function called whenever a pong frame is received.
+func (c *Conn) SetPongHandler(h func()) {
+	c.pongHandler = h
+}
+
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+func (c *Conn) SetWriteDeadline(t time.Time) error {
+	return c.conn.SetWriteDeadline(t)
+}
+
+func (c *Conn) Close() error {
+	return c.conn.Close()
+}
+
+type frame struct {
+	fin     bool
+	opcode  int
+	payload []byte
+}
+
+func (c *Conn) readFrame(limit int64) (frame, error) {
+	var head [2]byte
+	_, err := io.ReadFull(c.br, head[:])
+	if err != nil {
+		return frame{}, err
+	}
+
+	f := frame{
+		fin:    head[0]&0x80 != 0,
+		opcode: int(head[0] & 0x0f),
+	}
+	if head[0]&0x70 != 0 {
+		return frame{}, c.fail(CloseProtocolError, "reserved bits set")
+	}
+	if head[1]&0x80 == 0 {
+		return frame{}, c.fail(CloseProtocolError, "client frames must be masked")
+	}
+
+	length := int64(head[1] & 0x7f)
+	switch length {
+	case 126:
+		var ext [2]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		length = int64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		_, err = io.ReadFull(c.br, ext[:])
+		length = int64(binary.BigEndian.Uint64(ext[:]))
+	}
+	if err != nil {
+		return frame{}, err
+	}
+
+	if f.opcode >= CloseMessage && (length > 125 || !f.fin) {
+		return frame{}, c.fail(CloseProtocolError, "invalid control frame")
+	}
+	if length < 0 || length > limit {
+		return frame{}, c.fail(CloseMessageTooBig, "message too big")
+	}
+
+	var mask [4]byte
+	_, err = io.ReadFull(c.br, mask[:])
+	if err != nil {
+		return frame{}, err
+	}
+	f.payload = make([]byte, length)
+	_, err = io.ReadFull(c.br, f.payload)
+	if err != nil {
+		return frame{}, err
+	}
+	for i := range f.payload {
+		f.payload[i] ^= mask[i%4]
+	}
+
+	return f, nil
+}
+
+// fail sends a close frame to the peer and returns the matching error.
+func (c *Conn) fail(code int, text string) error {
+	c.WriteClose(code, text)
+	if code == CloseMessageTooBig {
+		return ErrMessageTooBig
+	}
+	return fmt.Errorf("websocket: %s", text)
+}
+
+// ReadMessage returns the next text or binary message. Ping, pong and close
+// frames are handled internally; a close from the peer is answered and
+// returned as a *CloseError.
+func (c *Conn) ReadMessage() (int, []byte, error) {
+	messageType := 0
+	var message []byte
+
+	for {
+		f, err := c.readFrame(c.readLimit - int64(len(message)))
+		if err != nil {
+			return 0, nil, err
+		}
+
+		switch f.opcode {
+		case PingMessage:
+			err = c.WriteMessage(PongMessage, f.payload)
+			if err != nil {
+				return 0, nil, err
+			}
+			continue
+		case PongMessage:
+			if c.pongHandler != nil {
+				c.pongHandler()
+			}
+			continue
+		case CloseMessage:
+			ce := &CloseError{Code: CloseNormalClosure}
+			if len(f.payload) == 1 {
+				return 0, nil, c.fail(CloseProtocolError, "invalid close frame")
+			}
+			if len(f.payload) >= 2 {
+				if !utf8.Valid(f.payload[2:]) {
+					return 0, nil, c.fail(CloseInvalidPayload, "invalid UTF-8 in close reason")
+				}
+				ce.Code = int(binary.BigEndian.Uint16(f.payload))
+				ce.Text = string(f.payload[2:])
+			}
+			c.WriteClose(ce.Code, "")
+			return 0, nil, ce
+		case TextMessage, BinaryMessage:
+			if messageType != 0 {
+				return 0, nil, c.fail(CloseProtocolError, "expected continuation frame")
+			}
+			messageType = f.opcode
+		case continuationFrame:
+			if messageType == 0 {
+				return 0, nil, c.fail(CloseProtocolError, "unexpected continuation frame")
+			}
+		default:
+			return 0, nil, c.fail(CloseProtocolError, "unknown opcode")
+		}
+
+		message = append(message, f.payload...)
+		if f.fin {
+			if messageType == TextMessage && !utf8.Valid(message) {
+				return 0, nil, c.fail(CloseInvalidPayload, "invalid UTF-8 in text message")
+			}
+			return messageType, message, nil
+		}
+	}
+}
+
+// WriteMessage writes a single unfragmented frame. It is safe to call from
+// multiple goroutines.
+func (c *Conn) WriteMessage(messageType int, data []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	return c.writeFrame(messageType, data)
+}
+
+func (c *Conn) writeFrame(messageType int, data []byte) error {
+	header := []byte{0x80 | byte(messageType), 0}
+	switch {
+	case len(data) <= 125:
+		header[1] = byte(len(data))
+	case len(data) <= 0xffff:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(len(data)))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(len(data)))
+	}
+
+	_, err := c.conn.Write(append(header, data...))
+	return err
+}
+
+// WriteClose sends a close frame with the given status code, unless one was
+// already sent, e.g. in answer to the peer's.
+func (c *Conn) WriteClose(code int, text string) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closeSent {
+		return nil
+	}
+	c.closeSent = true
+
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	if len(text) > 123 {
+		// Don't cut a multi-byte character in half.
+		n := 123
+		for n > 0 && text[n]&0xC0 == 0x80 {
+			n--
+		}
+		text = text[:n]
+	}
+	payload = append(payload, text...)
+	return c.writeFrame(CloseMessage, payload)
+}
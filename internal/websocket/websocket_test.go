@@ -0,0 +1,165 @@
+package websocket
+
+import (
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"testing"
+)
+
+func clientFrame(fin bool, opcode int, payload []byte) []byte {
+	b0 := byte(opcode)
+	if fin {
+		b0 |= 0x80
+	}
+	f := []byte{b0}
+	switch {
+	case len(payload) <= 125:
+		f = append(f, 0x80|byte(len(payload)))
+	default:
+		f = append(f, 0x80|126)
+		f = binary.BigEndian.AppendUint16(f, uint16(len(payload)))
+	}
+	mask := []byte{1, 2, 3, 4}
+	f = append(f, mask...)
+	for i, b := range payload {
+		f = append(f, b^mask[i%4])
+	}
+	return f
+}
+
+func TestAcceptKey(t *testing.T) {
+	// Example from RFC 6455 section 1.3.
+	if got := AcceptKey("dGhlIHNhbXBsZSBub25jZQ=="); got != "s3pPLMBiTxaQ9kYGzzhZRbK+xOo=" {
+		t.Errorf("Wrong accept key: %s", got)
+	}
+}
+
+func TestReadFragmentedMessage(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	c := newConn(server, nil)
+
+	go func() {
+		client.Write(clientFrame(false, TextMessage, []byte("hello ")))
+		client.Write(clientFrame(true, continuationFrame, []byte("world")))
+	}()
+
+	mt, msg, err := c.ReadMessage()
+	if err != nil {
+		t.Fatalf("Can't read message: %v", err)
+	}
+	if mt != TextMessage || string(msg) != "hello world" {
+		t.Errorf("Wrong message: %d %q", mt, msg)
+	}
+}
+
+func TestPingAnsweredWithPong(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	c := newConn(server, nil)
+
+	go c.ReadMessage()
+	client.Write(clientFrame(true, PingMessage, []byte("hi")))
+
+	reply := make([]byte, 4)
+	_, err := io.ReadFull(client, reply)
+	if err != nil {
+		t.Fatalf("Can't read pong: %v", err)
+	}
+	if reply[0] != 0x80|PongMessage || reply[1] != 2 || string(reply[2:]) != "hi" {
+		t.Errorf("Wrong pong frame: %v", reply)
+	}
+}
+
+func TestReadLimit(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	c := newConn(server, nil)
+	c.SetReadLimit(8)
+
+	go func() {
+		client.Write(clientFrame(true, TextMessage, []byte("this is too long")))
+		io.Copy(io.Discard, client)
+	}()
+
+	_, _, err := c.ReadMessage()
+	if !errors.Is(err, ErrMessageTooBig) {
+		t.Errorf("Expected ErrMessageTooBig, got %v", err)
+	}
+}
+
+func TestUnmaskedFrameRejected(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	c := newConn(server, nil)
+
+	go func() {
+		client.Write([]byte{0x80 | TextMessage, 1, 'a'})
+		io.Copy(io.Discard, client)
+	}()
+
+	_, _, err := c.ReadMessage()
+	if err == nil {
+		t.Errorf("Expected error for unmasked frame")
+	}
+}
+
+func TestInvalidUTF8Rejected(t *testing.T) {
+	server, client := net.Pipe()
+	defer server.Close()
+	c := newConn(server, nil)
+
+	go client.Write(clientFrame(true, TextMessage, []byte("caf\xe9")))
+	reply := make(chan []byte, 1)
+	go func() {
+		b := make([]byte, 4)
+		io.ReadFull(client, b)
+		reply <- b
+		io.Copy(io.Discard, client)
+	}()
+
+	_, _, err := c.ReadMessage()
+	if err == nil {
+		t.Fatalf("Expected error for invalid UTF-8")
+	}
+	b := <-reply
+	if b[0] != 0x80|CloseMessage || binary.BigEndian.Uint16(b[2:]) != CloseInvalidPayload {
+		t.Errorf("Expected a close frame with code 1007, got %v", b)
+	}
+
+	// Binary messages can hold any bytes.
+	server, client = net.Pipe()
+	defer server.Close()
+	c = newConn(server, nil)
+	go client.Write(clientFrame(true, BinaryMessage, []byte("caf\xe9")))
+	if _, _, err := c.ReadMessage(); err != nil {
+		t.Errorf("Binary message was rejected: %v", err)
+	}
+}
+
+func TestCloseSentOnce(t *testing.T) {
+	server, client := net.Pipe()
+	c := newConn(server, nil)
+
+	go client.Write(clientFrame(true, CloseMessage, binary.BigEndian.AppendUint16(nil, CloseGoingAway)))
+	received := make(chan []byte)
+	go func() {
+		b, _ := io.ReadAll(client)
+		received <- b
+	}()
+
+	_, _, err := c.ReadMessage()
+	ce := &CloseError{}
+	if !errors.As(err, &ce) || ce.Code != CloseGoingAway {
+		t.Fatalf("Expected a CloseError with code 1001, got %v", err)
+	}
+	c.WriteClose(CloseNormalClosure, "")
+	c.Close()
+
+	b := <-received
+	if len(b) != 4 || b[0] != 0x80|CloseMessage {
+		t.Errorf("Expected a single close frame, got %v", b)
+	}
+}
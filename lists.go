@@ -0,0 +1,417 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	maxListNameLength = 50
+	maxListsPerUser   = 100
+	maxListMembers    = 500
+)
+
+var errInvalidListName = errors.New("List name must be between 1 and 50 characters")
+
+type List struct {
+	ID        uuid.UUID `json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	OwnerID   uuid.UUID `json:"owner_id"`
+	Name      string    `json:"name"`
+	Public    bool      `json:"public"`
+}
+
+func listJSON(l database.List) List {
+	return List{
+		ID:        l.ID,
+		CreatedAt: l.CreatedAt,
+		UpdatedAt: l.UpdatedAt,
+		OwnerID:   l.OwnerID,
+		Name:      l.Name,
+		Public:    l.IsPublic,
+	}
+}
+
+type listRequest struct {
+	Name   string `json:"name"`
+	Public bool   `json:"public"`
+}
+
+func (b *listRequest) validate() error {
+	b.Name = strings.TrimSpace(b.Name)
+	if b.Name == "" || utf8.RuneCountInString(b.Name) > maxListNameLength {
+		return errInvalidListName
+	}
+	return nil
+}
+
+// visibleList returns the list in the path if viewerID owns it or it is
+// public. Private lists look the same as missing ones to everyone else.
+func (cfg *APIConfig) visibleList(w http.ResponseWriter, r *http.Request, viewerID uuid.UUID) (database.List, bool) {
+	listID, err := uuid.Parse(r.PathValue("listID"))
+	if err != nil {
+		respondError(w, "Can't parse listID", 400, err)
+		return database.List{}, false
+	}
+
+	l, err := cfg.db.GetList(r.Context(), listID)
+	if err != nil {
+		respondError(w, "List not found", 404, err)
+		return database.List{}, false
+	}
+	if !l.IsPublic && l.OwnerID != viewerID {
+		respondError(w, "List not found", 404, nil)
+		return database.List{}, false
+	}
+
+	return l, true
+}
+
+// ownedList authenticates the request and returns the list in the path if
+// the user owns it.
+func (cfg *APIConfig) ownedList(w http.ResponseWriter, r *http.Request) (database.List, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return database.List{}, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return database.List{}, false
+	}
+
+	l, ok := cfg.visibleList(w, r, userID)
+	if !ok {
+		return database.List{}, false
+	}
+	if l.OwnerID != userID {
+		respondError(w, "User is not owner of list", 403, nil)
+		return database.List{}, false
+	}
+
+	return l, true
+}
+
+func (cfg *APIConfig) createListHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := listRequest{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	err = b.validate()
+	if err != nil {
+		respondError(w, err.Error(), 400, err)
+		return
+	}
+
+	count, err := cfg.db.CountUserLists(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't count lists", 500, err)
+		return
+	}
+	if count >= maxListsPerUser {
+		respondError(w, "You can have at most 100 lists", 400, nil)
+		return
+	}
+
+	l, err := cfg.db.CreateList(r.Context(), database.CreateListParams{
+		OwnerID:  userID,
+		Name:     b.Name,
+		IsPublic: b.Public,
+	})
+	if err != nil {
+		respondError(w, "Can't create list", 500, err)
+		return
+	}
+
+	respondJSON(w, 201, listJSON(l))
+}
+
+func (cfg *APIConfig) getMyListsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	lists, err := cfg.db.GetUserLists(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get lists", 500, err)
+		return
+	}
+
+	ll := make([]List, len(lists))
+	for i, l := range lists {
+		ll[i] = listJSON(l)
+	}
+
+	respondJSON(w, 200, ll)
+}
+
+func (cfg *APIConfig) getListHandler(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	l, ok := cfg.visibleList(w, r, viewerID)
+	if !ok {
+		return
+	}
+
+	respondJSON(w, 200, listJSON(l))
+}
+
+// updateListHandler renames a list and sets whether it is public.
+func (cfg *APIConfig) updateListHandler(w http.ResponseWriter, r *http.Request) {
+	l, ok := cfg.ownedList(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := listRequest{}
+	err := decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	err = b.validate()
+	if err != nil {
+		respondError(w, err.Error(), 400, err)
+		return
+	}
+
+	l, err = cfg.db.UpdateList(r.Context(), database.UpdateListParams{
+		Name:     b.Name,
+		IsPublic: b.Public,
+		ID:       l.ID,
+		OwnerID:  l.OwnerID,
+	})
+	if err != nil {
+		respondError(w, "Can't update list", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, listJSON(l))
+}
+
+func (cfg *APIConfig) deleteListHandler(w http.ResponseWriter, r *http.Request) {
+	l, ok := cfg.ownedList(w, r)
+	if !ok {
+		return
+	}
+
+	_, err := cfg.db.DeleteList(r.Context(), database.DeleteListParams{
+		ID:      l.ID,
+		OwnerID: l.OwnerID,
+	})
+	if err != nil {
+		respondError(w, "Can't delete list", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) getListMembersHandler(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	l, ok := cfg.visibleList(w, r, viewerID)
+	if !ok {
+		return
+	}
+
+	members, err := cfg.db.GetListMembers(r.Context(), l.ID)
+	if err != nil {
+		respondError(w, "Can't get list members", 500, err)
+		return
+	}
+
+	mm := make([]Relationship, len(members))
+	for i, m := range members {
+		mm[i] = Relationship{UserID: m.UserID, CreatedAt: m.CreatedAt}
+	}
+
+	respondJSON(w, 200, mm)
+}
+
+func (cfg *APIConfig) addListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		UserID uuid.UUID `json:"user_id"`
+	}
+
+	l, ok := cfg.ownedList(w, r)
+	if !ok {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err := decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+
+	_, err = cfg.db.GetUserByID(r.Context(), b.UserID)
+	if err != nil {
+		respondError(w, "Can't get user", 404, err)
+		return
+	}
+
+	count, err := cfg.db.CountListMembers(r.Context(), l.ID)
+	if err != nil {
+		respondError(w, "Can't count list members", 500, err)
+		return
+	}
+	if count >= maxListMembers {
+		respondError(w, "A list can have at most 500 members", 400, nil)
+		return
+	}
+
+	err = cfg.db.AddListMember(r.Context(), database.AddListMemberParams{
+		ListID: l.ID,
+		UserID: b.UserID,
+	})
+	if err != nil {
+		respondError(w, "Can't add list member", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) removeListMemberHandler(w http.ResponseWriter, r *http.Request) {
+	l, ok := cfg.ownedList(w, r)
+	if !ok {
+		return
+	}
+
+	userID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, "Can't parse userID", 400, err)
+		return
+	}
+
+	n, err := cfg.db.RemoveListMember(r.Context(), database.RemoveListMemberParams{
+		ListID: l.ID,
+		UserID: userID,
+	})
+	if err != nil {
+		respondError(w, "Can't remove list member", 500, err)
+		return
+	}
+	if n == 0 {
+		respondError(w, "User is not a member of the list", 404, nil)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// getListTimelineHandler returns the chirps of a list's members, newest
+// first.
+func (cfg *APIConfig) getListTimelineHandler(w http.ResponseWriter, r *http.Request) {
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	l, ok := cfg.visibleList(w, r, viewerID)
+	if !ok {
+		return
+	}
+
+	limit := 20
+	if v := r.URL.Query().Get("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil || limit < 1 || limit > 100 {
+			respondError(w, "limit must be between 1 and 100", 400, err)
+			return
+		}
+	}
+
+	before := time.Now().Add(time.Minute)
+	if b := r.URL.Query().Get("before"); b != "" {
+		before, err = time.Parse(time.RFC3339Nano, b)
+		if err != nil {
+			respondError(w, "Can't parse before", 400, err)
+			return
+		}
+	}
+
+	chirps, err := cfg.db.GetListTimeline(r.Context(), database.GetListTimelineParams{
+		ListID:    l.ID,
+		Before:    before,
+		ViewerID:  viewerID,
+		MaxChirps: int32(limit),
+	})
+	if err != nil {
+		respondError(w, "Can't get list timeline", 500, err)
+		return
+	}
+
+	type response struct {
+		Chirps     []Chirp `json:"chirps"`
+		NextCursor string  `json:"next_cursor,omitempty"`
+	}
+
+	resp := response{Chirps: make([]Chirp, len(chirps))}
+	for i, ch := range chirps {
+		resp.Chirps[i] = chirpJSON(ch)
+	}
+	err = cfg.loadChirpDetails(r.Context(), resp.Chirps, viewerID)
+	if err != nil {
+		respondError(w, "Can't get chirp details", 500, err)
+		return
+	}
+	resp.Chirps, err = cfg.applySensitivePreference(r.Context(), resp.Chirps, viewerID)
+	if err != nil {
+		respondError(w, "Can't get chirp details", 500, err)
+		return
+	}
+	if len(chirps) == limit {
+		resp.NextCursor = chirps[len(chirps)-1].CreatedAt.Format(time.RFC3339Nano)
+	}
+
+	respondJSON(w, 200, resp)
+}
@@ -1,10 +1,17 @@
 package main
 
 import (
+	"cmp"
+	"context"
+	"crypto/subtle"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"log"
 	"net/http"
+	"net/netip"
 	"os"
+	"slices"
 	"sort"
 	"strings"
 	"sync/atomic"
@@ -14,6 +21,12 @@ import (
 
 	"github.com/aobatake/goserver/internal/auth"
 	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/events"
+	"github.com/aobatake/goserver/internal/links"
+	"github.com/aobatake/goserver/internal/ratelimit"
+	"github.com/aobatake/goserver/internal/spam"
+	"github.com/aobatake/goserver/internal/storage"
+	"github.com/aobatake/goserver/internal/validate"
 	"github.com/google/uuid"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -22,9 +35,20 @@ import (
 type APIConfig struct {
 	fileserverHits atomic.Int32
 	db             *database.Queries
+	sqlDB          *sql.DB
 	platform       string
 	JWTSecret      string
 	polkaSecret    string
+	deletedChirps  string
+	events         *events.Broker
+	realtime       *realtimeHub
+	storage        storage.Storage
+	linkFetcher    *links.Fetcher
+	spamFilter     *spam.Pipeline
+	spamClassifier *spam.Classifier
+	rateLimiter    ratelimit.Store
+	trustedProxies []netip.Prefix
+	metrics        *serverMetrics
 }
 
 type User struct {
@@ -38,11 +62,37 @@ type User struct {
 }
 
 type Chirp struct {
-	ID        uuid.UUID `json:"id"`
-	CreatedAt time.Time `json:"created_at"`
-	UpdatedAt time.Time `json:"updated_at"`
-	Body      string    `json:"body"`
-	User_id   uuid.UUID `json:"user_id"`
+	ID             uuid.UUID    `json:"id"`
+	CreatedAt      time.Time    `json:"created_at"`
+	UpdatedAt      time.Time    `json:"updated_at"`
+	Body           string       `json:"body"`
+	User_id        uuid.UUID    `json:"user_id"`
+	Attachments    []Attachment `json:"attachments,omitempty"`
+	Links          []Link       `json:"links,omitempty"`
+	Poll           *Poll        `json:"poll,omitempty"`
+	QuotedChirpID  *uuid.UUID   `json:"quoted_chirp_id,omitempty"`
+	QuotedChirp    *QuotedChirp `json:"quoted_chirp,omitempty"`
+	QuoteCount     int64        `json:"quote_count"`
+	Pinned         bool         `json:"pinned,omitempty"`
+	Visibility     string       `json:"visibility"`
+	ContentWarning string       `json:"content_warning,omitempty"`
+	Sensitive      bool         `json:"sensitive"`
+	Collapsed      bool         `json:"collapsed,omitempty"`
+	Held           bool         `json:"held,omitempty"`
+}
+
+func chirpJSON(ch database.Chirp) Chirp {
+	return Chirp{
+		ID:             ch.ID,
+		CreatedAt:      ch.CreatedAt,
+		UpdatedAt:      ch.UpdatedAt,
+		Body:           ch.Body,
+		User_id:        ch.UserID,
+		QuotedChirpID:  nullUUIDPtr(ch.QuotedChirpID),
+		Visibility:     ch.Visibility,
+		ContentWarning: ch.ContentWarning.String,
+		Sensitive:      ch.Sensitive,
+	}
 }
 
 func main() {
@@ -51,6 +101,11 @@ func main() {
 	platform := os.Getenv("PLATFORM")
 	JWTSecret := os.Getenv("JWT_SECRET")
 	polkaSecret := os.Getenv("POLKA_KEY")
+	metricsToken := os.Getenv("METRICS_TOKEN")
+	deletedChirps := os.Getenv("DELETED_ACCOUNT_CHIRPS")
+	if deletedChirps != DeletedChirpsAnonymize {
+		deletedChirps = DeletedChirpsDelete
+	}
 
 	db, err := sql.Open("postgres", dbURL)
 	if err != nil {
@@ -58,37 +113,184 @@ func main() {
 	}
 	dbQueries := database.New(db)
 
+	mediaStorage, err := newMediaStorage()
+	if err != nil {
+		log.Printf("Can't set up media storage: %v", err)
+		return
+	}
+
+	broker := events.NewBroker()
+	go func() {
+		err := broker.Listen(dbURL)
+		if err != nil {
+			log.Printf("Chirp event listener stopped: %v", err)
+		}
+	}()
+
+	rateLimiter, err := newRateLimitStore(dbQueries)
+	if err != nil {
+		log.Printf("Can't set up rate limiting: %v", err)
+		return
+	}
+	trustedProxies, err := parseTrustedProxies(os.Getenv("TRUSTED_PROXIES"))
+	if err != nil {
+		log.Printf("Can't parse TRUSTED_PROXIES: %v", err)
+		return
+	}
+
+	classifier := spam.NewClassifier()
+
 	mux := http.NewServeMux()
 	ap := APIConfig{
 		fileserverHits: atomic.Int32{},
 		db:             dbQueries,
+		sqlDB:          db,
 		platform:       platform,
 		JWTSecret:      JWTSecret,
 		polkaSecret:    polkaSecret,
+		deletedChirps:  deletedChirps,
+		events:         broker,
+		realtime:       newRealtimeHub(),
+		storage:        mediaStorage,
+		linkFetcher:    links.NewFetcher(),
+		spamFilter:     spam.NewPipeline(classifier),
+		spamClassifier: classifier,
+		rateLimiter:    rateLimiter,
+		trustedProxies: trustedProxies,
 	}
+	ap.metrics = newServerMetrics(db, func() float64 {
+		return float64(ap.fileserverHits.Load())
+	})
+	go ap.runPurgeJob(context.Background())
+	go ap.resumeDataExports(context.Background())
+	go ap.resumeChirpImports(context.Background())
+	go ap.runPollJob(context.Background())
+	go ap.runDraftPublisher(context.Background())
+	go ap.runLinkPreviewJob(context.Background())
+	go ap.runSpamModelJob(context.Background())
+
 	mux.Handle("/app/", ap.middlewareMetricsInc(http.StripPrefix("/app/", http.FileServer(http.Dir(".")))))
 	mux.HandleFunc("GET /admin/metrics", ap.metricsHandler)
+	// Metrics are only served when a token for the scraper is set.
+	if metricsToken != "" {
+		mux.Handle("GET /metrics", ap.metrics.handler(metricsToken))
+	}
 	mux.HandleFunc("POST /admin/reset", ap.resetHandler)
+	mux.HandleFunc("GET /admin/users/{userID}", ap.adminGetUserHandler)
+	mux.HandleFunc("POST /admin/users/{userID}/suspend", ap.suspendUserHandler)
+	mux.HandleFunc("DELETE /admin/users/{userID}/suspend", ap.unsuspendUserHandler)
+	mux.HandleFunc("POST /admin/users/{userID}/ban", ap.banUserHandler)
+	mux.HandleFunc("DELETE /admin/users/{userID}/ban", ap.unbanUserHandler)
+	mux.HandleFunc("POST /admin/users/{userID}/shadowban", ap.shadowbanUserHandler)
+	mux.HandleFunc("DELETE /admin/users/{userID}/shadowban", ap.unshadowbanUserHandler)
+	mux.HandleFunc("DELETE /admin/users/{userID}", ap.adminDeleteUserHandler)
+	mux.HandleFunc("GET /admin/audit", ap.getAuditLogHandler)
+	mux.HandleFunc("GET /admin/audit/verify", ap.verifyAuditLogHandler)
 
 	mux.HandleFunc("GET /api/healthz", healthzHandler)
 
 	mux.HandleFunc("POST /api/chirps", ap.chirpHandler)
 	mux.HandleFunc("GET /api/chirps", ap.getChirpsHandler)
+	mux.HandleFunc("GET /api/chirps/stream", ap.streamChirpsHandler)
+	mux.Handle("POST /api/chirps/import", ap.rateLimited("imports", ap.importChirpsHandler))
 	mux.HandleFunc("GET /api/chirps/{chirpID}", ap.getChirpHandler)
 	mux.HandleFunc("DELETE /api/chirps/{chirpID}", ap.DeleteChirpHandler)
-
-	mux.HandleFunc("POST /api/users", ap.createUsersHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/restore", ap.restoreChirpHandler)
+	mux.Handle("POST /api/chirps/{chirpID}/report", ap.rateLimited("reports", ap.reportChirpHandler))
+	mux.HandleFunc("POST /api/chirps/{chirpID}/poll/votes", ap.votePollHandler)
+	mux.HandleFunc("GET /api/chirps/{chirpID}/quotes", ap.getQuotesHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/bookmark", ap.bookmarkChirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/bookmark", ap.unbookmarkChirpHandler)
+	mux.HandleFunc("POST /api/chirps/{chirpID}/pin", ap.pinChirpHandler)
+	mux.HandleFunc("DELETE /api/chirps/{chirpID}/pin", ap.unpinChirpHandler)
+
+	mux.HandleFunc("POST /api/drafts", ap.createDraftHandler)
+	mux.HandleFunc("GET /api/drafts", ap.getDraftsHandler)
+	mux.HandleFunc("GET /api/drafts/{draftID}", ap.getDraftHandler)
+	mux.HandleFunc("PUT /api/drafts/{draftID}", ap.updateDraftHandler)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}", ap.deleteDraftHandler)
+	mux.HandleFunc("DELETE /api/drafts/{draftID}/schedule", ap.unscheduleDraftHandler)
+	mux.HandleFunc("POST /api/drafts/{draftID}/publish", ap.publishDraftHandler)
+
+	mux.HandleFunc("POST /api/lists", ap.createListHandler)
+	mux.HandleFunc("GET /api/lists/{listID}", ap.getListHandler)
+	mux.HandleFunc("PUT /api/lists/{listID}", ap.updateListHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}", ap.deleteListHandler)
+	mux.HandleFunc("GET /api/lists/{listID}/members", ap.getListMembersHandler)
+	mux.HandleFunc("POST /api/lists/{listID}/members", ap.addListMemberHandler)
+	mux.HandleFunc("DELETE /api/lists/{listID}/members/{userID}", ap.removeListMemberHandler)
+	mux.HandleFunc("GET /api/lists/{listID}/timeline", ap.getListTimelineHandler)
+
+	mux.Handle("POST /api/media", ap.rateLimited("media", ap.uploadMediaHandler))
+	mux.HandleFunc("PUT /api/media/{mediaID}", ap.updateMediaHandler)
+	mux.HandleFunc("GET /api/media/{mediaID}", ap.getMediaHandler)
+	mux.HandleFunc("GET /api/media/{mediaID}/thumbnail", ap.getMediaThumbnailHandler)
+
+	mux.Handle("POST /api/users", ap.rateLimited("signup", ap.createUsersHandler))
+	mux.HandleFunc("POST /api/users/restore", ap.restoreUserHandler)
 	mux.HandleFunc("PUT /api/users", ap.updateUsersHandler)
-
-	mux.HandleFunc("POST /api/login", ap.loginHandler)
+	mux.HandleFunc("DELETE /api/users/me", ap.deleteMeHandler)
+	mux.HandleFunc("POST /api/users/me/export", ap.requestExportHandler)
+	mux.HandleFunc("GET /api/users/me/exports/{exportID}", ap.getExportHandler)
+	mux.HandleFunc("GET /api/users/me/imports/{importID}", ap.getChirpImportHandler)
+	mux.HandleFunc("GET /api/users/me/bookmarks", ap.getBookmarksHandler)
+	mux.HandleFunc("GET /api/users/me/lists", ap.getMyListsHandler)
+	mux.HandleFunc("GET /api/users/me/pins", ap.getPinnedChirpsHandler)
+	mux.HandleFunc("PUT /api/users/me/pins", ap.reorderPinnedChirpsHandler)
+	mux.HandleFunc("GET /api/exports/{exportID}/download", ap.downloadExportHandler)
+
+	mux.Handle("POST /api/login", ap.rateLimited("login", ap.loginHandler))
 	mux.HandleFunc("POST /api/refresh", ap.refreshTokenHandler)
 	mux.HandleFunc("POST /api/revoke", ap.revokeRefreshTokenHandler)
 
 	mux.HandleFunc("POST /api/polka/webhooks", ap.PolkaHandler)
 
+	mux.HandleFunc("GET /api/ws", ap.realtimeHandler)
+
+	mux.HandleFunc("GET /api/notifications", ap.getNotificationsHandler)
+	mux.HandleFunc("POST /api/notifications/read", ap.markNotificationsReadHandler)
+	mux.HandleFunc("POST /api/notifications/read_all", ap.markAllNotificationsReadHandler)
+	mux.HandleFunc("GET /api/notifications/preferences", ap.getNotificationPreferencesHandler)
+	mux.HandleFunc("PUT /api/notifications/preferences", ap.updateNotificationPreferencesHandler)
+
+	mux.Handle("POST /api/conversations", ap.rateLimited("messages", ap.createConversationHandler))
+	mux.HandleFunc("GET /api/conversations", ap.getConversationsHandler)
+	mux.HandleFunc("GET /api/conversations/{conversationID}", ap.getConversationHandler)
+	mux.HandleFunc("DELETE /api/conversations/{conversationID}", ap.deleteConversationHandler)
+	mux.HandleFunc("GET /api/conversations/{conversationID}/messages", ap.getMessagesHandler)
+	mux.Handle("POST /api/conversations/{conversationID}/messages", ap.rateLimited("messages", ap.sendMessageHandler))
+	mux.HandleFunc("POST /api/conversations/{conversationID}/read", ap.markConversationReadHandler)
+	mux.HandleFunc("GET /api/users/me/dm_settings", ap.getDMSettingsHandler)
+	mux.HandleFunc("PUT /api/users/me/dm_settings", ap.updateDMSettingsHandler)
+
+	mux.HandleFunc("POST /api/users/{userID}/block", ap.blockUserHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/block", ap.unblockUserHandler)
+	mux.HandleFunc("POST /api/users/{userID}/follow", ap.followUserHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/follow", ap.unfollowUserHandler)
+	mux.HandleFunc("GET /api/users/me/follow_requests", ap.getFollowRequestsHandler)
+	mux.HandleFunc("POST /api/users/me/follow_requests/{userID}/approve", ap.approveFollowRequestHandler)
+	mux.HandleFunc("DELETE /api/users/me/follow_requests/{userID}", ap.rejectFollowRequestHandler)
+	mux.HandleFunc("GET /api/users/me/content_settings", ap.getContentSettingsHandler)
+	mux.HandleFunc("PUT /api/users/me/content_settings", ap.updateContentSettingsHandler)
+	mux.HandleFunc("GET /api/users/me/privacy", ap.getPrivacyHandler)
+	mux.HandleFunc("PUT /api/users/me/privacy", ap.updatePrivacyHandler)
+	mux.HandleFunc("POST /api/users/{userID}/mute", ap.muteUserHandler)
+	mux.HandleFunc("DELETE /api/users/{userID}/mute", ap.unmuteUserHandler)
+	mux.HandleFunc("GET /api/users/me/blocks", ap.getBlockedUsersHandler)
+	mux.HandleFunc("GET /api/users/me/mutes", ap.getMutedUsersHandler)
+	mux.Handle("POST /api/users/{userID}/report", ap.rateLimited("reports", ap.reportUserHandler))
+
+	mux.HandleFunc("GET /api/moderation/reports", ap.getReportsHandler)
+	mux.HandleFunc("POST /api/moderation/reports/{reportID}/claim", ap.claimReportHandler)
+	mux.HandleFunc("POST /api/moderation/reports/{reportID}/resolve", ap.resolveReportHandler)
+	mux.HandleFunc("GET /api/moderation/decisions", ap.getModerationDecisionsHandler)
+	mux.HandleFunc("GET /api/moderation/held_chirps", ap.getHeldChirpsHandler)
+	mux.HandleFunc("POST /api/moderation/held_chirps/{chirpID}/approve", ap.approveHeldChirpHandler)
+	mux.HandleFunc("POST /api/moderation/held_chirps/{chirpID}/reject", ap.rejectHeldChirpHandler)
+
 	s := &http.Server{
 		Addr:    ":8080",
-		Handler: mux,
+		Handler: middlewareRequestID(ap.metrics.middleware(mux, ap.middlewareRateLimit("default", mux))),
 	}
 
 	s.ListenAndServe()
@@ -111,16 +313,25 @@ func (c *APIConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 
 	user, err := c.db.GetUser(r.Context(), login.Email)
 	if err != nil {
+		c.recordAudit(r, uuid.Nil, AuditLoginFailed, "user", "", map[string]string{"reason": "unknown_email"})
+		c.metrics.logins.WithLabelValues("failed").Inc()
 		respondError(w, "User with this email doesn't exist", 500, err)
 		return
 	}
 
 	err = auth.CheckPasswordHash(login.Password, user.HashedPassword)
 	if err != nil {
+		c.recordAudit(r, uuid.Nil, AuditLoginFailed, "user", user.ID.String(), map[string]string{"reason": "wrong_password"})
+		c.metrics.logins.WithLabelValues("failed").Inc()
 		respondError(w, "401 Unauthorized", 401, err)
 		return
 	}
 
+	if user.BannedAt.Valid {
+		respondError(w, "User is banned", 403, nil)
+		return
+	}
+
 	expiresInSeconds := 360
 	if login.ExpiresInSeconds != 0 && login.ExpiresInSeconds < 360 {
 		expiresInSeconds = login.ExpiresInSeconds
@@ -158,6 +369,9 @@ func (c *APIConfig) loginHandler(w http.ResponseWriter, r *http.Request) {
 		IsChirpyRed:  user.IsChirpyRed,
 	}
 
+	c.recordAudit(r, user.ID, AuditLoginSucceeded, "user", user.ID.String(), nil)
+	c.metrics.logins.WithLabelValues("succeeded").Inc()
+
 	respondJSON(w, 200, userJSON)
 }
 
@@ -168,12 +382,20 @@ func (c *APIConfig) revokeRefreshTokenHandler(w http.ResponseWriter, r *http.Req
 		return
 	}
 
+	tokenInfo, err := c.db.GetRefreshToken(r.Context(), token)
+	if err != nil {
+		respondError(w, "RefreshToken Invalid", 401, err)
+		return
+	}
+
 	err = c.db.RevokeToken(r.Context(), token)
 	if err != nil {
 		respondError(w, "Can't revoke token", 500, err)
 		return
 	}
 
+	c.recordAudit(r, tokenInfo.UserID, AuditTokenRevoked, "user", tokenInfo.UserID.String(), nil)
+
 	w.WriteHeader(204)
 }
 
@@ -193,6 +415,16 @@ func (c *APIConfig) refreshTokenHandler(w http.ResponseWriter, r *http.Request)
 		return
 	}
 
+	user, err := c.db.GetUserByID(r.Context(), tokenInfo.UserID)
+	if err != nil {
+		respondError(w, "RefreshToken Invalid", 401, err)
+		return
+	}
+	if user.BannedAt.Valid {
+		respondError(w, "User is banned", 403, nil)
+		return
+	}
+
 	token, err = auth.MakeJWT(tokenInfo.UserID, c.JWTSecret, time.Duration(360*time.Second))
 	if err != nil {
 		respondError(w, "Can't create token", 500, err)
@@ -252,6 +484,8 @@ func (c *APIConfig) updateUsersHandler(w http.ResponseWriter, r *http.Request) {
 		IsChirpyRed: user.IsChirpyRed,
 	}
 
+	c.recordAudit(r, user.ID, AuditPasswordChanged, "user", user.ID.String(), nil)
+
 	respondJSON(w, 200, userJSON)
 }
 
@@ -336,9 +570,49 @@ func (c *APIConfig) DeleteChirpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// Only public chirps were announced on the streams.
+	if ch.Visibility == VisibilityPublic {
+		c.publishChirpEvent(r.Context(), events.ChirpDeleted, Chirp{
+			ID:      ch.ID,
+			User_id: ch.UserID,
+		})
+	}
+	c.recordAudit(r, userID, AuditChirpDeleted, "chirp", ch.ID.String(), nil)
+
 	w.WriteHeader(204)
 }
 
+// loadChirpDetails fills in what is stored alongside chirps, as seen by
+// viewerID.
+func (cfg *APIConfig) loadChirpDetails(ctx context.Context, chirps []Chirp, viewerID uuid.UUID) error {
+	err := cfg.loadAttachments(ctx, chirps)
+	if err != nil {
+		return err
+	}
+	err = cfg.loadLinks(ctx, chirps)
+	if err != nil {
+		return err
+	}
+	err = cfg.loadPolls(ctx, chirps, viewerID)
+	if err != nil {
+		return err
+	}
+	return cfg.loadQuotes(ctx, chirps, viewerID)
+}
+
+// viewerID returns the user making the request, or uuid.Nil when the request
+// is anonymous.
+func (cfg *APIConfig) viewerID(r *http.Request) (uuid.UUID, error) {
+	if r.Header.Get("Authorization") == "" {
+		return uuid.Nil, nil
+	}
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		return uuid.Nil, err
+	}
+	return auth.ValidateJWT(token, cfg.JWTSecret)
+}
+
 func (cfg *APIConfig) getChirpHandler(w http.ResponseWriter, r *http.Request) {
 	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
 	if err != nil {
@@ -346,20 +620,29 @@ func (cfg *APIConfig) getChirpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	ch, err := cfg.db.GetChirp(r.Context(), chirpID)
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	ch, err := cfg.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
 	if err != nil {
 		respondError(w, "Can't get chirp", 404, err)
 		return
 	}
-	cc := Chirp{
-		ID:        ch.ID,
-		CreatedAt: ch.CreatedAt,
-		UpdatedAt: ch.UpdatedAt,
-		Body:      ch.Body,
-		User_id:   ch.UserID,
+	cc := []Chirp{chirpJSON(ch)}
+
+	err = cfg.loadChirpDetails(r.Context(), cc, viewerID)
+	if err != nil {
+		respondError(w, "Can't get chirp details", 500, err)
+		return
 	}
 
-	respondJSON(w, 200, cc)
+	respondJSON(w, 200, cc[0])
 }
 
 func (cfg *APIConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
@@ -378,9 +661,16 @@ func (cfg *APIConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
 		filterByUserID = true
 	}
 
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
 	chirps, err := cfg.db.GetChirps(r.Context(), database.GetChirpsParams{
 		UserID:         authorID,
 		FilterByUserID: filterByUserID,
+		ViewerID:       viewerID,
 	})
 	if err != nil {
 		respondError(w, "Can't get chirps", 500, err)
@@ -395,13 +685,28 @@ func (cfg *APIConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
 	}
 	cc := make([]Chirp, len(chirps))
 	for i, ch := range chirps {
-		cc[i] = Chirp{
-			ID:        ch.ID,
-			CreatedAt: ch.CreatedAt,
-			UpdatedAt: ch.UpdatedAt,
-			Body:      ch.Body,
-			User_id:   ch.UserID,
+		cc[i] = chirpJSON(ch)
+	}
+
+	// Pinned chirps come first on an author's profile.
+	if filterByUserID {
+		pins, err := cfg.db.GetPinnedChirps(r.Context(), authorID)
+		if err != nil {
+			respondError(w, "Can't get pinned chirps", 500, err)
+			return
 		}
+		cc = pinFirst(cc, pins)
+	}
+
+	err = cfg.loadChirpDetails(r.Context(), cc, viewerID)
+	if err != nil {
+		respondError(w, "Can't get chirp details", 500, err)
+		return
+	}
+	cc, err = cfg.applySensitivePreference(r.Context(), cc, viewerID)
+	if err != nil {
+		respondError(w, "Can't get chirp details", 500, err)
+		return
 	}
 
 	respondJSON(w, 200, cc)
@@ -409,25 +714,29 @@ func (cfg *APIConfig) getChirpsHandler(w http.ResponseWriter, r *http.Request) {
 
 func (c *APIConfig) chirpHandler(w http.ResponseWriter, r *http.Request) {
 	type chirp struct {
-		Body    string `json:"body"`
-		User_id string `json:"user_id"`
+		Body           string      `json:"body"`
+		User_id        string      `json:"user_id"`
+		MediaIDs       []uuid.UUID `json:"media_ids"`
+		Poll           *newPoll    `json:"poll"`
+		QuotedChirpID  *uuid.UUID  `json:"quoted_chirp_id"`
+		Visibility     string      `json:"visibility"`
+		ContentWarning string      `json:"content_warning"`
+		Sensitive      bool        `json:"sensitive"`
 	}
+	r.Body = http.MaxBytesReader(w, r.Body, maxChirpRequestBytes)
 	decoder := json.NewDecoder(r.Body)
 	ch := chirp{}
 	err := decoder.Decode(&ch)
-	if err != nil {
-		respondError(w, "Something went wrong", 500, err)
+	var tooLarge *http.MaxBytesError
+	if errors.As(err, &tooLarge) {
+		respondError(w, "Request body is too large", 413, err)
 		return
 	}
-	if len(ch.Body) > 140 {
-		respondError(w, "Chirp is too long", 400, err)
+	if err != nil {
+		respondError(w, "Something went wrong", 500, err)
 		return
 	}
 
-	msg := censorMsg(ch.Body, "kerfuffle")
-	msg = censorMsg(msg, "sharbert")
-	msg = censorMsg(msg, "fornax")
-
 	token, err := auth.GetBearerToken(r.Header)
 	if err != nil {
 		respondError(w, "Invalid Authorization Header", 500, err)
@@ -440,25 +749,388 @@ func (c *APIConfig) chirpHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	cc, err := c.db.CreateChirp(r.Context(), database.CreateChirpParams{
-		Body:   msg,
-		UserID: userID,
-	})
-	if err != nil {
+	errs := validate.Errors{}
+	msg, err := cleanChirpBody(ch.Body)
+	errs.Add(err)
+	if len(ch.MediaIDs) > maxAttachmentsPerChirp {
+		errs.Add(errTooManyAttachments)
+	}
+	if ch.Poll != nil {
+		err = ch.Poll.validate(time.Now().UTC())
+		if err != nil {
+			errs.Add(validate.Errorf("poll", "%v", err))
+		}
+	}
+	visibility, err := chirpVisibility(ch.Visibility)
+	errs.Add(err)
+	warning, err := contentWarning(ch.ContentWarning)
+	errs.Add(err)
+	if errs.Err() != nil {
+		respondValidationError(w, errs)
 		return
 	}
 
-	chirpResponse := Chirp{
-		ID:        cc.ID,
-		CreatedAt: cc.CreatedAt,
-		UpdatedAt: cc.UpdatedAt,
-		Body:      cc.Body,
-		User_id:   cc.UserID,
+	nc := newChirp{
+		Body:           msg,
+		MediaIDs:       ch.MediaIDs,
+		Poll:           ch.Poll,
+		Visibility:     visibility,
+		ContentWarning: warning,
+		Sensitive:      ch.Sensitive,
+		Source:         ChirpSourceAPI,
+	}
+	if ch.QuotedChirpID != nil {
+		nc.QuotedChirpID = uuid.NullUUID{UUID: *ch.QuotedChirpID, Valid: true}
 	}
 
+	chirpResponse, err := c.storeChirp(r.Context(), userID, nc)
+	if respondRateLimited(w, err) {
+		return
+	}
+	if errors.Is(err, errUserSuspended) || errors.Is(err, errUserBanned) || errors.Is(err, errSpam) {
+		respondError(w, err.Error(), 403, err)
+		return
+	}
+	if errors.Is(err, errInvalidMedia) || errors.Is(err, errQuotedChirpNotFound) {
+		respondError(w, err.Error(), 400, err)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't create chirp", 500, err)
+		return
+	}
+
+	// Held chirps are accepted but only published once a moderator
+	// approves them.
+	if chirpResponse.Held {
+		respondJSON(w, 202, chirpResponse)
+		return
+	}
 	respondJSON(w, 201, chirpResponse)
 }
 
+const (
+	maxChirpLength = 140
+	// maxChirpBytes bounds chirps that are short in characters but long in
+	// bytes, such as a letter with thousands of combining marks.
+	maxChirpBytes = 4 * maxChirpLength
+	// maxChirpRequestBytes is the largest request body accepted when
+	// posting a chirp or saving a draft.
+	maxChirpRequestBytes = 64 << 10
+)
+
+var (
+	errUserSuspended      = errors.New("User is suspended")
+	errUserBanned         = errors.New("User is banned")
+	errInvalidMedia       = errors.New("Media doesn't exist or is already attached")
+	errTooManyAttachments = validate.Errorf("media_ids", "A chirp can have at most 4 attachments")
+	errInvalidVisibility  = validate.Errorf("visibility", "visibility must be public, followers or mentioned")
+)
+
+// Chirp visibilities. Protected accounts additionally limit every chirp to
+// their approved followers.
+const (
+	VisibilityPublic    = "public"
+	VisibilityFollowers = "followers"
+	VisibilityMentioned = "mentioned"
+)
+
+// chirpVisibility validates a requested visibility, defaulting to public.
+func chirpVisibility(visibility string) (string, error) {
+	switch visibility {
+	case "":
+		return VisibilityPublic, nil
+	case VisibilityPublic, VisibilityFollowers, VisibilityMentioned:
+		return visibility, nil
+	}
+	return "", errInvalidVisibility
+}
+
+// cleanChirpBody normalises and validates a chirp body and censors banned
+// words. Its length is counted in user-perceived characters, with links
+// counting as links.ShortURLLength however long they are. Failures are
+// *validate.FieldError.
+func cleanChirpBody(body string) (string, error) {
+	body, err := validate.Text("body", body, validate.Rules{
+		Required:  true,
+		MaxLength: maxChirpLength,
+		MaxBytes:  maxChirpBytes,
+		Length:    links.Length,
+		Multiline: true,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	msg := censorMsg(body, "kerfuffle")
+	msg = censorMsg(msg, "sharbert")
+	msg = censorMsg(msg, "fornax")
+	return msg, nil
+}
+
+// newChirp is a chirp to be posted. Body and ContentWarning must already be
+// cleaned, Poll validated and Visibility one of the chirp visibilities.
+type newChirp struct {
+	Body           string
+	MediaIDs       []uuid.UUID
+	Poll           *newPoll
+	QuotedChirpID  uuid.NullUUID
+	Visibility     string
+	ContentWarning sql.NullString
+	Sensitive      bool
+	// Source is where the chirp came from, one of the ChirpSource*
+	// constants.
+	Source string
+	// Imported chirps keep the time they were originally posted, and their
+	// ImportKey makes importing them again a no-op.
+	CreatedAt time.Time
+	ImportKey sql.NullString
+}
+
+// rateLimited reports whether nc counts against the "chirps" rate limit.
+// Imports are limited as a whole when they're created instead, as one can
+// hold thousands of chirps.
+func (nc newChirp) rateLimited() bool {
+	return nc.Source != ChirpSourceImport
+}
+
+// announced reports whether nc is published on the chirp streams and
+// notifies the users it mentions once posted. Imported chirps were posted
+// long ago, so they're only saved.
+func (nc newChirp) announced() bool {
+	return nc.Source != ChirpSourceImport
+}
+
+// storeChirp saves a chirp with its attachments and publishes its creation
+// event. Chirps the spam checks hold are stored hidden and not published.
+func (c *APIConfig) storeChirp(ctx context.Context, userID uuid.UUID, nc newChirp) (Chirp, error) {
+	user, err := c.db.GetUserByID(ctx, userID)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	tx, err := c.sqlDB.BeginTx(ctx, nil)
+	if err != nil {
+		return Chirp{}, err
+	}
+	defer tx.Rollback()
+	qtx := c.db.WithTx(tx)
+
+	chirpResponse, err := c.postChirp(ctx, qtx, user, nc)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		return Chirp{}, err
+	}
+	c.chirpPosted(ctx, user, nc, chirpResponse)
+	return chirpResponse, nil
+}
+
+// postChirp checks that user may post nc, isn't over the "chirps" rate
+// limit and that nc isn't spam, then saves it with qtx. Chirps the spam
+// checks hold are saved hidden, with Held set. It returns sql.ErrNoRows for
+// an imported chirp that was already imported.
+func (c *APIConfig) postChirp(ctx context.Context, qtx *database.Queries, user database.User, nc newChirp) (Chirp, error) {
+	err := canPost(user)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	// The limit is taken here rather than on the route so that drafts and
+	// chirps posted over WebSocket count against it too.
+	if nc.rateLimited() {
+		err = c.takeRateLimit(ctx, "chirps", "user:"+user.ID.String())
+		if err != nil {
+			return Chirp{}, err
+		}
+	}
+
+	verdict, err := c.checkSpam(ctx, user, nc.Body)
+	if err != nil {
+		return Chirp{}, err
+	}
+	if verdict.Verdict == spam.Reject {
+		return Chirp{}, errSpam
+	}
+
+	chirpResponse, err := insertChirp(ctx, qtx, user.ID, nc)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	if verdict.Verdict == spam.Hold {
+		err = qtx.HoldChirp(ctx, database.HoldChirpParams{
+			ChirpID: chirpResponse.ID,
+			Score:   verdict.Score,
+			Reasons: verdict.Reasons(),
+		})
+		if err != nil {
+			return Chirp{}, err
+		}
+		chirpResponse.Held = true
+	}
+	return chirpResponse, nil
+}
+
+// chirpPosted counts a chirp postChirp saved, once it is committed, and
+// announces it unless it is held for review or imported.
+func (c *APIConfig) chirpPosted(ctx context.Context, user database.User, nc newChirp, ch Chirp) {
+	c.metrics.chirpsCreated.WithLabelValues(nc.Source).Inc()
+	if !ch.Held && nc.announced() {
+		c.announceChirp(ctx, user, ch)
+	}
+}
+
+// canPost reports why user isn't allowed to post, if they aren't.
+func canPost(user database.User) error {
+	if user.BannedAt.Valid {
+		return errUserBanned
+	}
+	if user.SuspendedUntil.Valid && user.SuspendedUntil.Time.After(time.Now().UTC()) {
+		return errUserSuspended
+	}
+	return nil
+}
+
+// requireCanPost checks that userID isn't suspended or banned, responding
+// 403 if they are. Messages and reports are held to the same rule as
+// chirps.
+func (c *APIConfig) requireCanPost(w http.ResponseWriter, r *http.Request, userID uuid.UUID) bool {
+	user, err := c.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get user", 500, err)
+		return false
+	}
+	err = canPost(user)
+	if err != nil {
+		respondError(w, err.Error(), 403, err)
+		return false
+	}
+	return true
+}
+
+// insertChirp saves a chirp, its attachments, poll and mentions with qtx.
+func insertChirp(ctx context.Context, qtx *database.Queries, userID uuid.UUID, nc newChirp) (Chirp, error) {
+	var quoted Chirp
+	if nc.QuotedChirpID.Valid {
+		q, err := qtx.GetVisibleChirp(ctx, database.GetVisibleChirpParams{
+			ID:       nc.QuotedChirpID.UUID,
+			ViewerID: userID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return Chirp{}, errQuotedChirpNotFound
+		}
+		if err != nil {
+			return Chirp{}, err
+		}
+		quoted = chirpJSON(q)
+	}
+
+	var cc database.Chirp
+	var err error
+	if nc.ImportKey.Valid {
+		cc, err = qtx.ImportChirp(ctx, database.ImportChirpParams{
+			CreatedAt: nc.CreatedAt,
+			Body:      nc.Body,
+			UserID:    userID,
+			ImportKey: nc.ImportKey,
+		})
+	} else {
+		cc, err = qtx.CreateChirp(ctx, database.CreateChirpParams{
+			Body:           nc.Body,
+			UserID:         userID,
+			QuotedChirpID:  nc.QuotedChirpID,
+			Visibility:     nc.Visibility,
+			ContentWarning: nc.ContentWarning,
+			Sensitive:      nc.Sensitive,
+		})
+	}
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	chirpResponse := chirpJSON(cc)
+
+	chirpResponse.Links, err = saveChirpLinks(ctx, qtx, cc.ID, cc.Body)
+	if err != nil {
+		return Chirp{}, err
+	}
+
+	mentioned, err := mentionedUsers(ctx, qtx, userID, cc.Body)
+	if err != nil {
+		return Chirp{}, err
+	}
+	if len(mentioned) > 0 {
+		err = qtx.CreateChirpMentions(ctx, database.CreateChirpMentionsParams{
+			ChirpID: cc.ID,
+			UserIds: mentioned,
+		})
+		if err != nil {
+			return Chirp{}, err
+		}
+	}
+
+	if len(nc.MediaIDs) > 0 {
+		attached, err := qtx.AttachMedia(ctx, database.AttachMediaParams{
+			ChirpID: cc.ID,
+			Ids:     nc.MediaIDs,
+			UserID:  userID,
+		})
+		if err != nil {
+			return Chirp{}, err
+		}
+		if len(attached) != len(nc.MediaIDs) {
+			return Chirp{}, errInvalidMedia
+		}
+		slices.SortFunc(attached, func(a, b database.Attachment) int {
+			return cmp.Compare(a.Position, b.Position)
+		})
+		for _, a := range attached {
+			chirpResponse.Attachments = append(chirpResponse.Attachments, attachmentJSON(a))
+		}
+	}
+
+	if nc.Poll != nil {
+		chirpResponse.Poll, err = createPoll(ctx, qtx, cc.ID, *nc.Poll)
+		if err != nil {
+			return Chirp{}, err
+		}
+	}
+
+	if nc.QuotedChirpID.Valid {
+		quoted.Attachments, err = chirpAttachments(ctx, qtx, quoted.ID)
+		if err != nil {
+			return Chirp{}, err
+		}
+		chirpResponse.QuotedChirp = quotedChirpJSON(quoted)
+	}
+
+	return chirpResponse, nil
+}
+
+// announceChirp publishes the creation event of a stored chirp and notifies
+// the users it mentions.
+func (c *APIConfig) announceChirp(ctx context.Context, user database.User, ch Chirp) {
+	// Chirps from shadowbanned users are only visible to themselves, so
+	// nobody else is told about them.
+	if user.ShadowbannedAt.Valid {
+		return
+	}
+	if broadcastable(user, ch) {
+		c.publishChirpEvent(ctx, events.ChirpCreated, ch)
+	}
+	c.notifyMentions(ctx, ch)
+}
+
+// broadcastable reports whether ch may be published on the chirp streams,
+// which anyone can read.
+func broadcastable(author database.User, ch Chirp) bool {
+	return ch.Visibility == VisibilityPublic && !author.Protected
+}
+
 func censorMsg(msg string, censorWord string) string {
 	split_msg := strings.Split(msg, " ")
 	s := make([]string, len(split_msg))
@@ -492,6 +1164,7 @@ func (cfg *APIConfig) resetHandler(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte("Can't delete all users"))
 		return
 	}
+	cfg.recordAudit(r, uuid.Nil, AuditAdminReset, "platform", cfg.platform, nil)
 	w.WriteHeader(http.StatusOK)
 	w.Write([]byte("Reset"))
 
@@ -509,11 +1182,13 @@ func (c *APIConfig) PolkaHandler(w http.ResponseWriter, r *http.Request) {
 
 	key, err := auth.GetAPIKey(r.Header)
 	if err != nil {
+		c.metrics.webhooks.WithLabelValues("unauthorized").Inc()
 		respondError(w, "Authorization Header Invalid", 401, err)
 		return
 	}
 
-	if key != c.polkaSecret {
+	if subtle.ConstantTimeCompare([]byte(key), []byte(c.polkaSecret)) != 1 {
+		c.metrics.webhooks.WithLabelValues("unauthorized").Inc()
 		respondError(w, "API key invalid", 401, err)
 		return
 	}
@@ -522,25 +1197,34 @@ func (c *APIConfig) PolkaHandler(w http.ResponseWriter, r *http.Request) {
 	jr := JSONRequest{}
 	err = decoder.Decode(&jr)
 	if err != nil {
+		c.metrics.webhooks.WithLabelValues("invalid").Inc()
 		respondError(w, "Can't decode JSON Request", 500, err)
 		return
 	}
 
 	if jr.Event != "user.upgraded" {
+		c.metrics.webhooks.WithLabelValues("ignored").Inc()
 		w.WriteHeader(204)
+		return
 	}
 
 	userID, err := uuid.Parse(jr.Data.UserID)
 	if err != nil {
+		c.metrics.webhooks.WithLabelValues("invalid").Inc()
 		respondError(w, "Can't parse chirpID", 500, err)
 		return
 	}
 
 	err = c.db.UpgradeUser(r.Context(), userID)
 	if err != nil {
+		c.metrics.webhooks.WithLabelValues("failed").Inc()
 		respondError(w, "Can't upgrade user to Chirpy Red", 404, err)
 		return
 	}
+	c.metrics.webhooks.WithLabelValues("upgraded").Inc()
+
+	c.notify(r.Context(), userID, NotificationAccount, uuid.NullUUID{}, uuid.NullUUID{})
+	c.recordAudit(r, uuid.Nil, AuditUserUpgraded, "user", userID.String(), map[string]string{"source": "polka"})
 
 	w.WriteHeader(204)
 
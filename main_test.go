@@ -0,0 +1,58 @@
+package main
+
+import (
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/google/uuid"
+)
+
+func TestNewChirpChecks(t *testing.T) {
+	tests := []struct {
+		source      string
+		rateLimited bool
+		announced   bool
+	}{
+		{ChirpSourceAPI, true, true},
+		{ChirpSourceDraft, true, true},
+		{ChirpSourceImport, false, false},
+	}
+	for _, tt := range tests {
+		nc := newChirp{Source: tt.source}
+		if nc.rateLimited() != tt.rateLimited {
+			t.Errorf("%s: rate limited is %v, want %v", tt.source, nc.rateLimited(), tt.rateLimited)
+		}
+		if nc.announced() != tt.announced {
+			t.Errorf("%s: announced is %v, want %v", tt.source, nc.announced(), tt.announced)
+		}
+	}
+}
+
+func TestPolkaHandlerIgnoresOtherEvents(t *testing.T) {
+	// No database: upgrading a user would panic.
+	cfg := &APIConfig{
+		polkaSecret: "polka-key",
+		metrics:     newServerMetrics(nil, func() float64 { return 0 }),
+	}
+	body := `{"event": "user.payment_failed", "data": {"user_id": "` + uuid.NewString() + `"}}`
+
+	tests := []struct {
+		name string
+		key  string
+		code int
+	}{
+		{"ignored event", "polka-key", 204},
+		{"wrong key", "polka-kez", 401},
+		{"key prefix", "polka", 401},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/api/polka/webhooks", strings.NewReader(body))
+		req.Header.Set("Authorization", "ApiKey "+tt.key)
+		w := httptest.NewRecorder()
+		cfg.PolkaHandler(w, req)
+		if w.Code != tt.code {
+			t.Errorf("%s: status is %d, want %d", tt.name, w.Code, tt.code)
+		}
+	}
+}
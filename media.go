@@ -0,0 +1,372 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/media"
+	"github.com/aobatake/goserver/internal/storage"
+	"github.com/google/uuid"
+)
+
+const (
+	maxMediaSize           = 5 << 20
+	maxAttachmentsPerChirp = 4
+	maxAltTextLength       = 1000
+	// unattachedMediaTTL is how long an upload that is neither attached to a
+	// chirp nor used by a draft is kept before it is purged.
+	unattachedMediaTTL = 24 * time.Hour
+	mediaPurgeBatch    = 500
+)
+
+type Attachment struct {
+	ID           uuid.UUID `json:"id"`
+	URL          string    `json:"url"`
+	ThumbnailURL string    `json:"thumbnail_url"`
+	ContentType  string    `json:"content_type"`
+	Width        int32     `json:"width"`
+	Height       int32     `json:"height"`
+	AltText      string    `json:"alt_text"`
+}
+
+func attachmentJSON(a database.Attachment) Attachment {
+	return Attachment{
+		ID:           a.ID,
+		URL:          "/api/media/" + a.ID.String(),
+		ThumbnailURL: "/api/media/" + a.ID.String() + "/thumbnail",
+		ContentType:  a.ContentType,
+		Width:        a.Width,
+		Height:       a.Height,
+		AltText:      a.AltText,
+	}
+}
+
+// newMediaStorage sets up the storage backend named by MEDIA_STORAGE: "local"
+// (the default) keeps files under MEDIA_DIR, "s3" uses the S3_* settings.
+func newMediaStorage() (storage.Storage, error) {
+	switch os.Getenv("MEDIA_STORAGE") {
+	case "", "local":
+		dir := os.Getenv("MEDIA_DIR")
+		if dir == "" {
+			dir = "media"
+		}
+		return storage.NewLocal(dir)
+	case "s3":
+		return &storage.S3{
+			Endpoint:        os.Getenv("S3_ENDPOINT"),
+			Bucket:          os.Getenv("S3_BUCKET"),
+			Region:          os.Getenv("S3_REGION"),
+			AccessKeyID:     os.Getenv("S3_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("S3_SECRET_ACCESS_KEY"),
+			Client:          &http.Client{Timeout: 30 * time.Second},
+		}, nil
+	default:
+		return nil, fmt.Errorf("Unknown MEDIA_STORAGE %q", os.Getenv("MEDIA_STORAGE"))
+	}
+}
+
+// uploadMediaHandler accepts a multipart upload with the image in "file" and
+// optional "alt_text". The result can be attached to a chirp with media_ids.
+func (cfg *APIConfig) uploadMediaHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	// Leave room for the multipart framing and alt text around the file.
+	r.Body = http.MaxBytesReader(w, r.Body, maxMediaSize+64<<10)
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		respondError(w, "Can't read file from the upload", 400, err)
+		return
+	}
+	defer file.Close()
+
+	data, err := io.ReadAll(io.LimitReader(file, maxMediaSize+1))
+	if err != nil {
+		respondError(w, "Can't read file from the upload", 400, err)
+		return
+	}
+	if len(data) > maxMediaSize {
+		respondError(w, "File is larger than 5 MB", 413, nil)
+		return
+	}
+
+	altText := r.FormValue("alt_text")
+	if utf8.RuneCountInString(altText) > maxAltTextLength {
+		respondError(w, "Alt text is too long", 400, nil)
+		return
+	}
+
+	img, err := media.Process(data)
+	if errors.Is(err, media.ErrUnsupportedType) || errors.Is(err, media.ErrTooManyPixels) || errors.Is(err, media.ErrCorrupt) {
+		respondError(w, err.Error(), 400, err)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't process image", 500, err)
+		return
+	}
+
+	id := uuid.New()
+	storageKey := "media/" + id.String()
+	thumbnailKey := "media/" + id.String() + "_thumb"
+
+	err = cfg.storage.Put(r.Context(), storageKey, img.Data, img.ContentType)
+	if err != nil {
+		respondError(w, "Can't store image", 500, err)
+		return
+	}
+	err = cfg.storage.Put(r.Context(), thumbnailKey, img.Thumbnail, img.ThumbnailType)
+	if err != nil {
+		cfg.deleteMediaFiles(r.Context(), storageKey)
+		respondError(w, "Can't store thumbnail", 500, err)
+		return
+	}
+
+	a, err := cfg.db.CreateAttachment(r.Context(), database.CreateAttachmentParams{
+		ID:           id,
+		UserID:       userID,
+		ContentType:  img.ContentType,
+		SizeBytes:    int64(len(img.Data)),
+		Width:        int32(img.Width),
+		Height:       int32(img.Height),
+		AltText:      altText,
+		StorageKey:   storageKey,
+		ThumbnailKey: thumbnailKey,
+	})
+	if err != nil {
+		cfg.deleteMediaFiles(r.Context(), storageKey, thumbnailKey)
+		respondError(w, "Can't save attachment", 500, err)
+		return
+	}
+
+	respondJSON(w, 201, attachmentJSON(a))
+}
+
+func (cfg *APIConfig) updateMediaHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		AltText string `json:"alt_text"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondError(w, "Can't parse mediaID", 400, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	if utf8.RuneCountInString(b.AltText) > maxAltTextLength {
+		respondError(w, "Alt text is too long", 400, nil)
+		return
+	}
+
+	a, err := cfg.db.UpdateAttachmentAltText(r.Context(), database.UpdateAttachmentAltTextParams{
+		AltText: b.AltText,
+		ID:      mediaID,
+		UserID:  userID,
+	})
+	if err != nil {
+		respondError(w, "Media not found", 404, err)
+		return
+	}
+
+	respondJSON(w, 200, attachmentJSON(a))
+}
+
+func (cfg *APIConfig) getMediaHandler(w http.ResponseWriter, r *http.Request) {
+	a, ok := cfg.visibleAttachment(w, r)
+	if !ok {
+		return
+	}
+	cfg.serveMedia(w, r, a.StorageKey, a.ContentType)
+}
+
+func (cfg *APIConfig) getMediaThumbnailHandler(w http.ResponseWriter, r *http.Request) {
+	a, ok := cfg.visibleAttachment(w, r)
+	if !ok {
+		return
+	}
+	// Thumbnails of JPEGs are JPEGs, everything else gets a PNG thumbnail.
+	contentType := "image/png"
+	if a.ContentType == "image/jpeg" {
+		contentType = "image/jpeg"
+	}
+	cfg.serveMedia(w, r, a.ThumbnailKey, contentType)
+}
+
+// visibleAttachment returns the attachment in the path if the viewer can see
+// the chirp it is attached to. Unattached uploads are only visible to the
+// user who uploaded them.
+func (cfg *APIConfig) visibleAttachment(w http.ResponseWriter, r *http.Request) (database.Attachment, bool) {
+	mediaID, err := uuid.Parse(r.PathValue("mediaID"))
+	if err != nil {
+		respondError(w, "Can't parse mediaID", 400, err)
+		return database.Attachment{}, false
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return database.Attachment{}, false
+	}
+
+	a, err := cfg.db.GetAttachment(r.Context(), mediaID)
+	if err != nil {
+		respondError(w, "Media not found", 404, err)
+		return database.Attachment{}, false
+	}
+
+	if !a.ChirpID.Valid {
+		if a.UserID != viewerID {
+			respondError(w, "Media not found", 404, nil)
+			return database.Attachment{}, false
+		}
+		return a, true
+	}
+
+	_, err = cfg.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       a.ChirpID.UUID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		respondError(w, "Media not found", 404, err)
+		return database.Attachment{}, false
+	}
+
+	return a, true
+}
+
+func (cfg *APIConfig) serveMedia(w http.ResponseWriter, r *http.Request, key, contentType string) {
+	rc, err := cfg.storage.Get(r.Context(), key)
+	if err != nil {
+		respondError(w, "Can't get media", 500, err)
+		return
+	}
+	defer rc.Close()
+
+	w.Header().Set("Content-Type", contentType)
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+	w.Header().Set("Cache-Control", "private, max-age=3600")
+	w.WriteHeader(200)
+	io.Copy(w, rc)
+}
+
+// loadAttachments fills in the attachments of chirps.
+func (cfg *APIConfig) loadAttachments(ctx context.Context, chirps []Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(chirps))
+	index := make(map[uuid.UUID]int, len(chirps))
+	for i, ch := range chirps {
+		ids[i] = ch.ID
+		index[ch.ID] = i
+	}
+
+	attachments, err := cfg.db.GetChirpAttachments(ctx, ids)
+	if err != nil {
+		return err
+	}
+	for _, a := range attachments {
+		i := index[a.ChirpID.UUID]
+		chirps[i].Attachments = append(chirps[i].Attachments, attachmentJSON(a))
+	}
+
+	return nil
+}
+
+// chirpAttachments returns the attachments of a single chirp in order.
+func chirpAttachments(ctx context.Context, q *database.Queries, chirpID uuid.UUID) ([]Attachment, error) {
+	attachments, err := q.GetChirpAttachments(ctx, []uuid.UUID{chirpID})
+	if err != nil {
+		return nil, err
+	}
+	aa := make([]Attachment, len(attachments))
+	for i, a := range attachments {
+		aa[i] = attachmentJSON(a)
+	}
+	return aa, nil
+}
+
+func (cfg *APIConfig) deleteMediaFiles(ctx context.Context, keys ...string) error {
+	for _, key := range keys {
+		err := cfg.storage.Delete(ctx, key)
+		if err != nil {
+			log.Printf("Can't delete media file %s: %v", key, err)
+			return err
+		}
+	}
+	return nil
+}
+
+// purgeAttachments removes uploads that were never attached to a chirp, and
+// the files of chirps and accounts about to be purged, which would otherwise
+// be left behind when their rows cascade away.
+func (cfg *APIConfig) purgeAttachments(ctx context.Context, deletedBefore time.Time) {
+	for {
+		attachments, err := cfg.db.GetPurgeableAttachments(ctx, database.GetPurgeableAttachmentsParams{
+			UnattachedBefore: time.Now().UTC().Add(-unattachedMediaTTL),
+			DeletedBefore:    deletedBefore,
+			MaxAttachments:   mediaPurgeBatch,
+		})
+		if err != nil {
+			log.Printf("Can't get purgeable attachments: %v", err)
+			return
+		}
+
+		for _, a := range attachments {
+			// Keep the row if the files can't be removed so the next run
+			// tries again.
+			err = cfg.deleteMediaFiles(ctx, a.StorageKey, a.ThumbnailKey)
+			if err != nil {
+				return
+			}
+			err = cfg.db.DeleteAttachment(ctx, a.ID)
+			if err != nil {
+				log.Printf("Can't delete attachment %s: %v", a.ID, err)
+				return
+			}
+		}
+
+		if len(attachments) < mediaPurgeBatch {
+			return
+		}
+	}
+}
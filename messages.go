@@ -0,0 +1,665 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/events"
+	"github.com/aobatake/goserver/internal/validate"
+	"github.com/google/uuid"
+)
+
+const (
+	maxConversationMembers = 10
+	maxMessageLength       = 2000
+	maxMessageBytes        = 4 * maxMessageLength
+)
+
+const (
+	DMPolicyEveryone = "everyone"
+	DMPolicyNobody   = "nobody"
+)
+
+type ConversationMember struct {
+	UserID     uuid.UUID  `json:"user_id"`
+	JoinedAt   time.Time  `json:"joined_at"`
+	LastReadAt *time.Time `json:"last_read_at"`
+}
+
+type Conversation struct {
+	ID          uuid.UUID            `json:"id"`
+	CreatedAt   time.Time            `json:"created_at"`
+	UpdatedAt   time.Time            `json:"updated_at"`
+	CreatedBy   uuid.UUID            `json:"created_by"`
+	IsGroup     bool                 `json:"is_group"`
+	UnreadCount int64                `json:"unread_count"`
+	Members     []ConversationMember `json:"members,omitempty"`
+}
+
+type Message struct {
+	ID             uuid.UUID `json:"id"`
+	CreatedAt      time.Time `json:"created_at"`
+	ConversationID uuid.UUID `json:"conversation_id"`
+	SenderID       uuid.UUID `json:"sender_id"`
+	Body           string    `json:"body"`
+}
+
+// conversationMember returns the caller's membership of the conversation in
+// the path, responding with 404 if they aren't a member so the existence of
+// other conversations isn't revealed.
+func (cfg *APIConfig) conversationMember(w http.ResponseWriter, r *http.Request, userID uuid.UUID) (database.ConversationMember, bool) {
+	conversationID, err := uuid.Parse(r.PathValue("conversationID"))
+	if err != nil {
+		respondError(w, "Can't parse conversationID", 400, err)
+		return database.ConversationMember{}, false
+	}
+
+	member, err := cfg.db.GetConversationMember(r.Context(), database.GetConversationMemberParams{
+		ConversationID: conversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondError(w, "Can't get conversation", 404, err)
+		return database.ConversationMember{}, false
+	}
+	return member, true
+}
+
+// mayMessage reports whether userID may still send messages to members.
+// Nobody in the conversation may have blocked userID or been blocked by
+// them, and the other member of a direct conversation must still accept
+// direct messages.
+func (cfg *APIConfig) mayMessage(ctx context.Context, userID uuid.UUID, members []ConversationMember) (bool, error) {
+	for _, m := range members {
+		if m.UserID == userID {
+			continue
+		}
+		for _, params := range []database.IsBlockedParams{
+			{BlockerID: m.UserID, BlockedID: userID},
+			{BlockerID: userID, BlockedID: m.UserID},
+		} {
+			blocked, err := cfg.db.IsBlocked(ctx, params)
+			if err != nil {
+				return false, err
+			}
+			if blocked {
+				return false, nil
+			}
+		}
+
+		if len(members) == 2 {
+			other, err := cfg.db.GetUserByID(ctx, m.UserID)
+			if err != nil {
+				return false, err
+			}
+			if other.DmPolicy == DMPolicyNobody {
+				return false, nil
+			}
+		}
+	}
+	return true, nil
+}
+
+func (cfg *APIConfig) conversationMembers(ctx context.Context, conversationID uuid.UUID) ([]ConversationMember, error) {
+	members, err := cfg.db.GetConversationMembers(ctx, conversationID)
+	if err != nil {
+		return nil, err
+	}
+	mm := make([]ConversationMember, len(members))
+	for i, m := range members {
+		mm[i] = ConversationMember{
+			UserID:   m.UserID,
+			JoinedAt: m.JoinedAt,
+		}
+		if m.LastReadAt.Valid {
+			mm[i].LastReadAt = &m.LastReadAt.Time
+		}
+	}
+	return mm, nil
+}
+
+// publishToMembers pushes a realtime event to every member except userID.
+func (cfg *APIConfig) publishToMembers(ctx context.Context, eventType string, userID uuid.UUID, members []ConversationMember, payload any) {
+	for _, m := range members {
+		if m.UserID == userID {
+			continue
+		}
+		cfg.publishRealtimeEvent(ctx, eventType, "messages:"+m.UserID.String(), userID, payload)
+	}
+}
+
+func (cfg *APIConfig) createConversationHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		MemberIDs []uuid.UUID `json:"member_ids"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+	if !cfg.requireCanPost(w, r, userID) {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+
+	memberIDs := []uuid.UUID{userID}
+	seen := map[uuid.UUID]bool{userID: true}
+	for _, id := range b.MemberIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		memberIDs = append(memberIDs, id)
+	}
+	if len(memberIDs) < 2 {
+		respondError(w, "A conversation needs at least one other member", 400, nil)
+		return
+	}
+	if len(memberIDs) > maxConversationMembers {
+		respondError(w, "Too many conversation members", 400, nil)
+		return
+	}
+
+	for _, id := range memberIDs[1:] {
+		user, err := cfg.db.GetUserByID(r.Context(), id)
+		if err != nil {
+			respondError(w, "Can't get user", 404, err)
+			return
+		}
+		if user.DmPolicy == DMPolicyNobody {
+			respondError(w, "User doesn't accept direct messages", 403, nil)
+			return
+		}
+		blocked, err := cfg.db.IsBlocked(r.Context(), database.IsBlockedParams{
+			BlockerID: id,
+			BlockedID: userID,
+		})
+		if err != nil {
+			respondError(w, "Can't check blocks", 500, err)
+			return
+		}
+		if blocked {
+			respondError(w, "User doesn't accept direct messages", 403, nil)
+			return
+		}
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, "Can't create conversation", 500, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	isGroup := len(memberIDs) > 2
+	if !isGroup {
+		// Two people have a single direct conversation, so concurrent
+		// requests for the same pair wait for each other here.
+		err = qtx.LockDirectConversation(r.Context(), database.LockDirectConversationParams{
+			UserID:  userID,
+			OtherID: memberIDs[1],
+		})
+		if err != nil {
+			respondError(w, "Can't find conversation", 500, err)
+			return
+		}
+		existing, err := qtx.FindDirectConversation(r.Context(), database.FindDirectConversationParams{
+			UserID:  userID,
+			OtherID: memberIDs[1],
+		})
+		if err == nil {
+			err = qtx.UnhideConversation(r.Context(), existing.ID)
+			if err == nil {
+				err = tx.Commit()
+			}
+			if err != nil {
+				respondError(w, "Can't open conversation", 500, err)
+				return
+			}
+			members, err := cfg.conversationMembers(r.Context(), existing.ID)
+			if err != nil {
+				respondError(w, "Can't get conversation members", 500, err)
+				return
+			}
+			respondJSON(w, 200, Conversation{
+				ID:        existing.ID,
+				CreatedAt: existing.CreatedAt,
+				UpdatedAt: existing.UpdatedAt,
+				CreatedBy: existing.CreatedBy,
+				IsGroup:   existing.IsGroup,
+				Members:   members,
+			})
+			return
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			respondError(w, "Can't find conversation", 500, err)
+			return
+		}
+	}
+
+	conv, err := qtx.CreateConversation(r.Context(), database.CreateConversationParams{
+		CreatedBy: userID,
+		IsGroup:   isGroup,
+	})
+	if err != nil {
+		respondError(w, "Can't create conversation", 500, err)
+		return
+	}
+
+	err = qtx.AddConversationMembers(r.Context(), database.AddConversationMembersParams{
+		ConversationID: conv.ID,
+		UserIds:        memberIDs,
+	})
+	if err != nil {
+		respondError(w, "Can't add conversation members", 500, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondError(w, "Can't create conversation", 500, err)
+		return
+	}
+
+	members, err := cfg.conversationMembers(r.Context(), conv.ID)
+	if err != nil {
+		respondError(w, "Can't get conversation members", 500, err)
+		return
+	}
+
+	respondJSON(w, 201, Conversation{
+		ID:        conv.ID,
+		CreatedAt: conv.CreatedAt,
+		UpdatedAt: conv.UpdatedAt,
+		CreatedBy: conv.CreatedBy,
+		IsGroup:   conv.IsGroup,
+		Members:   members,
+	})
+}
+
+func (cfg *APIConfig) getConversationsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	convs, err := cfg.db.GetConversations(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get conversations", 500, err)
+		return
+	}
+
+	cc := make([]Conversation, len(convs))
+	for i, conv := range convs {
+		cc[i] = Conversation{
+			ID:          conv.ID,
+			CreatedAt:   conv.CreatedAt,
+			UpdatedAt:   conv.UpdatedAt,
+			CreatedBy:   conv.CreatedBy,
+			IsGroup:     conv.IsGroup,
+			UnreadCount: conv.UnreadCount,
+		}
+	}
+
+	respondJSON(w, 200, cc)
+}
+
+func (cfg *APIConfig) getConversationHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	member, ok := cfg.conversationMember(w, r, userID)
+	if !ok {
+		return
+	}
+
+	members, err := cfg.conversationMembers(r.Context(), member.ConversationID)
+	if err != nil {
+		respondError(w, "Can't get conversation members", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, Conversation{
+		ID:      member.ConversationID,
+		Members: members,
+	})
+}
+
+func (cfg *APIConfig) getMessagesHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	member, ok := cfg.conversationMember(w, r, userID)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 200 {
+			respondError(w, "limit must be between 1 and 200", 400, err)
+			return
+		}
+	}
+
+	before := time.Now().Add(time.Minute)
+	if b := r.URL.Query().Get("before"); b != "" {
+		before, err = time.Parse(time.RFC3339Nano, b)
+		if err != nil {
+			respondError(w, "Can't parse before", 400, err)
+			return
+		}
+	}
+
+	messages, err := cfg.db.GetMessages(r.Context(), database.GetMessagesParams{
+		UserID:         userID,
+		ConversationID: member.ConversationID,
+		Before:         before,
+		MaxMessages:    int32(limit),
+	})
+	if err != nil {
+		respondError(w, "Can't get messages", 500, err)
+		return
+	}
+
+	mm := make([]Message, len(messages))
+	for i, m := range messages {
+		mm[i] = Message{
+			ID:             m.ID,
+			CreatedAt:      m.CreatedAt,
+			ConversationID: m.ConversationID,
+			SenderID:       m.SenderID,
+			Body:           m.Body,
+		}
+	}
+
+	respondJSON(w, 200, mm)
+}
+
+func (cfg *APIConfig) sendMessageHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Body string `json:"body"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	member, ok := cfg.conversationMember(w, r, userID)
+	if !ok {
+		return
+	}
+	if !cfg.requireCanPost(w, r, userID) {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	body, err := validate.Text("body", b.Body, validate.Rules{
+		Required:  true,
+		MaxLength: maxMessageLength,
+		MaxBytes:  maxMessageBytes,
+		Multiline: true,
+	})
+	if err != nil {
+		respondValidationError(w, err)
+		return
+	}
+
+	members, err := cfg.conversationMembers(r.Context(), member.ConversationID)
+	if err != nil {
+		respondError(w, "Can't get conversation members", 500, err)
+		return
+	}
+	ok, err = cfg.mayMessage(r.Context(), userID, members)
+	if err != nil {
+		respondError(w, "Can't check blocks", 500, err)
+		return
+	}
+	if !ok {
+		respondError(w, "You can't message this conversation", 403, nil)
+		return
+	}
+
+	m, err := cfg.db.CreateMessage(r.Context(), database.CreateMessageParams{
+		ConversationID: member.ConversationID,
+		SenderID:       userID,
+		Body:           body,
+	})
+	if err != nil {
+		respondError(w, "Can't send message", 500, err)
+		return
+	}
+
+	err = cfg.db.TouchConversation(r.Context(), member.ConversationID)
+	if err == nil {
+		err = cfg.db.UnhideConversation(r.Context(), member.ConversationID)
+	}
+	if err == nil {
+		err = cfg.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+			ConversationID: member.ConversationID,
+			UserID:         userID,
+		})
+	}
+	if err != nil {
+		respondError(w, "Can't update conversation", 500, err)
+		return
+	}
+
+	msg := Message{
+		ID:             m.ID,
+		CreatedAt:      m.CreatedAt,
+		ConversationID: m.ConversationID,
+		SenderID:       m.SenderID,
+		Body:           m.Body,
+	}
+
+	cfg.publishToMembers(r.Context(), events.MessageCreated, userID, members, msg)
+
+	respondJSON(w, 201, msg)
+}
+
+func (cfg *APIConfig) markConversationReadHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	member, ok := cfg.conversationMember(w, r, userID)
+	if !ok {
+		return
+	}
+
+	err = cfg.db.MarkConversationRead(r.Context(), database.MarkConversationReadParams{
+		ConversationID: member.ConversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondError(w, "Can't mark conversation read", 500, err)
+		return
+	}
+
+	members, err := cfg.conversationMembers(r.Context(), member.ConversationID)
+	if err != nil {
+		respondError(w, "Can't get conversation members", 500, err)
+		return
+	}
+	cfg.publishToMembers(r.Context(), events.ConversationRead, userID, members, struct {
+		ConversationID uuid.UUID `json:"conversation_id"`
+		UserID         uuid.UUID `json:"user_id"`
+		LastReadAt     time.Time `json:"last_read_at"`
+	}{
+		ConversationID: member.ConversationID,
+		UserID:         userID,
+		LastReadAt:     time.Now().UTC(),
+	})
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) deleteConversationHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	member, ok := cfg.conversationMember(w, r, userID)
+	if !ok {
+		return
+	}
+
+	// Deleting only hides the conversation and its history for this user;
+	// other members keep their copy.
+	err = cfg.db.HideConversation(r.Context(), database.HideConversationParams{
+		ConversationID: member.ConversationID,
+		UserID:         userID,
+	})
+	if err != nil {
+		respondError(w, "Can't delete conversation", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) getDMSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get user", 404, err)
+		return
+	}
+
+	respondJSON(w, 200, struct {
+		AllowMessagesFrom string `json:"allow_messages_from"`
+	}{
+		AllowMessagesFrom: user.DmPolicy,
+	})
+}
+
+func (cfg *APIConfig) updateDMSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		AllowMessagesFrom string `json:"allow_messages_from"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	if b.AllowMessagesFrom != DMPolicyEveryone && b.AllowMessagesFrom != DMPolicyNobody {
+		respondError(w, "allow_messages_from must be everyone or nobody", 400, nil)
+		return
+	}
+
+	err = cfg.db.UpdateDMPolicy(r.Context(), database.UpdateDMPolicyParams{
+		DmPolicy: b.AllowMessagesFrom,
+		ID:       userID,
+	})
+	if err != nil {
+		respondError(w, "Can't update direct message settings", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, b)
+}
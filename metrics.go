@@ -0,0 +1,185 @@
+package main
+
+import (
+	"bufio"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Sources of created chirps.
+const (
+	ChirpSourceAPI    = "api"
+	ChirpSourceDraft  = "draft"
+	ChirpSourceImport = "import"
+)
+
+// serverMetrics are the metrics served on /metrics in the Prometheus
+// exposition format.
+type serverMetrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	requestsInFlight prometheus.Gauge
+	chirpsCreated    *prometheus.CounterVec
+	logins           *prometheus.CounterVec
+	webhooks         *prometheus.CounterVec
+}
+
+// newServerMetrics registers the server's metrics, along with the Go
+// runtime's, the process's and the stats of the db connection pool.
+// fileserverHits reports the hits counted for the admin page.
+func newServerMetrics(db *sql.DB, fileserverHits func() float64) *serverMetrics {
+	m := &serverMetrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_http_requests_total",
+			Help: "HTTP requests by route pattern and status code.",
+		}, []string{"route", "code"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "chirpy_http_request_duration_seconds",
+			Help:    "How long HTTP requests took to serve, by route pattern and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, []string{"route", "code"}),
+		requestsInFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "chirpy_http_requests_in_flight",
+			Help: "HTTP requests being served.",
+		}),
+		chirpsCreated: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_chirps_created_total",
+			Help: "Chirps created, by where they came from: api, draft or import.",
+		}, []string{"source"}),
+		logins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_logins_total",
+			Help: "Login attempts by outcome: succeeded or failed.",
+		}, []string{"outcome"}),
+		webhooks: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "chirpy_polka_webhooks_total",
+			Help: "Polka webhooks by outcome.",
+		}, []string{"outcome"}),
+	}
+
+	m.registry.MustRegister(
+		m.requests,
+		m.requestDuration,
+		m.requestsInFlight,
+		m.chirpsCreated,
+		m.logins,
+		m.webhooks,
+		prometheus.NewCounterFunc(prometheus.CounterOpts{
+			Name: "chirpy_fileserver_hits_total",
+			Help: "Requests for the app's static files since the last reset.",
+		}, fileserverHits),
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		collectors.NewDBStatsCollector(db, "chirpy"),
+	)
+	return m
+}
+
+// handler serves the metrics to requests bearing token, so only the scraper
+// can read them.
+func (m *serverMetrics) handler(token string) http.Handler {
+	h := promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got, err := auth.GetBearerToken(r.Header)
+		if err != nil || subtle.ConstantTimeCompare([]byte(got), []byte(token)) != 1 {
+			respondError(w, "Unauthorized", 401, err)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// middleware counts and times the requests mux serves, labelled with the
+// pattern of the route they match. Requests matching no route are labelled
+// "unmatched", so clients can't create new series by requesting made-up
+// paths.
+func (m *serverMetrics) middleware(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, route := mux.Handler(r)
+		if route == "" {
+			route = "unmatched"
+		}
+
+		m.requestsInFlight.Inc()
+		defer m.requestsInFlight.Dec()
+
+		start := time.Now()
+		sw := &statusWriter{ResponseWriter: w}
+		next.ServeHTTP(sw, r)
+
+		code := strconv.Itoa(sw.status())
+		m.requests.WithLabelValues(route, code).Inc()
+		m.requestDuration.WithLabelValues(route, code).Observe(time.Since(start).Seconds())
+	})
+}
+
+// statusWriter records the status code of a response. It passes flushes
+// and hijacks through for the event stream and WebSocket connections.
+type statusWriter struct {
+	http.ResponseWriter
+	code     int
+	hijacked bool
+}
+
+func (w *statusWriter) WriteHeader(code int) {
+	if w.code == 0 {
+		w.code = code
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *statusWriter) Write(b []byte) (int, error) {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	return w.ResponseWriter.Write(b)
+}
+
+func (w *statusWriter) Flush() {
+	if w.code == 0 {
+		w.code = http.StatusOK
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *statusWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	hj, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("Response can't be hijacked")
+	}
+	conn, brw, err := hj.Hijack()
+	if err == nil {
+		w.hijacked = true
+	}
+	return conn, brw, err
+}
+
+func (w *statusWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// status is the status code sent: 101 for hijacked connections, which
+// upgrade to WebSocket, and 200 if the handler sent nothing.
+func (w *statusWriter) status() int {
+	switch {
+	case w.hijacked:
+		return http.StatusSwitchingProtocols
+	case w.code == 0:
+		return http.StatusOK
+	}
+	return w.code
+}
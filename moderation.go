@@ -0,0 +1,512 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/events"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const (
+	RoleUser      = "user"
+	RoleModerator = "moderator"
+	RoleAdmin     = "admin"
+)
+
+const (
+	ActionDismiss       = "dismiss"
+	ActionHideChirp     = "hide_chirp"
+	ActionSuspendUser   = "suspend_user"
+	ActionMarkSensitive = "mark_sensitive"
+)
+
+var reportReasons = map[string]bool{
+	"spam":           true,
+	"harassment":     true,
+	"hate":           true,
+	"violence":       true,
+	"sexual":         true,
+	"self_harm":      true,
+	"misinformation": true,
+	"other":          true,
+}
+
+const maxReportDetails = 1000
+
+type Report struct {
+	ID         uuid.UUID  `json:"id"`
+	CreatedAt  time.Time  `json:"created_at"`
+	UpdatedAt  time.Time  `json:"updated_at"`
+	ReporterID uuid.UUID  `json:"reporter_id"`
+	UserID     uuid.UUID  `json:"user_id"`
+	ChirpID    *uuid.UUID `json:"chirp_id,omitempty"`
+	Reason     string     `json:"reason"`
+	Details    string     `json:"details"`
+	Status     string     `json:"status"`
+	ClaimedBy  *uuid.UUID `json:"claimed_by,omitempty"`
+}
+
+type ModerationDecision struct {
+	ID             uuid.UUID  `json:"id"`
+	CreatedAt      time.Time  `json:"created_at"`
+	ReportID       uuid.UUID  `json:"report_id"`
+	ModeratorID    uuid.UUID  `json:"moderator_id"`
+	Action         string     `json:"action"`
+	Note           string     `json:"note"`
+	UserID         uuid.UUID  `json:"user_id"`
+	ChirpID        *uuid.UUID `json:"chirp_id,omitempty"`
+	ChirpBody      string     `json:"chirp_body,omitempty"`
+	Reason         string     `json:"reason"`
+	SuspendedUntil *time.Time `json:"suspended_until,omitempty"`
+}
+
+func reportJSON(r database.Report) Report {
+	return Report{
+		ID:         r.ID,
+		CreatedAt:  r.CreatedAt,
+		UpdatedAt:  r.UpdatedAt,
+		ReporterID: r.ReporterID,
+		UserID:     r.UserID,
+		ChirpID:    nullUUIDPtr(r.ChirpID),
+		Reason:     r.Reason,
+		Details:    r.Details,
+		Status:     r.Status,
+		ClaimedBy:  nullUUIDPtr(r.ClaimedBy),
+	}
+}
+
+func decisionJSON(d database.ModerationDecision) ModerationDecision {
+	md := ModerationDecision{
+		ID:          d.ID,
+		CreatedAt:   d.CreatedAt,
+		ReportID:    d.ReportID,
+		ModeratorID: d.ModeratorID,
+		Action:      d.Action,
+		Note:        d.Note,
+		UserID:      d.UserID,
+		ChirpID:     nullUUIDPtr(d.ChirpID),
+		ChirpBody:   d.ChirpBody.String,
+		Reason:      d.Reason,
+	}
+	if d.SuspendedUntil.Valid {
+		md.SuspendedUntil = &d.SuspendedUntil.Time
+	}
+	return md
+}
+
+// requireModerator authenticates the request and checks the caller is a
+// moderator or admin.
+func (cfg *APIConfig) requireModerator(w http.ResponseWriter, r *http.Request) (database.User, bool) {
+	return cfg.requireRole(w, r, RoleModerator, RoleAdmin)
+}
+
+// requireRole authenticates the request and checks the caller has one of roles.
+func (cfg *APIConfig) requireRole(w http.ResponseWriter, r *http.Request, roles ...string) (database.User, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return database.User{}, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return database.User{}, false
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return database.User{}, false
+	}
+	if !slices.Contains(roles, user.Role) {
+		respondError(w, "403 Forbidden", 403, nil)
+		return database.User{}, false
+	}
+
+	return user, true
+}
+
+func (cfg *APIConfig) createReport(w http.ResponseWriter, r *http.Request, reporterID, userID uuid.UUID, chirpID uuid.NullUUID) {
+	type reqBody struct {
+		Reason  string `json:"reason"`
+		Details string `json:"details"`
+	}
+
+	if !cfg.requireCanPost(w, r, reporterID) {
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err := decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	if !reportReasons[b.Reason] {
+		respondError(w, fmt.Sprintf("Unknown report reason %q", b.Reason), 400, nil)
+		return
+	}
+	if len(b.Details) > maxReportDetails {
+		respondError(w, "Report details are too long", 400, nil)
+		return
+	}
+
+	report, err := cfg.db.CreateReport(r.Context(), database.CreateReportParams{
+		ReporterID: reporterID,
+		UserID:     userID,
+		ChirpID:    chirpID,
+		Reason:     b.Reason,
+		Details:    b.Details,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		respondError(w, "You already reported this", 409, err)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't create report", 500, err)
+		return
+	}
+
+	respondJSON(w, 201, reportJSON(report))
+}
+
+func (cfg *APIConfig) reportChirpHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, "Can't parse chirpID", 400, err)
+		return
+	}
+
+	ch, err := cfg.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if err != nil {
+		respondError(w, "Can't get chirp", 404, err)
+		return
+	}
+	if ch.UserID == userID {
+		respondError(w, "Can't report your own chirp", 400, nil)
+		return
+	}
+
+	cfg.createReport(w, r, userID, ch.UserID, uuid.NullUUID{UUID: ch.ID, Valid: true})
+}
+
+func (cfg *APIConfig) reportUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	cfg.createReport(w, r, userID, targetID, uuid.NullUUID{})
+}
+
+func (cfg *APIConfig) getReportsHandler(w http.ResponseWriter, r *http.Request) {
+	_, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	status := r.URL.Query().Get("status")
+	if status == "" {
+		status = "open"
+	}
+
+	limit := 50
+	var err error
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 200 {
+			respondError(w, "limit must be between 1 and 200", 400, err)
+			return
+		}
+	}
+
+	after := time.Time{}
+	if a := r.URL.Query().Get("after"); a != "" {
+		after, err = time.Parse(time.RFC3339Nano, a)
+		if err != nil {
+			respondError(w, "Can't parse after", 400, err)
+			return
+		}
+	}
+
+	reports, err := cfg.db.GetReports(r.Context(), database.GetReportsParams{
+		Status:     status,
+		After:      after,
+		MaxReports: int32(limit),
+	})
+	if err != nil {
+		respondError(w, "Can't get reports", 500, err)
+		return
+	}
+
+	rr := make([]Report, len(reports))
+	for i, report := range reports {
+		rr[i] = reportJSON(report)
+	}
+
+	respondJSON(w, 200, rr)
+}
+
+func (cfg *APIConfig) claimReportHandler(w http.ResponseWriter, r *http.Request) {
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondError(w, "Can't parse reportID", 400, err)
+		return
+	}
+
+	report, err := cfg.db.ClaimReport(r.Context(), database.ClaimReportParams{
+		ModeratorID: uuid.NullUUID{UUID: moderator.ID, Valid: true},
+		ID:          reportID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		_, err = cfg.db.GetReport(r.Context(), reportID)
+		if err != nil {
+			respondError(w, "Can't get report", 404, err)
+			return
+		}
+		respondError(w, "Report is already claimed or resolved", 409, nil)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't claim report", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, reportJSON(report))
+}
+
+func (cfg *APIConfig) resolveReportHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		Action         string `json:"action"`
+		Note           string `json:"note"`
+		SuspendHours   int    `json:"suspend_hours"`
+		ContentWarning string `json:"content_warning"`
+	}
+
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	reportID, err := uuid.Parse(r.PathValue("reportID"))
+	if err != nil {
+		respondError(w, "Can't parse reportID", 400, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+
+	report, err := cfg.db.GetReport(r.Context(), reportID)
+	if err != nil {
+		respondError(w, "Can't get report", 404, err)
+		return
+	}
+	if report.Status != "claimed" || report.ClaimedBy.UUID != moderator.ID {
+		respondError(w, "Report must be claimed by you before resolving", 409, nil)
+		return
+	}
+
+	var chirpBody sql.NullString
+	if report.ChirpID.Valid {
+		ch, err := cfg.db.GetChirp(r.Context(), report.ChirpID.UUID)
+		if err == nil {
+			chirpBody = sql.NullString{String: ch.Body, Valid: true}
+		}
+	}
+
+	var suspendedUntil sql.NullTime
+	var warning sql.NullString
+	switch b.Action {
+	case ActionDismiss:
+	case ActionHideChirp:
+		if !report.ChirpID.Valid {
+			respondError(w, "Report is not about a chirp", 400, nil)
+			return
+		}
+	case ActionMarkSensitive:
+		if !report.ChirpID.Valid {
+			respondError(w, "Report is not about a chirp", 400, nil)
+			return
+		}
+		warning, err = contentWarning(b.ContentWarning)
+		if err != nil {
+			respondError(w, err.Error(), 400, err)
+			return
+		}
+	case ActionSuspendUser:
+		if b.SuspendHours < 1 || b.SuspendHours > 24*365 {
+			respondError(w, "suspend_hours must be between 1 and 8760", 400, nil)
+			return
+		}
+		suspendedUntil = sql.NullTime{
+			Time:  time.Now().UTC().Add(time.Duration(b.SuspendHours) * time.Hour),
+			Valid: true,
+		}
+	default:
+		respondError(w, fmt.Sprintf("Unknown action %q", b.Action), 400, nil)
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, "Can't resolve report", 500, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	switch b.Action {
+	case ActionHideChirp:
+		err = qtx.HideChirp(r.Context(), report.ChirpID.UUID)
+	case ActionMarkSensitive:
+		err = qtx.MarkChirpSensitive(r.Context(), database.MarkChirpSensitiveParams{
+			ContentWarning: warning,
+			ID:             report.ChirpID.UUID,
+		})
+	case ActionSuspendUser:
+		err = qtx.SuspendUser(r.Context(), database.SuspendUserParams{
+			SuspendedUntil:   suspendedUntil,
+			SuspensionReason: sql.NullString{String: report.Reason, Valid: true},
+			ID:               report.UserID,
+		})
+	}
+	if err != nil {
+		respondError(w, "Can't apply moderation action", 500, err)
+		return
+	}
+
+	decision, err := qtx.CreateModerationDecision(r.Context(), database.CreateModerationDecisionParams{
+		ReportID:       report.ID,
+		ModeratorID:    moderator.ID,
+		Action:         b.Action,
+		Note:           b.Note,
+		UserID:         report.UserID,
+		ChirpID:        report.ChirpID,
+		ChirpBody:      chirpBody,
+		Reason:         report.Reason,
+		SuspendedUntil: suspendedUntil,
+	})
+	if err != nil {
+		respondError(w, "Can't record moderation decision", 500, err)
+		return
+	}
+
+	// Hiding or dismissing a chirp reported as spam trains the spam
+	// classifier.
+	trainSpam := report.Reason == "spam" && chirpBody.Valid &&
+		(b.Action == ActionHideChirp || b.Action == ActionDismiss)
+	if trainSpam {
+		err = saveSpamExample(r.Context(), qtx, report.ChirpID.UUID, chirpBody.String, b.Action == ActionHideChirp)
+		if err != nil {
+			respondError(w, "Can't record moderation decision", 500, err)
+			return
+		}
+	}
+
+	err = qtx.ResolveReport(r.Context(), report.ID)
+	if err != nil {
+		respondError(w, "Can't resolve report", 500, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondError(w, "Can't resolve report", 500, err)
+		return
+	}
+	if trainSpam {
+		cfg.spamClassifier.Train(chirpBody.String, b.Action == ActionHideChirp)
+	}
+
+	if b.Action == ActionHideChirp {
+		cfg.publishChirpEvent(r.Context(), events.ChirpDeleted, Chirp{
+			ID:      report.ChirpID.UUID,
+			User_id: report.UserID,
+		})
+	}
+
+	cfg.recordAudit(r, moderator.ID, AuditModerationDecision, "report", report.ID.String(), map[string]string{
+		"action":  b.Action,
+		"user_id": report.UserID.String(),
+	})
+
+	respondJSON(w, 200, decisionJSON(decision))
+}
+
+func (cfg *APIConfig) getModerationDecisionsHandler(w http.ResponseWriter, r *http.Request) {
+	_, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	params := database.GetModerationDecisionsParams{}
+	var err error
+	if id := r.URL.Query().Get("report_id"); id != "" {
+		params.ReportID, err = uuid.Parse(id)
+		if err != nil {
+			respondError(w, "Can't parse report_id", 400, err)
+			return
+		}
+		params.FilterByReportID = true
+	}
+	if id := r.URL.Query().Get("user_id"); id != "" {
+		params.UserID, err = uuid.Parse(id)
+		if err != nil {
+			respondError(w, "Can't parse user_id", 400, err)
+			return
+		}
+		params.FilterByUserID = true
+	}
+
+	decisions, err := cfg.db.GetModerationDecisions(r.Context(), params)
+	if err != nil {
+		respondError(w, "Can't get moderation decisions", 500, err)
+		return
+	}
+
+	dd := make([]ModerationDecision, len(decisions))
+	for i, d := range decisions {
+		dd[i] = decisionJSON(d)
+	}
+
+	respondJSON(w, 200, dd)
+}
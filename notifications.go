@@ -0,0 +1,419 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	NotificationMention       = "mention"
+	NotificationFollow        = "follow"
+	NotificationFollowRequest = "follow_request"
+	NotificationAccount       = "account"
+	NotificationPollClosed    = "poll_closed"
+)
+
+var notificationTypes = []string{
+	NotificationMention,
+	NotificationFollow,
+	NotificationFollowRequest,
+	NotificationAccount,
+	NotificationPollClosed,
+}
+
+// maxMentions caps how many users a single chirp can notify.
+const maxMentions = 10
+
+// NotificationGroup is the notifications of one type about the same chirp,
+// read or unread. Notifications without a chirp, such as follows, are
+// grouped by the day they arrived. Notifications about chirps the recipient
+// can't see any more are left out.
+type NotificationGroup struct {
+	Type       string      `json:"type"`
+	ChirpID    *uuid.UUID  `json:"chirp_id,omitempty"`
+	IDs        []uuid.UUID `json:"ids"`
+	ActorIDs   []uuid.UUID `json:"actor_ids"`
+	ActorCount int64       `json:"actor_count"`
+	Count      int         `json:"count"`
+	Read       bool        `json:"read"`
+	Summary    string      `json:"summary"`
+	LatestAt   time.Time   `json:"latest_at"`
+}
+
+type Notification struct {
+	ID        uuid.UUID  `json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	Type      string     `json:"type"`
+	ActorID   *uuid.UUID `json:"actor_id,omitempty"`
+	ChirpID   *uuid.UUID `json:"chirp_id,omitempty"`
+}
+
+func nullUUIDPtr(id uuid.NullUUID) *uuid.UUID {
+	if !id.Valid {
+		return nil
+	}
+	return &id.UUID
+}
+
+func notificationSummary(notificationType string, actors int64) string {
+	who := "Someone"
+	if actors > 1 {
+		who = fmt.Sprintf("%d people", actors)
+	}
+	switch notificationType {
+	case NotificationMention:
+		return who + " mentioned you"
+	case NotificationFollow:
+		return who + " followed you"
+	case NotificationFollowRequest:
+		return who + " asked to follow you"
+	case NotificationAccount:
+		return "Chirpy Red is now active on your account"
+	case NotificationPollClosed:
+		return "Your poll has ended"
+	}
+	return "You have a new notification"
+}
+
+// notify stores a notification unless the recipient has turned that type off
+// and pushes it to the recipient's realtime connections.
+func (cfg *APIConfig) notify(ctx context.Context, userID uuid.UUID, notificationType string, actorID, chirpID uuid.NullUUID) {
+	n, err := cfg.db.CreateNotification(ctx, database.CreateNotificationParams{
+		ActorID: actorID,
+		Type:    notificationType,
+		ChirpID: chirpID,
+		UserID:  userID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		return
+	}
+	if err != nil {
+		log.Printf("Can't create %s notification: %v", notificationType, err)
+		return
+	}
+
+	cfg.publishNotification(ctx, n)
+}
+
+// publishNotification pushes a stored notification to its recipient's
+// realtime connections.
+func (cfg *APIConfig) publishNotification(ctx context.Context, n database.Notification) {
+	cfg.publishRealtimeEvent(ctx, events.NotificationCreated, "notifications:"+n.UserID.String(), n.UserID, Notification{
+		ID:        n.ID,
+		CreatedAt: n.CreatedAt,
+		Type:      n.Type,
+		ActorID:   nullUUIDPtr(n.ActorID),
+		ChirpID:   nullUUIDPtr(n.ChirpID),
+	})
+}
+
+// mentionedUsers returns the users mentioned as @email in a chirp body,
+// other than its author.
+func mentionedUsers(ctx context.Context, q *database.Queries, authorID uuid.UUID, body string) ([]uuid.UUID, error) {
+	seen := map[uuid.UUID]bool{authorID: true}
+	var mentioned []uuid.UUID
+	for _, word := range strings.Fields(body) {
+		if len(mentioned) >= maxMentions {
+			break
+		}
+		if !strings.HasPrefix(word, "@") {
+			continue
+		}
+		email := strings.TrimRight(word[1:], ".,:;!?)")
+		if email == "" {
+			continue
+		}
+
+		user, err := q.GetUser(ctx, email)
+		if errors.Is(err, sql.ErrNoRows) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		if seen[user.ID] {
+			continue
+		}
+		seen[user.ID] = true
+		mentioned = append(mentioned, user.ID)
+	}
+	return mentioned, nil
+}
+
+// notifyMentions notifies every user mentioned in a chirp who can see it.
+func (cfg *APIConfig) notifyMentions(ctx context.Context, ch Chirp) {
+	mentioned, err := mentionedUsers(ctx, cfg.db, ch.User_id, ch.Body)
+	if err != nil {
+		log.Printf("Can't find mentioned users: %v", err)
+		return
+	}
+
+	for _, userID := range mentioned {
+		// A followers-only chirp can mention someone who doesn't follow the
+		// author; they aren't told about a chirp they can't open.
+		_, err := cfg.db.GetVisibleChirp(ctx, database.GetVisibleChirpParams{
+			ID:       ch.ID,
+			ViewerID: userID,
+		})
+		if err != nil {
+			continue
+		}
+
+		cfg.notify(ctx, userID, NotificationMention,
+			uuid.NullUUID{UUID: ch.User_id, Valid: true},
+			uuid.NullUUID{UUID: ch.ID, Valid: true},
+		)
+	}
+}
+
+func (cfg *APIConfig) getNotificationsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	limit := 20
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 100 {
+			respondError(w, "limit must be between 1 and 100", 400, err)
+			return
+		}
+	}
+
+	before := time.Now().Add(time.Minute)
+	if b := r.URL.Query().Get("before"); b != "" {
+		before, err = time.Parse(time.RFC3339Nano, b)
+		if err != nil {
+			respondError(w, "Can't parse before", 400, err)
+			return
+		}
+	}
+
+	groups, err := cfg.db.GetNotificationGroups(r.Context(), database.GetNotificationGroupsParams{
+		UserID:     userID,
+		UnreadOnly: r.URL.Query().Get("unread") == "true",
+		Before:     before,
+		MaxGroups:  int32(limit),
+	})
+	if err != nil {
+		respondError(w, "Can't get notifications", 500, err)
+		return
+	}
+
+	unread, err := cfg.db.CountUnreadNotifications(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't count notifications", 500, err)
+		return
+	}
+
+	type response struct {
+		UnreadCount   int64               `json:"unread_count"`
+		Notifications []NotificationGroup `json:"notifications"`
+		NextCursor    string              `json:"next_cursor,omitempty"`
+	}
+
+	resp := response{
+		UnreadCount:   unread,
+		Notifications: make([]NotificationGroup, len(groups)),
+	}
+	for i, g := range groups {
+		resp.Notifications[i] = NotificationGroup{
+			Type:       g.Type,
+			ChirpID:    nullUUIDPtr(g.ChirpID),
+			IDs:        g.Ids,
+			ActorIDs:   g.ActorIds,
+			ActorCount: g.ActorCount,
+			Count:      len(g.Ids),
+			Read:       g.IsRead,
+			Summary:    notificationSummary(g.Type, g.ActorCount),
+			LatestAt:   g.LatestAt,
+		}
+	}
+	if len(groups) == limit {
+		resp.NextCursor = groups[len(groups)-1].LatestAt.Format(time.RFC3339Nano)
+	}
+
+	respondJSON(w, 200, resp)
+}
+
+func (cfg *APIConfig) markNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		NotificationIDs []uuid.UUID `json:"notification_ids"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+
+	err = cfg.db.MarkNotificationsRead(r.Context(), database.MarkNotificationsReadParams{
+		UserID: userID,
+		Ids:    b.NotificationIDs,
+	})
+	if err != nil {
+		respondError(w, "Can't mark notifications read", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) markAllNotificationsReadHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	err = cfg.db.MarkAllNotificationsRead(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't mark notifications read", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+// notificationPreferences fills in the default (enabled) for every type the
+// user hasn't set.
+func notificationPreferences(stored json.RawMessage) (map[string]bool, error) {
+	prefs := map[string]bool{}
+	err := json.Unmarshal(stored, &prefs)
+	if err != nil {
+		return nil, err
+	}
+	for _, t := range notificationTypes {
+		if _, ok := prefs[t]; !ok {
+			prefs[t] = true
+		}
+	}
+	return prefs, nil
+}
+
+func (cfg *APIConfig) getNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	stored, err := cfg.db.GetNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get notification preferences", 404, err)
+		return
+	}
+
+	prefs, err := notificationPreferences(stored)
+	if err != nil {
+		respondError(w, "Can't parse notification preferences", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, prefs)
+}
+
+func (cfg *APIConfig) updateNotificationPreferencesHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	update := map[string]bool{}
+	err = decoder.Decode(&update)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+
+	stored, err := cfg.db.GetNotificationPreferences(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get notification preferences", 404, err)
+		return
+	}
+	prefs, err := notificationPreferences(stored)
+	if err != nil {
+		respondError(w, "Can't parse notification preferences", 500, err)
+		return
+	}
+
+	for t, enabled := range update {
+		if _, ok := prefs[t]; !ok {
+			respondError(w, fmt.Sprintf("Unknown notification type %q", t), 400, nil)
+			return
+		}
+		prefs[t] = enabled
+	}
+
+	data, err := json.Marshal(prefs)
+	if err != nil {
+		respondError(w, "Can't encode notification preferences", 500, err)
+		return
+	}
+
+	_, err = cfg.db.UpdateNotificationPreferences(r.Context(), database.UpdateNotificationPreferencesParams{
+		NotificationPreferences: data,
+		ID:                      userID,
+	})
+	if err != nil {
+		respondError(w, "Can't update notification preferences", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, prefs)
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+	"github.com/lib/pq"
+)
+
+const maxPinnedChirps = 3
+
+type PinnedChirps struct {
+	ChirpIDs []uuid.UUID `json:"chirp_ids"`
+}
+
+func pinnedChirpsJSON(pins []database.PinnedChirp) PinnedChirps {
+	ids := make([]uuid.UUID, len(pins))
+	for i, p := range pins {
+		ids[i] = p.ChirpID
+	}
+	return PinnedChirps{ChirpIDs: ids}
+}
+
+// pinFirst moves the pinned chirps in cc to the front in pin order and marks
+// them as pinned. The rest keep their order.
+func pinFirst(cc []Chirp, pins []database.PinnedChirp) []Chirp {
+	if len(pins) == 0 {
+		return cc
+	}
+
+	position := make(map[uuid.UUID]int, len(pins))
+	for i, p := range pins {
+		position[p.ChirpID] = i
+	}
+
+	pinned := make([]*Chirp, len(pins))
+	rest := make([]Chirp, 0, len(cc))
+	for _, ch := range cc {
+		if i, ok := position[ch.ID]; ok {
+			ch.Pinned = true
+			pinned[i] = &ch
+			continue
+		}
+		rest = append(rest, ch)
+	}
+
+	out := make([]Chirp, 0, len(cc))
+	for _, ch := range pinned {
+		// Pins the viewer can't see aren't in cc.
+		if ch != nil {
+			out = append(out, *ch)
+		}
+	}
+	return append(out, rest...)
+}
+
+func (cfg *APIConfig) pinChirpHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, "Can't parse chirpID", 400, err)
+		return
+	}
+
+	ch, err := cfg.db.GetChirp(r.Context(), chirpID)
+	if err != nil {
+		respondError(w, "Can't get chirp", 404, err)
+		return
+	}
+	if ch.UserID != userID {
+		respondError(w, "User is not author of chirp", 403, nil)
+		return
+	}
+
+	_, err = cfg.db.PinChirp(r.Context(), database.PinChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+		MaxPins: maxPinnedChirps,
+	})
+	var pqErr *pq.Error
+	if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+		respondError(w, "Chirps are being pinned at the same time", 409, err)
+		return
+	}
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "Can't pin chirp", 500, err)
+		return
+	}
+
+	// Nothing is inserted if the chirp is already pinned or there is no room
+	// for another pin.
+	pins, err := cfg.db.GetPinnedChirps(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get pinned chirps", 500, err)
+		return
+	}
+	resp := pinnedChirpsJSON(pins)
+	for _, id := range resp.ChirpIDs {
+		if id == chirpID {
+			respondJSON(w, 200, resp)
+			return
+		}
+	}
+
+	respondError(w, "You can pin at most 3 chirps", 400, nil)
+}
+
+func (cfg *APIConfig) unpinChirpHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, "Can't parse chirpID", 400, err)
+		return
+	}
+
+	err = cfg.db.UnpinChirp(r.Context(), database.UnpinChirpParams{
+		UserID:  userID,
+		ChirpID: chirpID,
+	})
+	if err != nil {
+		respondError(w, "Can't unpin chirp", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) getPinnedChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	pins, err := cfg.db.GetPinnedChirps(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get pinned chirps", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, pinnedChirpsJSON(pins))
+}
+
+// reorderPinnedChirpsHandler puts the user's pinned chirps in the given
+// order. chirp_ids must list every pinned chirp exactly once.
+func (cfg *APIConfig) reorderPinnedChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := PinnedChirps{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, "Can't reorder pinned chirps", 500, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	n, err := qtx.ReorderPinnedChirps(r.Context(), database.ReorderPinnedChirpsParams{
+		ChirpIds: b.ChirpIDs,
+		UserID:   userID,
+	})
+	if err != nil {
+		respondError(w, "Can't reorder pinned chirps", 500, err)
+		return
+	}
+
+	pins, err := qtx.GetPinnedChirps(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get pinned chirps", 500, err)
+		return
+	}
+	// A duplicate ID would update fewer rows than it lists.
+	if int(n) != len(pins) || len(b.ChirpIDs) != len(pins) {
+		respondError(w, "chirp_ids must list every pinned chirp once", 400, nil)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondError(w, "Can't reorder pinned chirps", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, pinnedChirpsJSON(pins))
+}
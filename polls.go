@@ -0,0 +1,297 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+)
+
+const (
+	minPollOptions      = 2
+	maxPollOptions      = 4
+	maxPollOptionLength = 50
+	minPollDuration     = 5 * time.Minute
+	maxPollDuration     = 7 * 24 * time.Hour
+	pollCloseInterval   = time.Minute
+)
+
+var (
+	errInvalidPoll  = errors.New("Poll needs 2 to 4 distinct options of at most 50 characters")
+	errPollDuration = errors.New("Poll must close between 5 minutes and 7 days from now")
+)
+
+// Poll is a poll as seen by one viewer. Vote counts are left out until the
+// viewer has voted or the poll has closed.
+type Poll struct {
+	ID            uuid.UUID    `json:"id"`
+	ClosesAt      time.Time    `json:"closes_at"`
+	Closed        bool         `json:"closed"`
+	Options       []PollOption `json:"options"`
+	TotalVotes    *int64       `json:"total_votes,omitempty"`
+	VotedOptionID *uuid.UUID   `json:"voted_option_id,omitempty"`
+}
+
+type PollOption struct {
+	ID    uuid.UUID `json:"id"`
+	Text  string    `json:"text"`
+	Votes *int64    `json:"votes,omitempty"`
+}
+
+// newPoll is a poll to be created along with a chirp.
+type newPoll struct {
+	Options  []string  `json:"options"`
+	ClosesAt time.Time `json:"closes_at"`
+}
+
+// validate trims the options and checks them and the closing time.
+func (p *newPoll) validate(now time.Time) error {
+	if len(p.Options) < minPollOptions || len(p.Options) > maxPollOptions {
+		return errInvalidPoll
+	}
+	seen := make(map[string]bool, len(p.Options))
+	for i, o := range p.Options {
+		o = strings.TrimSpace(o)
+		if o == "" || utf8.RuneCountInString(o) > maxPollOptionLength || seen[strings.ToLower(o)] {
+			return errInvalidPoll
+		}
+		seen[strings.ToLower(o)] = true
+		p.Options[i] = o
+	}
+
+	d := p.ClosesAt.Sub(now)
+	if d < minPollDuration || d > maxPollDuration {
+		return errPollDuration
+	}
+	p.ClosesAt = p.ClosesAt.UTC()
+	return nil
+}
+
+// createPoll stores a poll for a chirp that was just created with qtx.
+func createPoll(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, np newPoll) (*Poll, error) {
+	p, err := qtx.CreatePoll(ctx, database.CreatePollParams{
+		ChirpID:  chirpID,
+		ClosesAt: np.ClosesAt,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	options, err := qtx.CreatePollOptions(ctx, database.CreatePollOptionsParams{
+		PollID: p.ID,
+		Texts:  np.Options,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	poll := &Poll{
+		ID:       p.ID,
+		ClosesAt: p.ClosesAt,
+		Options:  make([]PollOption, len(options)),
+	}
+	for _, o := range options {
+		poll.Options[o.Position-1] = PollOption{ID: o.ID, Text: o.Text}
+	}
+	return poll, nil
+}
+
+// pollsFromRows builds the polls in rows, keyed by chirp.
+func pollsFromRows(rows []database.GetChirpPollsRow, now time.Time) map[uuid.UUID]*Poll {
+	polls := map[uuid.UUID]*Poll{}
+	for _, row := range rows {
+		p, ok := polls[row.ChirpID]
+		if !ok {
+			p = &Poll{
+				ID:       row.PollID,
+				ClosesAt: row.ClosesAt,
+				// The close job runs every minute, so a poll past its
+				// closing time counts as closed before the job gets to it.
+				Closed: row.ClosedAt.Valid || !row.ClosesAt.After(now),
+			}
+			polls[row.ChirpID] = p
+		}
+		votes := row.Votes
+		p.Options = append(p.Options, PollOption{
+			ID:    row.OptionID,
+			Text:  row.Text,
+			Votes: &votes,
+		})
+		if row.ViewerVoted {
+			optionID := row.OptionID
+			p.VotedOptionID = &optionID
+		}
+	}
+
+	for _, p := range polls {
+		if p.VotedOptionID == nil && !p.Closed {
+			for i := range p.Options {
+				p.Options[i].Votes = nil
+			}
+			continue
+		}
+		total := int64(0)
+		for _, o := range p.Options {
+			total += *o.Votes
+		}
+		p.TotalVotes = &total
+	}
+	return polls
+}
+
+// loadPolls fills in the polls of chirps as seen by viewerID.
+func (cfg *APIConfig) loadPolls(ctx context.Context, chirps []Chirp, viewerID uuid.UUID) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(chirps))
+	for i, ch := range chirps {
+		ids[i] = ch.ID
+	}
+
+	rows, err := cfg.db.GetChirpPolls(ctx, database.GetChirpPollsParams{
+		ViewerID: viewerID,
+		ChirpIds: ids,
+	})
+	if err != nil {
+		return err
+	}
+
+	polls := pollsFromRows(rows, time.Now().UTC())
+	for i := range chirps {
+		chirps[i].Poll = polls[chirps[i].ID]
+	}
+	return nil
+}
+
+func (cfg *APIConfig) votePollHandler(w http.ResponseWriter, r *http.Request) {
+	type reqBody struct {
+		OptionID uuid.UUID `json:"option_id"`
+	}
+
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, "Can't parse chirpID", 400, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := reqBody{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+
+	_, err = cfg.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: userID,
+	})
+	if err != nil {
+		respondError(w, "Can't get chirp", 404, err)
+		return
+	}
+
+	p, err := cfg.db.GetPollByChirp(r.Context(), chirpID)
+	if err != nil {
+		respondError(w, "Chirp doesn't have a poll", 404, err)
+		return
+	}
+	if p.ClosedAt.Valid || !p.ClosesAt.After(time.Now().UTC()) {
+		respondError(w, "Poll is closed", 409, nil)
+		return
+	}
+
+	_, err = cfg.db.CreatePollVote(r.Context(), database.CreatePollVoteParams{
+		UserID:   userID,
+		PollID:   p.ID,
+		OptionID: b.OptionID,
+	})
+	if errors.Is(err, sql.ErrNoRows) {
+		cc := []Chirp{{ID: chirpID}}
+		err = cfg.loadPolls(r.Context(), cc, userID)
+		if err != nil {
+			respondError(w, "Can't get poll", 500, err)
+			return
+		}
+		if cc[0].Poll.VotedOptionID != nil {
+			respondError(w, "Already voted in this poll", 409, nil)
+			return
+		}
+		if cc[0].Poll.Closed {
+			respondError(w, "Poll is closed", 409, nil)
+			return
+		}
+		respondError(w, "Option isn't part of this poll", 400, nil)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't record vote", 500, err)
+		return
+	}
+
+	cc := []Chirp{{ID: chirpID}}
+	err = cfg.loadPolls(r.Context(), cc, userID)
+	if err != nil {
+		respondError(w, "Can't get poll", 500, err)
+		return
+	}
+
+	respondJSON(w, 201, cc[0].Poll)
+}
+
+// runPollJob closes polls once their closing time has passed and tells their
+// authors. It runs until ctx is done.
+func (cfg *APIConfig) runPollJob(ctx context.Context) {
+	ticker := time.NewTicker(pollCloseInterval)
+	defer ticker.Stop()
+
+	for {
+		cfg.closeExpiredPolls(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *APIConfig) closeExpiredPolls(ctx context.Context) {
+	// Each poll is only returned by the instance whose update closed it, so
+	// authors are notified once even with several instances running.
+	closed, err := cfg.db.ClosePolls(ctx)
+	if err != nil {
+		log.Printf("Can't close polls: %v", err)
+		return
+	}
+
+	for _, p := range closed {
+		cfg.notify(ctx, p.UserID, NotificationPollClosed,
+			uuid.NullUUID{},
+			uuid.NullUUID{UUID: p.ChirpID, Valid: true},
+		)
+	}
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"database/sql"
+	"errors"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+)
+
+func TestNewPollValidate(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	day := now.Add(24 * time.Hour)
+	tests := []struct {
+		name     string
+		options  []string
+		closesAt time.Time
+		err      error
+		want     []string
+	}{
+		{"valid", []string{" Yes ", "No"}, day, nil, []string{"Yes", "No"}},
+		{"four options", []string{"a", "b", "c", "d"}, day, nil, []string{"a", "b", "c", "d"}},
+		{"one option", []string{"Yes"}, day, errInvalidPoll, nil},
+		{"five options", []string{"a", "b", "c", "d", "e"}, day, errInvalidPoll, nil},
+		{"duplicate", []string{"Yes", "Yes"}, day, errInvalidPoll, nil},
+		{"duplicate in another case", []string{"Yes", "yES"}, day, errInvalidPoll, nil},
+		{"duplicate after trimming", []string{"Yes", " Yes"}, day, errInvalidPoll, nil},
+		{"blank", []string{"Yes", "  "}, day, errInvalidPoll, nil},
+		{"longest option", []string{strings.Repeat("é", maxPollOptionLength), "No"}, day, nil, []string{strings.Repeat("é", maxPollOptionLength), "No"}},
+		{"option too long", []string{strings.Repeat("a", maxPollOptionLength+1), "No"}, day, errInvalidPoll, nil},
+		{"shortest duration", []string{"Yes", "No"}, now.Add(minPollDuration), nil, []string{"Yes", "No"}},
+		{"too short", []string{"Yes", "No"}, now.Add(minPollDuration - time.Second), errPollDuration, nil},
+		{"longest duration", []string{"Yes", "No"}, now.Add(maxPollDuration), nil, []string{"Yes", "No"}},
+		{"too long", []string{"Yes", "No"}, now.Add(maxPollDuration + time.Second), errPollDuration, nil},
+		{"in the past", []string{"Yes", "No"}, now.Add(-time.Hour), errPollDuration, nil},
+	}
+
+	for _, tt := range tests {
+		p := newPoll{Options: tt.options, ClosesAt: tt.closesAt}
+		err := p.validate(now)
+		if !errors.Is(err, tt.err) {
+			t.Errorf("%s: expected %v, got %v", tt.name, tt.err, err)
+			continue
+		}
+		if err == nil && !slices.Equal(p.Options, tt.want) {
+			t.Errorf("%s: options are %q, want %q", tt.name, p.Options, tt.want)
+		}
+	}
+
+	p := newPoll{Options: []string{"Yes", "No"}, ClosesAt: day.In(time.FixedZone("X", 3600))}
+	if err := p.validate(now); err != nil || p.ClosesAt.Location() != time.UTC {
+		t.Errorf("closes_at in another zone gave %v, %v", p.ClosesAt, err)
+	}
+}
+
+func TestPollsFromRows(t *testing.T) {
+	now := time.Date(2026, 5, 1, 12, 0, 0, 0, time.UTC)
+	chirpID, pollID := uuid.New(), uuid.New()
+	yes, no := uuid.New(), uuid.New()
+	rows := func(closesAt time.Time, closed, voted bool) []database.GetChirpPollsRow {
+		closedAt := sql.NullTime{Time: now, Valid: closed}
+		return []database.GetChirpPollsRow{
+			{PollID: pollID, ChirpID: chirpID, ClosesAt: closesAt, ClosedAt: closedAt, OptionID: yes, Text: "Yes", Votes: 3, ViewerVoted: voted},
+			{PollID: pollID, ChirpID: chirpID, ClosesAt: closesAt, ClosedAt: closedAt, OptionID: no, Text: "No", Votes: 1},
+		}
+	}
+
+	tests := []struct {
+		name       string
+		rows       []database.GetChirpPollsRow
+		closed     bool
+		showCounts bool
+		voted      bool
+	}{
+		{"open, not voted", rows(now.Add(time.Hour), false, false), false, false, false},
+		{"open, voted", rows(now.Add(time.Hour), false, true), false, true, true},
+		{"closed by the job", rows(now.Add(-time.Hour), true, false), true, true, false},
+		{"past closing time", rows(now.Add(-time.Second), false, false), true, true, false},
+		{"closing now", rows(now, false, false), true, true, false},
+	}
+
+	for _, tt := range tests {
+		p := pollsFromRows(tt.rows, now)[chirpID]
+		if p == nil {
+			t.Errorf("%s: poll is missing", tt.name)
+			continue
+		}
+		if p.ID != pollID || len(p.Options) != 2 || p.Options[0].Text != "Yes" || p.Options[1].Text != "No" {
+			t.Errorf("%s: poll is %+v", tt.name, p)
+		}
+		if p.Closed != tt.closed {
+			t.Errorf("%s: closed is %v, want %v", tt.name, p.Closed, tt.closed)
+		}
+		if (p.VotedOptionID != nil) != tt.voted || (tt.voted && *p.VotedOptionID != yes) {
+			t.Errorf("%s: voted option is %v", tt.name, p.VotedOptionID)
+		}
+
+		if !tt.showCounts {
+			if p.TotalVotes != nil || p.Options[0].Votes != nil || p.Options[1].Votes != nil {
+				t.Errorf("%s: vote counts are shown", tt.name)
+			}
+			continue
+		}
+		if p.TotalVotes == nil || *p.TotalVotes != 4 {
+			t.Errorf("%s: total votes is %v, want 4", tt.name, p.TotalVotes)
+		}
+		if p.Options[0].Votes == nil || *p.Options[0].Votes != 3 || p.Options[1].Votes == nil || *p.Options[1].Votes != 1 {
+			t.Errorf("%s: option votes are %v and %v, want 3 and 1", tt.name, p.Options[0].Votes, p.Options[1].Votes)
+		}
+	}
+}
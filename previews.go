@@ -0,0 +1,176 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/links"
+	"github.com/google/uuid"
+)
+
+const (
+	linkPreviewInterval = 15 * time.Second
+	linkPreviewBatch    = 10
+	// linkPreviewTTL is how long a fetched preview is used before a new
+	// chirp linking to it has it fetched again.
+	linkPreviewTTL = 24 * time.Hour
+	// linkPreviewClaimTimeout is how long a claimed preview waits for the
+	// instance that claimed it before another one may fetch it.
+	linkPreviewClaimTimeout = 2 * time.Minute
+	// Previews no chirp links to any more are purged after this long.
+	linkPreviewRetention = 30 * 24 * time.Hour
+)
+
+type LinkPreview struct {
+	Title       string `json:"title"`
+	Description string `json:"description,omitempty"`
+	ImageURL    string `json:"image_url,omitempty"`
+	SiteName    string `json:"site_name,omitempty"`
+}
+
+// Link is a URL in a chirp body. Start and End are byte offsets into the
+// body. Preview is missing until the page has been fetched, or if it had
+// no preview.
+type Link struct {
+	URL     string       `json:"url"`
+	Start   int          `json:"start"`
+	End     int          `json:"end"`
+	Preview *LinkPreview `json:"preview,omitempty"`
+}
+
+// saveChirpLinks stores the URLs in a chirp body with qtx and requests
+// previews of them.
+func saveChirpLinks(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, body string) ([]Link, error) {
+	entities := links.Find(body)
+	if len(entities) == 0 {
+		return nil, nil
+	}
+
+	params := database.CreateChirpLinksParams{ChirpID: chirpID}
+	cc := make([]Link, len(entities))
+	for i, e := range entities {
+		params.Urls = append(params.Urls, e.URL)
+		params.StartOffsets = append(params.StartOffsets, int32(e.Start))
+		params.EndOffsets = append(params.EndOffsets, int32(e.End))
+		cc[i] = Link{URL: e.URL, Start: e.Start, End: e.End}
+	}
+
+	err := qtx.CreateChirpLinks(ctx, params)
+	if err != nil {
+		return nil, err
+	}
+	err = qtx.RequestLinkPreviews(ctx, params.Urls)
+	if err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+// loadLinks fills in the links of chirps and their previews.
+func (cfg *APIConfig) loadLinks(ctx context.Context, chirps []Chirp) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(chirps))
+	for i, ch := range chirps {
+		ids[i] = ch.ID
+	}
+	rows, err := cfg.db.GetChirpLinks(ctx, ids)
+	if err != nil {
+		return err
+	}
+
+	byChirp := map[uuid.UUID][]Link{}
+	for _, row := range rows {
+		l := Link{
+			URL:   row.Url,
+			Start: int(row.StartOffset),
+			End:   int(row.EndOffset),
+		}
+		if row.Title != "" {
+			l.Preview = &LinkPreview{
+				Title:       row.Title,
+				Description: row.Description,
+				ImageURL:    row.ImageUrl,
+				SiteName:    row.SiteName,
+			}
+		}
+		byChirp[row.ChirpID] = append(byChirp[row.ChirpID], l)
+	}
+	for i := range chirps {
+		chirps[i].Links = byChirp[chirps[i].ID]
+	}
+	return nil
+}
+
+// runLinkPreviewJob fetches requested link previews. It runs until ctx is
+// done.
+func (cfg *APIConfig) runLinkPreviewJob(ctx context.Context) {
+	ticker := time.NewTicker(linkPreviewInterval)
+	defer ticker.Stop()
+
+	for {
+		cfg.fetchLinkPreviews(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *APIConfig) fetchLinkPreviews(ctx context.Context) {
+	for {
+		now := time.Now().UTC()
+		urls, err := cfg.db.ClaimLinkPreviews(ctx, database.ClaimLinkPreviewsParams{
+			StaleBefore:        now.Add(-linkPreviewTTL),
+			ClaimExpiredBefore: now.Add(-linkPreviewClaimTimeout),
+			MaxPreviews:        linkPreviewBatch,
+		})
+		if err != nil {
+			log.Printf("Can't claim link previews: %v", err)
+			return
+		}
+
+		for _, url := range urls {
+			p, err := cfg.linkFetcher.Fetch(ctx, url)
+			if err != nil {
+				// Pages without a preview are remembered too, so they
+				// aren't fetched again until the preview is stale.
+				err = cfg.db.FailLinkPreview(ctx, database.FailLinkPreviewParams{
+					Error: sql.NullString{String: err.Error(), Valid: true},
+					Url:   url,
+				})
+			} else {
+				err = cfg.db.SaveLinkPreview(ctx, database.SaveLinkPreviewParams{
+					Title:       p.Title,
+					Description: p.Description,
+					ImageUrl:    p.ImageURL,
+					SiteName:    p.SiteName,
+					Url:         url,
+				})
+			}
+			if err != nil {
+				log.Printf("Can't save link preview of %s: %v", url, err)
+				// Release the claim, or the page is fetched again every
+				// linkPreviewClaimTimeout.
+				err = cfg.db.FailLinkPreview(ctx, database.FailLinkPreviewParams{
+					Error: sql.NullString{String: err.Error(), Valid: true},
+					Url:   url,
+				})
+				if err != nil {
+					log.Printf("Can't save link preview of %s: %v", url, err)
+				}
+			}
+		}
+
+		if len(urls) < linkPreviewBatch {
+			return
+		}
+	}
+}
@@ -0,0 +1,159 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+)
+
+var errQuotedChirpNotFound = errors.New("Quoted chirp doesn't exist")
+
+// QuotedChirp is a chirp embedded in a quote. When the viewer can't see the
+// original any more, e.g. because it was deleted, only its ID is kept and
+// Tombstone is set.
+type QuotedChirp struct {
+	ID             uuid.UUID    `json:"id"`
+	Tombstone      bool         `json:"tombstone,omitempty"`
+	CreatedAt      *time.Time   `json:"created_at,omitempty"`
+	Body           string       `json:"body,omitempty"`
+	User_id        *uuid.UUID   `json:"user_id,omitempty"`
+	Attachments    []Attachment `json:"attachments,omitempty"`
+	ContentWarning string       `json:"content_warning,omitempty"`
+	Sensitive      bool         `json:"sensitive,omitempty"`
+	Collapsed      bool         `json:"collapsed,omitempty"`
+}
+
+func quotedChirpJSON(ch Chirp) *QuotedChirp {
+	return &QuotedChirp{
+		ID:             ch.ID,
+		CreatedAt:      &ch.CreatedAt,
+		Body:           ch.Body,
+		User_id:        &ch.User_id,
+		Attachments:    ch.Attachments,
+		ContentWarning: ch.ContentWarning,
+		Sensitive:      ch.Sensitive,
+	}
+}
+
+func (q *QuotedChirp) isSensitive() bool {
+	return q.Sensitive || q.ContentWarning != ""
+}
+
+// loadQuotes fills in the chirps quoted by chirps as seen by viewerID, and how
+// many times each of chirps has been quoted.
+func (cfg *APIConfig) loadQuotes(ctx context.Context, chirps []Chirp, viewerID uuid.UUID) error {
+	if len(chirps) == 0 {
+		return nil
+	}
+
+	ids := make([]uuid.UUID, len(chirps))
+	var quotedIDs []uuid.UUID
+	for i, ch := range chirps {
+		ids[i] = ch.ID
+		if ch.QuotedChirpID != nil {
+			quotedIDs = append(quotedIDs, *ch.QuotedChirpID)
+		}
+	}
+
+	counts, err := cfg.db.GetQuoteCounts(ctx, database.GetQuoteCountsParams{
+		ChirpIds: ids,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		return err
+	}
+	quoteCounts := make(map[uuid.UUID]int64, len(counts))
+	for _, c := range counts {
+		quoteCounts[c.ChirpID] = c.Quotes
+	}
+
+	quoted := map[uuid.UUID]Chirp{}
+	if len(quotedIDs) > 0 {
+		dbQuoted, err := cfg.db.GetVisibleChirpsByIDs(ctx, database.GetVisibleChirpsByIDsParams{
+			Ids:      quotedIDs,
+			ViewerID: viewerID,
+		})
+		if err != nil {
+			return err
+		}
+		qq := make([]Chirp, len(dbQuoted))
+		for i, ch := range dbQuoted {
+			qq[i] = chirpJSON(ch)
+		}
+		err = cfg.loadAttachments(ctx, qq)
+		if err != nil {
+			return err
+		}
+		for _, ch := range qq {
+			quoted[ch.ID] = ch
+		}
+	}
+
+	for i := range chirps {
+		chirps[i].QuoteCount = quoteCounts[chirps[i].ID]
+		if chirps[i].QuotedChirpID == nil {
+			continue
+		}
+		if ch, ok := quoted[*chirps[i].QuotedChirpID]; ok {
+			chirps[i].QuotedChirp = quotedChirpJSON(ch)
+		} else {
+			chirps[i].QuotedChirp = &QuotedChirp{ID: *chirps[i].QuotedChirpID, Tombstone: true}
+		}
+	}
+
+	return nil
+}
+
+func (cfg *APIConfig) getQuotesHandler(w http.ResponseWriter, r *http.Request) {
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, "Can't parse chirpID", 400, err)
+		return
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	_, err = cfg.db.GetVisibleChirp(r.Context(), database.GetVisibleChirpParams{
+		ID:       chirpID,
+		ViewerID: viewerID,
+	})
+	if err != nil {
+		respondError(w, "Can't get chirp", 404, err)
+		return
+	}
+
+	quotes, err := cfg.db.GetQuotes(r.Context(), database.GetQuotesParams{
+		QuotedChirpID: uuid.NullUUID{UUID: chirpID, Valid: true},
+		ViewerID:      viewerID,
+	})
+	if err != nil {
+		respondError(w, "Can't get quotes", 500, err)
+		return
+	}
+
+	cc := make([]Chirp, len(quotes))
+	for i, ch := range quotes {
+		cc[i] = chirpJSON(ch)
+	}
+
+	err = cfg.loadChirpDetails(r.Context(), cc, viewerID)
+	if err != nil {
+		respondError(w, "Can't get chirp details", 500, err)
+		return
+	}
+	cc, err = cfg.applySensitivePreference(r.Context(), cc, viewerID)
+	if err != nil {
+		respondError(w, "Can't get chirp details", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, cc)
+}
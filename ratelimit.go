@@ -0,0 +1,185 @@
+package main
+
+import (
+	"context"
+	"crypto/subtle"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/netip"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/ratelimit"
+)
+
+// rateLimits are the limits on each kind of request, per user, API key or
+// client IP. Every request counts against "default" as well.
+var rateLimits = map[string]ratelimit.Limit{
+	"default":  {Burst: 300, Per: time.Minute},
+	"chirps":   {Burst: 10, Per: time.Minute},
+	"imports":  {Burst: 3, Per: time.Hour},
+	"signup":   {Burst: 5, Per: time.Hour},
+	"login":    {Burst: 10, Per: 15 * time.Minute},
+	"media":    {Burst: 30, Per: time.Hour},
+	"messages": {Burst: 30, Per: time.Minute},
+	"reports":  {Burst: 20, Per: time.Hour},
+}
+
+// rateLimitRetention is how long shared buckets are kept after their last
+// request. It is longer than any limit's Per, so they are full by then.
+const rateLimitRetention = 24 * time.Hour
+
+// newRateLimitStore sets up the store named by RATE_LIMIT_STORE: "memory"
+// (the default) limits each instance separately, "postgres" shares the
+// limits between instances.
+func newRateLimitStore(db *database.Queries) (ratelimit.Store, error) {
+	switch os.Getenv("RATE_LIMIT_STORE") {
+	case "", "memory":
+		return ratelimit.NewMemory(), nil
+	case "postgres":
+		return dbRateLimitStore{db: db}, nil
+	default:
+		return nil, fmt.Errorf("Unknown RATE_LIMIT_STORE %q", os.Getenv("RATE_LIMIT_STORE"))
+	}
+}
+
+// dbRateLimitStore keeps the buckets in Postgres. Taking a token is a
+// single statement, so instances can't race each other for the last one.
+type dbRateLimitStore struct {
+	db *database.Queries
+}
+
+func (s dbRateLimitStore) Take(ctx context.Context, key string, l ratelimit.Limit) (ratelimit.Result, error) {
+	tokens, err := s.db.TakeRateLimitToken(ctx, database.TakeRateLimitTokenParams{
+		Key:   key,
+		Burst: float64(l.Burst),
+		Rate:  l.Rate(),
+	})
+	if err == nil {
+		return ratelimit.NewResult(l, tokens, true), nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return ratelimit.Result{}, err
+	}
+
+	// No row means the bucket had no token to take.
+	tokens, err = s.db.GetRateLimitTokens(ctx, database.GetRateLimitTokensParams{
+		Burst: float64(l.Burst),
+		Rate:  l.Rate(),
+		Key:   key,
+	})
+	if err != nil {
+		return ratelimit.Result{}, err
+	}
+	return ratelimit.NewResult(l, tokens, false), nil
+}
+
+// rateLimitError is returned by takeRateLimit when there is no token left.
+type rateLimitError struct {
+	Result ratelimit.Result
+}
+
+func (e *rateLimitError) Error() string {
+	return "Too many requests"
+}
+
+// takeRateLimit takes a token from key's bucket of the rate limit called
+// name, for limits that apply however the action is taken rather than to a
+// single route. It returns a *rateLimitError if there was none left.
+func (cfg *APIConfig) takeRateLimit(ctx context.Context, name, key string) error {
+	limit, ok := rateLimits[name]
+	if !ok {
+		panic("unknown rate limit " + name)
+	}
+
+	res, err := cfg.rateLimiter.Take(ctx, name+":"+key, limit)
+	if err != nil {
+		// Failing open keeps the API up when the store isn't.
+		log.Printf("Can't check rate limit %s: %v", name, err)
+		return nil
+	}
+	if !res.Allowed {
+		return &rateLimitError{Result: res}
+	}
+	return nil
+}
+
+// respondRateLimited responds 429 Too Many Requests if err is a
+// *rateLimitError, and reports whether it did.
+func respondRateLimited(w http.ResponseWriter, err error) bool {
+	var limited *rateLimitError
+	if !errors.As(err, &limited) {
+		return false
+	}
+	limited.Result.SetHeaders(w.Header())
+	respondError(w, "Too many requests", 429, nil)
+	return true
+}
+
+// rateLimited wraps h in the rate limit called name.
+func (cfg *APIConfig) rateLimited(name string, h http.HandlerFunc) http.Handler {
+	return cfg.middlewareRateLimit(name, h)
+}
+
+// middlewareRateLimit refuses requests over the rate limit called name with
+// 429 Too Many Requests. Every response gets RateLimit-* headers; the
+// innermost limit's are the ones sent.
+func (cfg *APIConfig) middlewareRateLimit(name string, next http.Handler) http.Handler {
+	limit, ok := rateLimits[name]
+	if !ok {
+		panic("unknown rate limit " + name)
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		res, err := cfg.rateLimiter.Take(r.Context(), name+":"+cfg.rateLimitIdentity(r), limit)
+		if err != nil {
+			// Failing open keeps the API up when the store isn't.
+			log.Printf("Can't check rate limit %s: %v", name, err)
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		res.SetHeaders(w.Header())
+		if !res.Allowed {
+			respondError(w, "Too many requests", 429, nil)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// rateLimitIdentity identifies who is making a request: the user of a
+// valid JWT, the holder of a valid API key or else the client's IP address.
+// IPv6 clients usually have a whole /64, so they are limited by it.
+func (cfg *APIConfig) rateLimitIdentity(r *http.Request) string {
+	header := r.Header.Get("Authorization")
+	if strings.HasPrefix(header, "Bearer ") {
+		token, err := auth.GetBearerToken(r.Header)
+		if err == nil {
+			userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+			if err == nil {
+				return "user:" + userID.String()
+			}
+		}
+	}
+	if strings.HasPrefix(header, "ApiKey ") && cfg.polkaSecret != "" {
+		key, err := auth.GetAPIKey(r.Header)
+		if err == nil && subtle.ConstantTimeCompare([]byte(key), []byte(cfg.polkaSecret)) == 1 {
+			return "key:polka"
+		}
+	}
+
+	ip := cfg.clientIP(r)
+	addr, err := netip.ParseAddr(ip)
+	if err == nil && addr.Is6() && !addr.Is4In6() {
+		prefix, _ := addr.Prefix(64)
+		return "ip:" + prefix.String()
+	}
+	return "ip:" + ip
+}
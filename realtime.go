@@ -0,0 +1,497 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/events"
+	"github.com/aobatake/goserver/internal/websocket"
+	"github.com/google/uuid"
+)
+
+const (
+	wsMaxConnsPerUser   = 5
+	wsMaxSubscriptions  = 50
+	wsMaxMessageSize    = 4096
+	wsSendBuffer        = 256
+	wsMessagesPerSecond = 10
+
+	wsWriteWait  = 10 * time.Second
+	wsPongWait   = 60 * time.Second
+	wsPingPeriod = 50 * time.Second
+)
+
+// wsMessage is a message sent by the client.
+type wsMessage struct {
+	Type  string `json:"type"`
+	Ref   string `json:"ref,omitempty"`
+	Topic string `json:"topic,omitempty"`
+	Body  string `json:"body,omitempty"`
+}
+
+// wsReply is a message sent to the client.
+type wsReply struct {
+	Type  string `json:"type"`
+	Ref   string `json:"ref,omitempty"`
+	Topic string `json:"topic,omitempty"`
+	Event string `json:"event,omitempty"`
+	ID    int64  `json:"id,omitempty"`
+	Data  any    `json:"data,omitempty"`
+	Error string `json:"error,omitempty"`
+}
+
+// realtimeHub counts the open connections of each user on this instance.
+type realtimeHub struct {
+	mu    sync.Mutex
+	conns map[uuid.UUID]int
+}
+
+func newRealtimeHub() *realtimeHub {
+	return &realtimeHub{
+		conns: make(map[uuid.UUID]int),
+	}
+}
+
+// acquire registers a connection for userID. first reports whether it is the
+// user's only connection.
+func (h *realtimeHub) acquire(userID uuid.UUID) (first bool, ok bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	if h.conns[userID] >= wsMaxConnsPerUser {
+		return false, false
+	}
+	h.conns[userID]++
+	return h.conns[userID] == 1, true
+}
+
+// release unregisters a connection and reports whether it was the last one.
+func (h *realtimeHub) release(userID uuid.UUID) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.conns[userID]--
+	if h.conns[userID] <= 0 {
+		delete(h.conns, userID)
+		return true
+	}
+	return false
+}
+
+type wsClient struct {
+	cfg    *APIConfig
+	conn   *websocket.Conn
+	userID uuid.UUID
+	// hidden is only used by eventPump.
+	hidden *hiddenAuthors
+
+	send      chan []byte
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu     sync.Mutex
+	topics map[string]struct{}
+
+	windowStart time.Time
+	windowCount int
+}
+
+func (cfg *APIConfig) publishRealtimeEvent(ctx context.Context, eventType, topic string, userID uuid.UUID, payload any) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Can't marshal %s event: %v", eventType, err)
+		return
+	}
+
+	e, err := json.Marshal(events.Event{
+		EventType: eventType,
+		UserID:    userID,
+		Topic:     topic,
+		Payload:   data,
+	})
+	if err != nil {
+		log.Printf("Can't marshal %s event: %v", eventType, err)
+		return
+	}
+
+	err = cfg.db.NotifyRealtimeEvent(ctx, string(e))
+	if err != nil {
+		log.Printf("Can't publish %s event: %v", eventType, err)
+	}
+}
+
+func (cfg *APIConfig) publishPresence(userID uuid.UUID, status string) {
+	cfg.publishRealtimeEvent(context.Background(), events.Presence, "presence:"+userID.String(), userID, struct {
+		UserID uuid.UUID `json:"user_id"`
+		Status string    `json:"status"`
+	}{
+		UserID: userID,
+		Status: status,
+	})
+}
+
+func (cfg *APIConfig) realtimeHandler(w http.ResponseWriter, r *http.Request) {
+	// Browsers can't set headers on a WebSocket handshake, so the token may
+	// also be passed as a query parameter.
+	token := r.URL.Query().Get("token")
+	if r.Header.Get("Authorization") != "" {
+		var err error
+		token, err = auth.GetBearerToken(r.Header)
+		if err != nil {
+			respondError(w, "Authorization Header doesn't have token", 401, err)
+			return
+		}
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	hidden, err := cfg.newHiddenAuthors(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get blocks and mutes", 500, err)
+		return
+	}
+
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		log.Println(err)
+		return
+	}
+
+	// The connection only counts once the handshake has succeeded, so a
+	// failed one doesn't announce the user as offline.
+	first, ok := cfg.realtime.acquire(userID)
+	if !ok {
+		conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		conn.WriteClose(websocket.CloseTryAgainLater, "Too many connections")
+		conn.Close()
+		return
+	}
+	defer func() {
+		if cfg.realtime.release(userID) {
+			cfg.publishPresence(userID, "offline")
+		}
+	}()
+
+	c := &wsClient{
+		cfg:    cfg,
+		conn:   conn,
+		userID: userID,
+		hidden: hidden,
+		send:   make(chan []byte, wsSendBuffer),
+		done:   make(chan struct{}),
+		topics: make(map[string]struct{}),
+	}
+
+	live, cancel := cfg.events.Subscribe()
+	defer cancel()
+
+	go c.writePump()
+	go c.eventPump(live)
+
+	if first {
+		cfg.publishPresence(userID, "online")
+	}
+
+	c.readPump()
+	c.close(websocket.CloseNormalClosure, "")
+}
+
+func (c *wsClient) close(code int, text string) {
+	c.closeOnce.Do(func() {
+		close(c.done)
+		c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+		c.conn.WriteClose(code, text)
+		c.conn.Close()
+	})
+}
+
+// enqueue queues a reply for the client. When the client can't keep up,
+// droppable replies are discarded and anything else closes the connection.
+func (c *wsClient) enqueue(reply wsReply, droppable bool) {
+	data, err := json.Marshal(reply)
+	if err != nil {
+		log.Printf("Can't marshal websocket reply: %v", err)
+		return
+	}
+
+	select {
+	case c.send <- data:
+	case <-c.done:
+	default:
+		if !droppable {
+			c.close(websocket.ClosePolicyViolation, "Client is not reading fast enough")
+		}
+	}
+}
+
+func (c *wsClient) replyError(ref, msg string) {
+	c.enqueue(wsReply{Type: "error", Ref: ref, Error: msg}, false)
+}
+
+func (c *wsClient) writePump() {
+	ping := time.NewTicker(wsPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-c.done:
+			return
+		case data := <-c.send:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			err := c.conn.WriteMessage(websocket.TextMessage, data)
+			if err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		case <-ping.C:
+			c.conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			err := c.conn.WriteMessage(websocket.PingMessage, nil)
+			if err != nil {
+				c.close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+func (c *wsClient) eventPump(live <-chan events.Event) {
+	for {
+		select {
+		case <-c.done:
+			return
+		case e, ok := <-live:
+			if !ok {
+				c.close(websocket.CloseTryAgainLater, "Event stream overflowed, please reconnect")
+				return
+			}
+			topic := c.match(e)
+			if topic == "" {
+				continue
+			}
+			c.enqueue(wsReply{
+				Type:  "event",
+				Topic: topic,
+				Event: e.EventType,
+				ID:    e.ID,
+				Data:  e.Payload,
+			}, e.EventType == events.Typing || e.EventType == events.Presence)
+		}
+	}
+}
+
+// match returns the subscribed topic an event is delivered under, or "" if
+// the client isn't subscribed to it.
+func (c *wsClient) match(e events.Event) string {
+	var candidates []string
+	switch e.EventType {
+	case events.ChirpCreated, events.ChirpDeleted:
+		if c.hidden.hides(context.Background(), e.UserID) {
+			return ""
+		}
+		candidates = []string{"timeline", "timeline:" + e.UserID.String(), "thread:" + e.ChirpID.String()}
+	case events.Typing:
+		if e.UserID == c.userID {
+			return ""
+		}
+		candidates = []string{e.Topic}
+	case events.Presence:
+		candidates = []string{e.Topic}
+	case events.NotificationCreated, events.MessageCreated, events.ConversationRead:
+		// Private events are only delivered to their recipient.
+		kind, recipient, _ := strings.Cut(e.Topic, ":")
+		if recipient != c.userID.String() {
+			return ""
+		}
+		candidates = []string{kind}
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for _, topic := range candidates {
+		if _, ok := c.topics[topic]; ok {
+			return topic
+		}
+	}
+	return ""
+}
+
+// validTopic reports whether a client may subscribe to topic.
+func validTopic(topic string) bool {
+	if topic == "timeline" || topic == "notifications" || topic == "messages" {
+		return true
+	}
+	kind, id, found := strings.Cut(topic, ":")
+	if !found {
+		return false
+	}
+	switch kind {
+	case "timeline", "thread", "presence":
+		_, err := uuid.Parse(id)
+		return err == nil
+	}
+	return false
+}
+
+// mayWatch reports whether the client may subscribe to, or send typing
+// events on, a valid topic. A user's presence is only shown to those who can
+// see their chirps and aren't blocked by them, and a thread to those who can
+// see its root chirp.
+func (c *wsClient) mayWatch(topic string) (bool, error) {
+	kind, id, _ := strings.Cut(topic, ":")
+	switch kind {
+	case "presence":
+		return c.cfg.db.CanWatchPresence(context.Background(), database.CanWatchPresenceParams{
+			TargetID: uuid.MustParse(id),
+			ViewerID: c.userID,
+		})
+	case "thread":
+		_, err := c.cfg.db.GetVisibleChirp(context.Background(), database.GetVisibleChirpParams{
+			ID:       uuid.MustParse(id),
+			ViewerID: c.userID,
+		})
+		if errors.Is(err, sql.ErrNoRows) {
+			return false, nil
+		}
+		return err == nil, err
+	}
+	return true, nil
+}
+
+// allow enforces the per-connection inbound message rate.
+func (c *wsClient) allow() bool {
+	now := time.Now()
+	if now.Sub(c.windowStart) >= time.Second {
+		c.windowStart = now
+		c.windowCount = 0
+	}
+	c.windowCount++
+	return c.windowCount <= wsMessagesPerSecond
+}
+
+func (c *wsClient) readPump() {
+	c.conn.SetReadLimit(wsMaxMessageSize)
+	c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	c.conn.SetPongHandler(func() {
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := c.conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		c.conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		msg := wsMessage{}
+		err = json.Unmarshal(data, &msg)
+		if err != nil {
+			c.replyError("", "Can't parse message")
+			continue
+		}
+		if !c.allow() {
+			c.replyError(msg.Ref, "Rate limit exceeded")
+			continue
+		}
+
+		c.handle(msg)
+	}
+}
+
+func (c *wsClient) handle(msg wsMessage) {
+	switch msg.Type {
+	case "subscribe":
+		if !validTopic(msg.Topic) {
+			c.replyError(msg.Ref, "Invalid topic")
+			return
+		}
+		ok, err := c.mayWatch(msg.Topic)
+		if err != nil {
+			log.Println(err)
+			c.replyError(msg.Ref, "Can't subscribe")
+			return
+		}
+		if !ok {
+			c.replyError(msg.Ref, "Not allowed to subscribe to this topic")
+			return
+		}
+		c.mu.Lock()
+		_, exists := c.topics[msg.Topic]
+		if !exists && len(c.topics) >= wsMaxSubscriptions {
+			c.mu.Unlock()
+			c.replyError(msg.Ref, "Too many subscriptions")
+			return
+		}
+		c.topics[msg.Topic] = struct{}{}
+		c.mu.Unlock()
+		c.enqueue(wsReply{Type: "ack", Ref: msg.Ref, Topic: msg.Topic}, false)
+
+	case "unsubscribe":
+		c.mu.Lock()
+		delete(c.topics, msg.Topic)
+		c.mu.Unlock()
+		c.enqueue(wsReply{Type: "ack", Ref: msg.Ref, Topic: msg.Topic}, false)
+
+	case "typing":
+		if !strings.HasPrefix(msg.Topic, "thread:") || !validTopic(msg.Topic) {
+			c.replyError(msg.Ref, "Typing is only supported on threads")
+			return
+		}
+		ok, err := c.mayWatch(msg.Topic)
+		if err != nil {
+			log.Println(err)
+			c.replyError(msg.Ref, "Can't send typing event")
+			return
+		}
+		if !ok {
+			c.replyError(msg.Ref, "Not allowed to type in this thread")
+			return
+		}
+		c.cfg.publishRealtimeEvent(context.Background(), events.Typing, msg.Topic, c.userID, struct {
+			UserID uuid.UUID `json:"user_id"`
+			Topic  string    `json:"topic"`
+		}{
+			UserID: c.userID,
+			Topic:  msg.Topic,
+		})
+
+	case "post_chirp":
+		body, err := cleanChirpBody(msg.Body)
+		if err != nil {
+			c.replyError(msg.Ref, err.Error())
+			return
+		}
+		ch, err := c.cfg.storeChirp(context.Background(), c.userID, newChirp{
+			Body:       body,
+			Visibility: VisibilityPublic,
+			Source:     ChirpSourceAPI,
+		})
+		if errors.Is(err, errUserSuspended) || errors.Is(err, errUserBanned) || errors.Is(err, errSpam) ||
+			errors.As(err, new(*rateLimitError)) {
+			c.replyError(msg.Ref, err.Error())
+			return
+		}
+		if err != nil {
+			log.Println(err)
+			c.replyError(msg.Ref, "Can't create chirp")
+			return
+		}
+		c.enqueue(wsReply{Type: "ack", Ref: msg.Ref, Data: ch}, false)
+
+	case "ping":
+		c.enqueue(wsReply{Type: "pong", Ref: msg.Ref}, false)
+
+	default:
+		c.replyError(msg.Ref, "Unknown message type")
+	}
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/google/uuid"
+)
+
+type Relationship struct {
+	UserID    uuid.UUID `json:"user_id"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// relationshipTarget authenticates the request and parses the target user from
+// the path, rejecting attempts to target yourself.
+func (cfg *APIConfig) relationshipTarget(w http.ResponseWriter, r *http.Request) (uuid.UUID, uuid.UUID, bool) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	targetID, err := uuid.Parse(r.PathValue("userID"))
+	if err != nil {
+		respondError(w, "Can't parse userID", 400, err)
+		return uuid.Nil, uuid.Nil, false
+	}
+	if targetID == userID {
+		respondError(w, "Can't target yourself", 400, nil)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	_, err = cfg.db.GetUserByID(r.Context(), targetID)
+	if err != nil {
+		respondError(w, "Can't get user", 404, err)
+		return uuid.Nil, uuid.Nil, false
+	}
+
+	return userID, targetID, true
+}
+
+func (cfg *APIConfig) blockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.BlockUser(r.Context(), database.BlockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondError(w, "Can't block user", 500, err)
+		return
+	}
+
+	err = cfg.db.DeleteFollowsBetween(r.Context(), database.DeleteFollowsBetweenParams{
+		UserA: userID,
+		UserB: targetID,
+	})
+	if err != nil {
+		respondError(w, "Can't remove follows", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) unblockUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.UnblockUser(r.Context(), database.UnblockUserParams{
+		BlockerID: userID,
+		BlockedID: targetID,
+	})
+	if err != nil {
+		respondError(w, "Can't unblock user", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) muteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.MuteUser(r.Context(), database.MuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondError(w, "Can't mute user", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) unmuteUserHandler(w http.ResponseWriter, r *http.Request) {
+	userID, targetID, ok := cfg.relationshipTarget(w, r)
+	if !ok {
+		return
+	}
+
+	err := cfg.db.UnmuteUser(r.Context(), database.UnmuteUserParams{
+		MuterID: userID,
+		MutedID: targetID,
+	})
+	if err != nil {
+		respondError(w, "Can't unmute user", 500, err)
+		return
+	}
+
+	w.WriteHeader(204)
+}
+
+func (cfg *APIConfig) getBlockedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	blocks, err := cfg.db.GetBlockedUsers(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get blocked users", 500, err)
+		return
+	}
+
+	rr := make([]Relationship, len(blocks))
+	for i, b := range blocks {
+		rr[i] = Relationship{
+			UserID:    b.BlockedID,
+			CreatedAt: b.CreatedAt,
+		}
+	}
+
+	respondJSON(w, 200, rr)
+}
+
+func (cfg *APIConfig) getMutedUsersHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	mutes, err := cfg.db.GetMutedUsers(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get muted users", 500, err)
+		return
+	}
+
+	rr := make([]Relationship, len(mutes))
+	for i, m := range mutes {
+		rr[i] = Relationship{
+			UserID:    m.MutedID,
+			CreatedAt: m.CreatedAt,
+		}
+	}
+
+	respondJSON(w, 200, rr)
+}
@@ -2,8 +2,11 @@ package main
 
 import (
 	"encoding/json"
+	"errors"
 	"log"
 	"net/http"
+
+	"github.com/aobatake/goserver/internal/validate"
 )
 
 func respondError(w http.ResponseWriter, msg string, code int, err error) {
@@ -22,6 +25,27 @@ func respondError(w http.ResponseWriter, msg string, code int, err error) {
 
 }
 
+// respondValidationError responds with the field-level errors in err, a
+// *validate.FieldError or validate.Errors, so clients can show each next to
+// its field.
+func respondValidationError(w http.ResponseWriter, err error) {
+	var fields validate.Errors
+	if !errors.As(err, &fields) {
+		fe := &validate.FieldError{}
+		if errors.As(err, &fe) {
+			fields = validate.Errors{fe}
+		}
+	}
+	type errorResponse struct {
+		Error  string          `json:"error"`
+		Fields validate.Errors `json:"fields"`
+	}
+	respondJSON(w, 400, errorResponse{
+		Error:  err.Error(),
+		Fields: fields,
+	})
+}
+
 func respondJSON(w http.ResponseWriter, code int, payload any) {
 	w.Header().Set("Content-Type", "application/json")
 	data, err := json.Marshal(payload)
@@ -0,0 +1,158 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/aobatake/goserver/internal/auth"
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/validate"
+	"github.com/google/uuid"
+)
+
+// How a viewer wants chirps with a content warning or the sensitive flag
+// shown in chirp listings. Collapsed chirps keep their content warning but
+// not their body, attachments or quote; fetching the chirp by ID expands it.
+const (
+	SensitiveCollapse = "collapse"
+	SensitiveExpand   = "expand"
+	SensitiveHide     = "hide"
+)
+
+const maxContentWarningLength = 100
+
+// contentWarning validates a requested content warning. A blank one means
+// the chirp has none. Failures are *validate.FieldError.
+func contentWarning(warning string) (sql.NullString, error) {
+	warning, err := validate.Text("content_warning", warning, validate.Rules{
+		MaxLength: maxContentWarningLength,
+		MaxBytes:  4 * maxContentWarningLength,
+	})
+	if err != nil {
+		return sql.NullString{}, err
+	}
+	if validate.Blank(warning) {
+		return sql.NullString{}, nil
+	}
+	return sql.NullString{String: strings.TrimSpace(warning), Valid: true}, nil
+}
+
+func (ch Chirp) isSensitive() bool {
+	return ch.Sensitive || ch.ContentWarning != ""
+}
+
+// applySensitivePreference collapses or drops the sensitive chirps in a
+// listing as viewerID prefers. Anonymous viewers get them collapsed, and
+// viewers always see their own chirps expanded. A sensitive quoted chirp is
+// collapsed the same way, or left as a tombstone when the viewer hides them.
+func (cfg *APIConfig) applySensitivePreference(ctx context.Context, chirps []Chirp, viewerID uuid.UUID) ([]Chirp, error) {
+	pref := SensitiveCollapse
+	if viewerID != uuid.Nil {
+		viewer, err := cfg.db.GetUserByID(ctx, viewerID)
+		if err != nil {
+			return nil, err
+		}
+		pref = viewer.SensitiveChirps
+	}
+	if pref == SensitiveExpand {
+		return chirps, nil
+	}
+
+	out := chirps[:0]
+	for _, ch := range chirps {
+		if ch.User_id != viewerID && ch.isSensitive() {
+			if pref == SensitiveHide {
+				continue
+			}
+			ch.Collapsed = true
+			ch.Body = ""
+			ch.Attachments = nil
+			ch.Links = nil
+			ch.QuotedChirp = nil
+		}
+		if q := ch.QuotedChirp; q != nil && !q.Tombstone && *q.User_id != viewerID && q.isSensitive() {
+			if pref == SensitiveHide {
+				ch.QuotedChirp = &QuotedChirp{ID: q.ID, Tombstone: true}
+			} else {
+				ch.QuotedChirp = &QuotedChirp{
+					ID:             q.ID,
+					CreatedAt:      q.CreatedAt,
+					User_id:        q.User_id,
+					ContentWarning: q.ContentWarning,
+					Sensitive:      q.Sensitive,
+					Collapsed:      true,
+				}
+			}
+		}
+		out = append(out, ch)
+	}
+	return out, nil
+}
+
+type contentSettings struct {
+	SensitiveChirps string `json:"sensitive_chirps"`
+}
+
+func (cfg *APIConfig) getContentSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	user, err := cfg.db.GetUserByID(r.Context(), userID)
+	if err != nil {
+		respondError(w, "Can't get user", 404, err)
+		return
+	}
+
+	respondJSON(w, 200, contentSettings{SensitiveChirps: user.SensitiveChirps})
+}
+
+func (cfg *APIConfig) updateContentSettingsHandler(w http.ResponseWriter, r *http.Request) {
+	token, err := auth.GetBearerToken(r.Header)
+	if err != nil {
+		respondError(w, "Authorization Header doesn't have token", 401, err)
+		return
+	}
+
+	userID, err := auth.ValidateJWT(token, cfg.JWTSecret)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+
+	decoder := json.NewDecoder(r.Body)
+	b := contentSettings{}
+	err = decoder.Decode(&b)
+	if err != nil {
+		respondError(w, "Can't decode JSON Request", 400, err)
+		return
+	}
+	switch b.SensitiveChirps {
+	case SensitiveCollapse, SensitiveExpand, SensitiveHide:
+	default:
+		respondError(w, "sensitive_chirps must be collapse, expand or hide", 400, nil)
+		return
+	}
+
+	err = cfg.db.UpdateSensitiveChirps(r.Context(), database.UpdateSensitiveChirpsParams{
+		SensitiveChirps: b.SensitiveChirps,
+		ID:              userID,
+	})
+	if err != nil {
+		respondError(w, "Can't update content settings", 500, err)
+		return
+	}
+
+	respondJSON(w, 200, b)
+}
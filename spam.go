@@ -0,0 +1,247 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/spam"
+	"github.com/google/uuid"
+)
+
+const (
+	// The spam checks look at up to spamHistoryLimit of the author's chirps
+	// from the last spamHistory.
+	spamHistory      = 24 * time.Hour
+	spamHistoryLimit = 200
+	// spamModelInterval is how often the classifier is retrained from the
+	// examples every instance has recorded.
+	spamModelInterval = 10 * time.Minute
+	maxSpamExamples   = 20000
+)
+
+var errSpam = errors.New("Chirp was rejected as spam")
+
+type HeldChirp struct {
+	ChirpID   uuid.UUID `json:"chirp_id"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    uuid.UUID `json:"user_id"`
+	Body      string    `json:"body"`
+	Score     float64   `json:"score"`
+	Reasons   []string  `json:"reasons"`
+}
+
+// checkSpam runs the spam checks on a chirp user is about to post.
+func (cfg *APIConfig) checkSpam(ctx context.Context, user database.User, body string) (spam.Result, error) {
+	now := time.Now().UTC()
+	recent, err := cfg.db.GetRecentUserChirps(ctx, database.GetRecentUserChirpsParams{
+		UserID:    user.ID,
+		Since:     now.Add(-spamHistory),
+		MaxChirps: spamHistoryLimit,
+	})
+	if err != nil {
+		return spam.Result{}, err
+	}
+
+	posts := make([]spam.Post, len(recent))
+	for i, p := range recent {
+		posts[i] = spam.Post{Body: p.Body, CreatedAt: p.CreatedAt}
+	}
+	res := cfg.spamFilter.Evaluate(spam.Submission{
+		Body:       body,
+		AccountAge: now.Sub(user.CreatedAt),
+		Recent:     posts,
+		Now:        now,
+	})
+	if res.Verdict != spam.Allow {
+		log.Printf("Spam checks %s chirp from %s (score %.1f): %s", res.Verdict, user.ID, res.Score, strings.Join(res.Reasons(), "; "))
+	}
+	return res, nil
+}
+
+// saveSpamExample records a moderator's decision about a chirp with qtx, to
+// train the classifier on.
+func saveSpamExample(ctx context.Context, qtx *database.Queries, chirpID uuid.UUID, body string, isSpam bool) error {
+	return qtx.CreateSpamExample(ctx, database.CreateSpamExampleParams{
+		ChirpID: chirpID,
+		Body:    body,
+		Spam:    isSpam,
+	})
+}
+
+// runSpamModelJob trains the classifier from the recorded examples, and
+// again every spamModelInterval so it learns from decisions made on other
+// instances. It runs until ctx is done.
+func (cfg *APIConfig) runSpamModelJob(ctx context.Context) {
+	ticker := time.NewTicker(spamModelInterval)
+	defer ticker.Stop()
+
+	for {
+		cfg.loadSpamModel(ctx)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (cfg *APIConfig) loadSpamModel(ctx context.Context) {
+	rows, err := cfg.db.GetSpamExamples(ctx, maxSpamExamples)
+	if err != nil {
+		log.Printf("Can't load spam examples: %v", err)
+		return
+	}
+
+	examples := make([]spam.Example, len(rows))
+	for i, row := range rows {
+		examples[i] = spam.Example{Text: row.Body, Spam: row.Spam}
+	}
+	cfg.spamClassifier.Load(examples)
+}
+
+func (cfg *APIConfig) getHeldChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	_, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	limit := 50
+	var err error
+	if l := r.URL.Query().Get("limit"); l != "" {
+		limit, err = strconv.Atoi(l)
+		if err != nil || limit < 1 || limit > 200 {
+			respondError(w, "limit must be between 1 and 200", 400, err)
+			return
+		}
+	}
+
+	after := time.Time{}
+	if a := r.URL.Query().Get("after"); a != "" {
+		after, err = time.Parse(time.RFC3339Nano, a)
+		if err != nil {
+			respondError(w, "Can't parse after", 400, err)
+			return
+		}
+	}
+
+	held, err := cfg.db.GetHeldChirps(r.Context(), database.GetHeldChirpsParams{
+		After:     after,
+		MaxChirps: int32(limit),
+	})
+	if err != nil {
+		respondError(w, "Can't get held chirps", 500, err)
+		return
+	}
+
+	hh := make([]HeldChirp, len(held))
+	for i, h := range held {
+		hh[i] = HeldChirp{
+			ChirpID:   h.ChirpID,
+			CreatedAt: h.CreatedAt,
+			UserID:    h.UserID,
+			Body:      h.Body,
+			Score:     h.Score,
+			Reasons:   h.Reasons,
+		}
+	}
+
+	respondJSON(w, 200, hh)
+}
+
+// approveHeldChirpHandler publishes a held chirp, as if it had just been
+// posted, and trains the classifier that it isn't spam.
+func (cfg *APIConfig) approveHeldChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.reviewHeldChirp(w, r, false)
+}
+
+// rejectHeldChirpHandler deletes a held chirp and trains the classifier that
+// it is spam.
+func (cfg *APIConfig) rejectHeldChirpHandler(w http.ResponseWriter, r *http.Request) {
+	cfg.reviewHeldChirp(w, r, true)
+}
+
+func (cfg *APIConfig) reviewHeldChirp(w http.ResponseWriter, r *http.Request, isSpam bool) {
+	moderator, ok := cfg.requireModerator(w, r)
+	if !ok {
+		return
+	}
+
+	chirpID, err := uuid.Parse(r.PathValue("chirpID"))
+	if err != nil {
+		respondError(w, "Can't parse chirpID", 400, err)
+		return
+	}
+
+	tx, err := cfg.sqlDB.BeginTx(r.Context(), nil)
+	if err != nil {
+		respondError(w, "Can't review chirp", 500, err)
+		return
+	}
+	defer tx.Rollback()
+	qtx := cfg.db.WithTx(tx)
+
+	var ch database.Chirp
+	if isSpam {
+		ch, err = qtx.RejectHeldChirp(r.Context(), chirpID)
+	} else {
+		ch, err = qtx.ApproveHeldChirp(r.Context(), chirpID)
+	}
+	if errors.Is(err, sql.ErrNoRows) {
+		respondError(w, "Chirp isn't held for review", 404, err)
+		return
+	}
+	if err != nil {
+		respondError(w, "Can't review chirp", 500, err)
+		return
+	}
+
+	err = saveSpamExample(r.Context(), qtx, ch.ID, ch.Body, isSpam)
+	if err != nil {
+		respondError(w, "Can't review chirp", 500, err)
+		return
+	}
+
+	err = tx.Commit()
+	if err != nil {
+		respondError(w, "Can't review chirp", 500, err)
+		return
+	}
+	cfg.spamClassifier.Train(ch.Body, isSpam)
+
+	verdict := "approve"
+	if isSpam {
+		verdict = "reject"
+	}
+	cfg.recordAudit(r, moderator.ID, AuditSpamReviewed, "chirp", ch.ID.String(), map[string]string{
+		"verdict": verdict,
+		"user_id": ch.UserID.String(),
+	})
+
+	if isSpam {
+		w.WriteHeader(204)
+		return
+	}
+
+	author, err := cfg.db.GetUserByID(r.Context(), ch.UserID)
+	if err != nil {
+		respondError(w, "Can't get author", 500, err)
+		return
+	}
+	cc := []Chirp{chirpJSON(ch)}
+	err = cfg.loadChirpDetails(r.Context(), cc, ch.UserID)
+	if err != nil {
+		respondError(w, "Can't get chirp details", 500, err)
+		return
+	}
+	cfg.announceChirp(r.Context(), author, cc[0])
+
+	respondJSON(w, 200, cc[0])
+}
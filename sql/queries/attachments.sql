@@ -0,0 +1,57 @@
+-- name: CreateAttachment :one
+INSERT INTO attachments (id, created_at, user_id, content_type, size_bytes, width, height, alt_text, storage_key, thumbnail_key)
+VALUES (
+  $1, NOW(), $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING *;
+
+-- name: GetAttachment :one
+SELECT * FROM attachments
+WHERE id = $1;
+
+-- name: UpdateAttachmentAltText :one
+UPDATE attachments
+SET alt_text = $1
+WHERE id = $2 AND user_id = $3
+RETURNING *;
+
+-- name: AttachMedia :many
+UPDATE attachments
+SET chirp_id = @chirp_id::uuid,
+    position = array_position(@ids::uuid[], id)
+WHERE id = ANY(@ids::uuid[])
+AND user_id = @user_id
+AND chirp_id IS NULL
+RETURNING *;
+
+-- name: GetChirpAttachments :many
+SELECT * FROM attachments
+WHERE chirp_id = ANY(@chirp_ids::uuid[])
+ORDER BY chirp_id, position;
+
+-- name: GetUserAttachments :many
+SELECT * FROM attachments
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: AnonymizeUserAttachments :exec
+UPDATE attachments
+SET user_id = @anonymous_id
+WHERE user_id = @user_id
+AND chirp_id IS NOT NULL;
+
+-- name: GetPurgeableAttachments :many
+SELECT attachments.* FROM attachments
+LEFT JOIN chirps ON chirps.id = attachments.chirp_id
+JOIN users ON users.id = attachments.user_id
+WHERE ( attachments.chirp_id IS NULL AND NOT EXISTS (
+    SELECT 1 FROM drafts
+    WHERE attachments.id = ANY(drafts.media_ids)
+  ) AND attachments.created_at < @unattached_before::timestamp )
+OR chirps.deleted_at < @deleted_before::timestamp
+OR users.deleted_at < @deleted_before::timestamp
+LIMIT @max_attachments;
+
+-- name: DeleteAttachment :exec
+DELETE FROM attachments
+WHERE id = $1;
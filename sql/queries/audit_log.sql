@@ -0,0 +1,31 @@
+-- name: LockAuditLog :exec
+SELECT pg_advisory_xact_lock(hashtext('audit_log'));
+
+-- name: GetLastAuditLogEntry :one
+SELECT * FROM audit_log
+ORDER BY id DESC
+LIMIT 1;
+
+-- name: CreateAuditLogEntry :one
+INSERT INTO audit_log (created_at, actor_id, action, target_type, target_id, ip, request_id, metadata, prev_hash, hash)
+VALUES (
+  $1, $2, $3, $4, $5, $6, $7, $8, $9, $10
+)
+RETURNING *;
+
+-- name: GetAuditLog :many
+SELECT * FROM audit_log
+WHERE id > @after_id
+AND ( actor_id = @actor_id OR NOT @filter_by_actor::boolean )
+AND ( action = @action OR NOT @filter_by_action::boolean )
+AND ( target_id = @target_id OR NOT @filter_by_target::boolean )
+AND created_at >= @since::timestamp
+AND created_at < @until::timestamp
+ORDER BY id
+LIMIT @max_entries;
+
+-- name: GetAuditLogChain :many
+SELECT * FROM audit_log
+WHERE id > $1
+ORDER BY id
+LIMIT $2;
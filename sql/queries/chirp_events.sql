@@ -0,0 +1,32 @@
+-- name: CreateChirpEvent :exec
+INSERT INTO chirp_events (created_at, event_type, chirp_id, user_id, payload)
+VALUES (
+  NOW(), $1, $2, $3, $4
+);
+
+-- name: GetChirpEventsAfter :many
+SELECT * FROM chirp_events
+WHERE id > @after_id
+AND created_at > @since::timestamp
+AND ( user_id = @user_id OR NOT @filter_by_user_id )
+AND (
+  event_type <> 'chirp.created'
+  OR EXISTS (
+    SELECT 1 FROM chirps
+    JOIN users ON users.id = chirps.user_id
+    WHERE chirps.id = chirp_events.chirp_id
+    AND chirps.deleted_at IS NULL
+    AND chirps.hidden_at IS NULL
+    AND chirps.visibility = 'public'
+    AND NOT users.protected
+    AND users.deleted_at IS NULL
+    AND users.banned_at IS NULL
+    AND users.shadowbanned_at IS NULL
+  )
+)
+ORDER BY id
+LIMIT 500;
+
+-- name: PurgeChirpEvents :execrows
+DELETE FROM chirp_events
+WHERE created_at < @created_before::timestamp;
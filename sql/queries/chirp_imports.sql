@@ -0,0 +1,78 @@
+-- name: CreateChirpImport :one
+INSERT INTO chirp_imports (id, created_at, updated_at, user_id, status, total)
+VALUES (
+  gen_random_uuid(), NOW(), NOW(), $1, 'pending', $2
+)
+RETURNING *;
+
+-- name: StoreChirpImportRecords :exec
+INSERT INTO chirp_import_records (import_id, records)
+VALUES (
+  $1, $2
+);
+
+-- name: GetChirpImport :one
+SELECT * FROM chirp_imports
+WHERE id = $1;
+
+-- name: GetChirpImportRecords :one
+SELECT records FROM chirp_import_records
+WHERE import_id = $1;
+
+-- name: CountUnfinishedChirpImports :one
+SELECT COUNT(*) FROM chirp_imports
+WHERE user_id = $1
+AND status IN ('pending', 'running');
+
+-- name: GetClaimableChirpImports :many
+SELECT * FROM chirp_imports
+WHERE status = 'pending'
+OR ( status = 'running' AND lease_until < NOW() )
+ORDER BY created_at;
+
+-- name: ClaimChirpImport :one
+UPDATE chirp_imports
+SET status = 'running',
+    lease_until = @lease_until::timestamp,
+    updated_at = NOW()
+WHERE id = @id
+AND (
+  status = 'pending'
+  OR ( status = 'running' AND lease_until < NOW() )
+)
+RETURNING *;
+
+-- name: UpdateChirpImportProgress :execrows
+UPDATE chirp_imports
+SET processed = @processed,
+    imported = imported + @imported,
+    skipped = skipped + @skipped,
+    failed = failed + @failed,
+    lease_until = @lease_until::timestamp,
+    updated_at = NOW()
+WHERE id = @id
+AND status = 'running'
+AND lease_until >= NOW();
+
+-- name: FinishChirpImport :exec
+UPDATE chirp_imports
+SET status = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: DeleteChirpImportRecords :exec
+DELETE FROM chirp_import_records
+WHERE import_id = $1;
+
+-- name: CreateChirpImportError :exec
+INSERT INTO chirp_import_errors (import_id, item, error)
+VALUES (
+  $1, $2, $3
+)
+ON CONFLICT DO NOTHING;
+
+-- name: GetChirpImportErrors :many
+SELECT * FROM chirp_import_errors
+WHERE import_id = $1
+ORDER BY item
+LIMIT $2;
@@ -1,19 +1,108 @@
 -- name: CreateChirp :one
-INSERT INTO chirps (id, created_at, updated_at, body, user_id)
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, quoted_chirp_id, visibility, content_warning, sensitive)
 VALUES (
-  gen_random_uuid(), NOW(), NOW(), $1, $2
+  gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, $6
 )
 RETURNING *;
 
 -- name: GetChirps :many
 SELECT * FROM chirps 
-WHERE ( user_id = @user_id OR NOT @filter_by_user_id )
+WHERE deleted_at IS NULL
+AND ( user_id = @user_id OR NOT @filter_by_user_id )
+AND chirp_visible(id, user_id, visibility, hidden_at, @viewer_id)
+AND NOT viewer_hides_author(@viewer_id, user_id)
 ORDER BY created_at;
 
 -- name: GetChirp :one
 SELECT * FROM chirps 
-WHERE id = $1;
+WHERE id = $1
+AND deleted_at IS NULL;
+
+-- name: GetVisibleChirp :one
+SELECT * FROM chirps
+WHERE id = @id
+AND deleted_at IS NULL
+AND chirp_visible(id, user_id, visibility, hidden_at, @viewer_id);
 
 -- name: DeleteChirp :exec
+WITH unpinned AS (
+  DELETE FROM pinned_chirps
+  WHERE chirp_id = $1
+)
+UPDATE chirps
+SET deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+AND deleted_at IS NULL;
+
+-- name: GetDeletedChirp :one
+SELECT * FROM chirps
+WHERE id = $1
+AND deleted_at IS NOT NULL;
+
+-- name: RestoreChirp :one
+UPDATE chirps
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = @id
+AND deleted_at > @deleted_after::timestamp
+RETURNING *;
+
+-- name: PurgeDeletedChirps :execrows
 DELETE FROM chirps
+WHERE deleted_at < @deleted_before::timestamp;
+
+-- name: HideChirp :exec
+UPDATE chirps
+SET hidden_at = NOW(),
+    updated_at = NOW()
 WHERE id = $1;
+
+-- name: MarkChirpSensitive :exec
+UPDATE chirps
+SET sensitive = true,
+    content_warning = COALESCE(sqlc.narg('content_warning'), content_warning),
+    updated_at = NOW()
+WHERE id = @id;
+
+-- name: AnonymizeUserChirps :execrows
+UPDATE chirps
+SET user_id = @anonymous_id,
+    updated_at = NOW()
+WHERE user_id = @user_id;
+
+-- name: GetUserChirps :many
+SELECT * FROM chirps
+WHERE user_id = $1
+ORDER BY created_at;
+
+-- name: ImportChirp :one
+INSERT INTO chirps (id, created_at, updated_at, body, user_id, import_key)
+VALUES (
+  gen_random_uuid(), $1, NOW(), $2, $3, $4
+)
+ON CONFLICT (user_id, import_key) WHERE import_key IS NOT NULL DO NOTHING
+RETURNING *;
+
+-- name: GetVisibleChirpsByIDs :many
+SELECT * FROM chirps
+WHERE id = ANY(@ids::uuid[])
+AND deleted_at IS NULL
+AND chirp_visible(id, user_id, visibility, hidden_at, @viewer_id);
+
+-- name: GetQuoteCounts :many
+SELECT quoted_chirp_id::uuid AS chirp_id, COUNT(*) AS quotes
+FROM chirps
+WHERE quoted_chirp_id = ANY(@chirp_ids::uuid[])
+AND deleted_at IS NULL
+AND chirp_visible(id, user_id, visibility, hidden_at, @viewer_id)
+AND NOT viewer_hides_author(@viewer_id, user_id)
+GROUP BY quoted_chirp_id;
+
+-- name: GetQuotes :many
+SELECT * FROM chirps
+WHERE quoted_chirp_id = @quoted_chirp_id
+AND deleted_at IS NULL
+AND chirp_visible(id, user_id, visibility, hidden_at, @viewer_id)
+AND NOT viewer_hides_author(@viewer_id, user_id)
+ORDER BY created_at;
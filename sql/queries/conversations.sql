@@ -0,0 +1,87 @@
+-- name: CreateConversation :one
+INSERT INTO conversations (id, created_at, updated_at, created_by, is_group)
+VALUES (
+  gen_random_uuid(), NOW(), NOW(), $1, $2
+)
+RETURNING *;
+
+-- name: AddConversationMembers :exec
+INSERT INTO conversation_members (conversation_id, user_id, joined_at, last_read_at, cleared_at, hidden)
+SELECT @conversation_id, member_id, NOW(), NULL, NULL, false
+FROM unnest(@user_ids::uuid[]) AS member_id;
+
+-- name: FindDirectConversation :one
+SELECT conversations.* FROM conversations
+JOIN conversation_members a ON a.conversation_id = conversations.id AND a.user_id = @user_id
+JOIN conversation_members b ON b.conversation_id = conversations.id AND b.user_id = @other_id
+WHERE NOT conversations.is_group
+LIMIT 1;
+
+-- name: LockDirectConversation :exec
+SELECT pg_advisory_xact_lock(hashtext('conversations:' || LEAST(@user_id::uuid, @other_id::uuid)::text || ':' || GREATEST(@user_id::uuid, @other_id::uuid)::text));
+
+-- name: GetConversationMember :one
+SELECT * FROM conversation_members
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: GetConversationMembers :many
+SELECT * FROM conversation_members
+WHERE conversation_id = $1
+ORDER BY joined_at;
+
+-- name: GetConversations :many
+SELECT conversations.*, (
+  SELECT COUNT(*) FROM messages
+  WHERE messages.conversation_id = conversations.id
+  AND messages.sender_id <> cm.user_id
+  AND messages.created_at > COALESCE(GREATEST(cm.last_read_at, cm.cleared_at), '-infinity'::timestamp)
+) AS unread_count
+FROM conversations
+JOIN conversation_members cm ON cm.conversation_id = conversations.id
+WHERE cm.user_id = $1
+AND NOT cm.hidden
+ORDER BY conversations.updated_at DESC
+LIMIT 100;
+
+-- name: CreateMessage :one
+INSERT INTO messages (id, created_at, conversation_id, sender_id, body)
+VALUES (
+  gen_random_uuid(), NOW(), $1, $2, $3
+)
+RETURNING *;
+
+-- name: TouchConversation :exec
+UPDATE conversations
+SET updated_at = NOW()
+WHERE id = $1;
+
+-- name: UnhideConversation :exec
+UPDATE conversation_members
+SET hidden = false
+WHERE conversation_id = $1;
+
+-- name: GetMessages :many
+SELECT messages.* FROM messages
+JOIN conversation_members cm ON cm.conversation_id = messages.conversation_id AND cm.user_id = @user_id
+WHERE messages.conversation_id = @conversation_id
+AND messages.created_at > COALESCE(cm.cleared_at, '-infinity'::timestamp)
+AND messages.created_at < @before::timestamp
+ORDER BY messages.created_at DESC
+LIMIT @max_messages;
+
+-- name: MarkConversationRead :exec
+UPDATE conversation_members
+SET last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: HideConversation :exec
+UPDATE conversation_members
+SET hidden = true,
+    cleared_at = NOW(),
+    last_read_at = NOW()
+WHERE conversation_id = $1 AND user_id = $2;
+
+-- name: GetSentMessages :many
+SELECT * FROM messages
+WHERE sender_id = $1
+ORDER BY created_at;
@@ -0,0 +1,66 @@
+-- name: LockUserDataExports :exec
+SELECT pg_advisory_xact_lock(hashtext('data_exports:' || @user_id::text));
+
+-- name: CreateDataExport :one
+INSERT INTO data_exports (id, created_at, user_id, status)
+SELECT gen_random_uuid(), NOW(), @user_id, 'pending'
+WHERE NOT EXISTS (
+  SELECT 1 FROM data_exports
+  WHERE user_id = @user_id
+  AND status <> 'failed'
+  AND created_at > @since::timestamp
+)
+RETURNING *;
+
+-- name: GetDataExport :one
+SELECT * FROM data_exports
+WHERE id = $1;
+
+-- name: GetLatestDataExport :one
+SELECT * FROM data_exports
+WHERE user_id = $1
+ORDER BY created_at DESC
+LIMIT 1;
+
+-- name: GetClaimableDataExports :many
+SELECT * FROM data_exports
+WHERE status = 'pending'
+AND ( lease_until IS NULL OR lease_until < NOW() )
+ORDER BY created_at;
+
+-- name: ClaimDataExport :one
+UPDATE data_exports
+SET lease_until = @lease_until::timestamp
+WHERE id = @id
+AND status = 'pending'
+AND ( lease_until IS NULL OR lease_until < NOW() )
+RETURNING *;
+
+-- name: StoreDataExportArchive :exec
+INSERT INTO data_export_archives (export_id, archive)
+VALUES (
+  $1, $2
+);
+
+-- name: CompleteDataExport :exec
+UPDATE data_exports
+SET status = 'ready',
+    completed_at = NOW(),
+    expires_at = $1::timestamp,
+    size_bytes = $2
+WHERE id = $3;
+
+-- name: FailDataExport :exec
+UPDATE data_exports
+SET status = 'failed',
+    completed_at = NOW()
+WHERE id = $1
+AND status = 'pending';
+
+-- name: GetDataExportArchive :one
+SELECT archive FROM data_export_archives
+WHERE export_id = $1;
+
+-- name: PurgeExpiredDataExports :execrows
+DELETE FROM data_exports
+WHERE expires_at < @expires_before::timestamp;
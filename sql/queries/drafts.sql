@@ -0,0 +1,76 @@
+-- name: CreateDraft :one
+INSERT INTO drafts (id, created_at, updated_at, user_id, body, media_ids, publish_at, visibility, content_warning, sensitive)
+VALUES (
+  gen_random_uuid(), NOW(), NOW(), @user_id, @body, @media_ids::uuid[], sqlc.narg('publish_at')::timestamp, @visibility, sqlc.narg('content_warning'), @sensitive
+)
+RETURNING *;
+
+-- name: GetDrafts :many
+SELECT * FROM drafts
+WHERE user_id = @user_id
+AND ( publish_at IS NOT NULL OR NOT @scheduled_only::boolean )
+ORDER BY created_at;
+
+-- name: GetDraft :one
+SELECT * FROM drafts
+WHERE id = $1 AND user_id = $2;
+
+-- name: UpdateDraft :one
+UPDATE drafts
+SET body = @body,
+    media_ids = @media_ids::uuid[],
+    publish_at = sqlc.narg('publish_at')::timestamp,
+    visibility = @visibility,
+    content_warning = sqlc.narg('content_warning'),
+    sensitive = @sensitive,
+    publish_error = NULL,
+    publish_attempts = 0,
+    updated_at = NOW()
+WHERE id = @id AND user_id = @user_id
+RETURNING *;
+
+-- name: UnscheduleDraft :one
+UPDATE drafts
+SET publish_at = NULL,
+    updated_at = NOW()
+WHERE id = $1 AND user_id = $2
+AND publish_at IS NOT NULL
+RETURNING *;
+
+-- name: DeleteDraft :execrows
+DELETE FROM drafts
+WHERE id = $1 AND user_id = $2;
+
+-- name: ClaimDueDraft :one
+SELECT drafts.* FROM drafts
+JOIN users ON users.id = drafts.user_id
+WHERE drafts.publish_at <= NOW()
+AND users.deleted_at IS NULL
+ORDER BY drafts.publish_at
+LIMIT 1
+FOR UPDATE OF drafts SKIP LOCKED;
+
+-- name: ClaimDraft :one
+SELECT * FROM drafts
+WHERE id = $1 AND user_id = $2
+FOR UPDATE SKIP LOCKED;
+
+-- name: FailDraft :exec
+UPDATE drafts
+SET publish_at = NULL,
+    publish_error = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: DelayDraft :exec
+UPDATE drafts
+SET publish_at = @publish_at::timestamp
+WHERE id = @id;
+
+-- name: RetryDraft :exec
+UPDATE drafts
+SET publish_at = @publish_at::timestamp,
+    publish_error = @publish_error,
+    publish_attempts = publish_attempts + 1,
+    updated_at = NOW()
+WHERE id = @id;
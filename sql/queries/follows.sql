@@ -0,0 +1,60 @@
+-- name: CreateFollow :one
+INSERT INTO follows (follower_id, followee_id, created_at, approved_at)
+SELECT @follower_id, users.id, NOW(), CASE WHEN users.protected THEN NULL ELSE NOW() END
+FROM users
+WHERE users.id = @followee_id
+ON CONFLICT DO NOTHING
+RETURNING *;
+
+-- name: GetFollow :one
+SELECT * FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: DeleteFollow :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2;
+
+-- name: DeleteFollowsBetween :exec
+DELETE FROM follows
+WHERE (follower_id = @user_a AND followee_id = @user_b)
+OR (follower_id = @user_b AND followee_id = @user_a);
+
+-- name: GetFollowRequests :many
+SELECT * FROM follows
+WHERE followee_id = $1
+AND approved_at IS NULL
+ORDER BY created_at DESC;
+
+-- name: GetFollowing :many
+SELECT * FROM follows
+WHERE follower_id = $1
+ORDER BY created_at;
+
+-- name: GetFollowers :many
+SELECT * FROM follows
+WHERE followee_id = $1
+AND approved_at IS NOT NULL
+ORDER BY created_at;
+
+-- name: ApproveFollowRequest :one
+UPDATE follows
+SET approved_at = NOW()
+WHERE follower_id = $1 AND followee_id = $2
+AND approved_at IS NULL
+RETURNING *;
+
+-- name: ApproveAllFollowRequests :exec
+UPDATE follows
+SET approved_at = NOW()
+WHERE followee_id = $1
+AND approved_at IS NULL;
+
+-- name: RejectFollowRequest :execrows
+DELETE FROM follows
+WHERE follower_id = $1 AND followee_id = $2
+AND approved_at IS NULL;
+
+-- name: CreateChirpMentions :exec
+INSERT INTO chirp_mentions (chirp_id, user_id)
+SELECT @chirp_id, unnest(@user_ids::uuid[])
+ON CONFLICT DO NOTHING;
@@ -0,0 +1,58 @@
+-- name: CreateChirpLinks :exec
+INSERT INTO chirp_links (chirp_id, position, url, start_offset, end_offset)
+SELECT @chirp_id, l.position, l.url, l.start_offset, l.end_offset
+FROM unnest(@urls::text[], @start_offsets::integer[], @end_offsets::integer[])
+  WITH ORDINALITY AS l(url, start_offset, end_offset, position);
+
+-- name: RequestLinkPreviews :exec
+INSERT INTO link_previews (url, created_at, requested_at)
+SELECT unnest(@urls::text[]), NOW(), NOW()
+ON CONFLICT (url) DO UPDATE
+SET requested_at = NOW();
+
+-- name: ClaimLinkPreviews :many
+UPDATE link_previews
+SET claimed_at = NOW()
+WHERE url IN (
+  SELECT url FROM link_previews
+  WHERE ( fetched_at IS NULL OR ( fetched_at < @stale_before::timestamp AND requested_at > fetched_at ) )
+  AND ( claimed_at IS NULL OR claimed_at < @claim_expired_before::timestamp )
+  ORDER BY requested_at
+  LIMIT @max_previews
+  FOR UPDATE SKIP LOCKED
+)
+RETURNING url;
+
+-- name: SaveLinkPreview :exec
+UPDATE link_previews
+SET title = @title,
+    description = @description,
+    image_url = @image_url,
+    site_name = @site_name,
+    error = NULL,
+    fetched_at = NOW(),
+    claimed_at = NULL
+WHERE url = @url;
+
+-- name: FailLinkPreview :exec
+UPDATE link_previews
+SET error = @error,
+    fetched_at = NOW(),
+    claimed_at = NULL
+WHERE url = @url;
+
+-- name: GetChirpLinks :many
+SELECT chirp_links.chirp_id, chirp_links.url, chirp_links.start_offset, chirp_links.end_offset,
+  link_previews.title, link_previews.description, link_previews.image_url, link_previews.site_name
+FROM chirp_links
+JOIN link_previews ON link_previews.url = chirp_links.url
+WHERE chirp_links.chirp_id = ANY(@chirp_ids::uuid[])
+ORDER BY chirp_links.chirp_id, chirp_links.position;
+
+-- name: PurgeUnusedLinkPreviews :execrows
+DELETE FROM link_previews
+WHERE requested_at < @requested_before::timestamp
+AND NOT EXISTS (
+  SELECT 1 FROM chirp_links
+  WHERE chirp_links.url = link_previews.url
+);
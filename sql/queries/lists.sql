@@ -0,0 +1,68 @@
+-- name: CreateList :one
+INSERT INTO lists (id, created_at, updated_at, owner_id, name, is_public)
+VALUES (
+  gen_random_uuid(), NOW(), NOW(), $1, $2, $3
+)
+RETURNING *;
+
+-- name: GetList :one
+SELECT * FROM lists
+WHERE id = $1;
+
+-- name: GetUserLists :many
+SELECT * FROM lists
+WHERE owner_id = $1
+ORDER BY created_at;
+
+-- name: CountUserLists :one
+SELECT COUNT(*) FROM lists
+WHERE owner_id = $1;
+
+-- name: UpdateList :one
+UPDATE lists
+SET name = $1,
+    is_public = $2,
+    updated_at = NOW()
+WHERE id = $3 AND owner_id = $4
+RETURNING *;
+
+-- name: DeleteList :execrows
+DELETE FROM lists
+WHERE id = $1 AND owner_id = $2;
+
+-- name: AddListMember :exec
+INSERT INTO list_members (list_id, user_id, created_at)
+VALUES (
+  $1, $2, NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: RemoveListMember :execrows
+DELETE FROM list_members
+WHERE list_id = $1 AND user_id = $2;
+
+-- name: CountListMembers :one
+SELECT COUNT(*) FROM list_members
+WHERE list_id = $1;
+
+-- name: GetListMembers :many
+SELECT * FROM list_members
+WHERE list_id = $1
+ORDER BY created_at;
+
+-- name: GetUserListMembers :many
+SELECT list_members.* FROM list_members
+JOIN lists ON lists.id = list_members.list_id
+WHERE lists.owner_id = $1
+ORDER BY list_members.list_id, list_members.created_at;
+
+-- name: GetListTimeline :many
+SELECT chirps.* FROM chirps
+JOIN list_members ON list_members.user_id = chirps.user_id
+WHERE list_members.list_id = @list_id
+AND chirps.created_at < @before::timestamp
+AND chirps.deleted_at IS NULL
+AND chirp_visible(chirps.id, chirps.user_id, chirps.visibility, chirps.hidden_at, @viewer_id)
+AND NOT viewer_hides_author(@viewer_id, chirps.user_id)
+ORDER BY chirps.created_at DESC
+LIMIT @max_chirps;
@@ -0,0 +1,48 @@
+-- name: CreateReport :one
+INSERT INTO reports (id, created_at, updated_at, reporter_id, user_id, chirp_id, reason, details, status)
+VALUES (
+  gen_random_uuid(), NOW(), NOW(), $1, $2, $3, $4, $5, 'open'
+)
+RETURNING *;
+
+-- name: GetReport :one
+SELECT * FROM reports
+WHERE id = $1;
+
+-- name: GetReports :many
+SELECT * FROM reports
+WHERE status = @status
+AND created_at > @after::timestamp
+ORDER BY created_at
+LIMIT @max_reports;
+
+-- name: ClaimReport :one
+UPDATE reports
+SET status = 'claimed',
+    claimed_by = @moderator_id,
+    claimed_at = NOW(),
+    updated_at = NOW()
+WHERE id = @id
+AND ( status = 'open' OR ( status = 'claimed' AND claimed_by = @moderator_id ) )
+RETURNING *;
+
+-- name: ResolveReport :exec
+UPDATE reports
+SET status = 'resolved',
+    resolved_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: CreateModerationDecision :one
+INSERT INTO moderation_decisions (id, created_at, report_id, moderator_id, action, note, user_id, chirp_id, chirp_body, reason, suspended_until)
+VALUES (
+  gen_random_uuid(), NOW(), $1, $2, $3, $4, $5, $6, $7, $8, $9
+)
+RETURNING *;
+
+-- name: GetModerationDecisions :many
+SELECT * FROM moderation_decisions
+WHERE ( report_id = @report_id OR NOT @filter_by_report_id::boolean )
+AND ( user_id = @user_id OR NOT @filter_by_user_id::boolean )
+ORDER BY created_at DESC
+LIMIT 100;
@@ -0,0 +1,76 @@
+-- name: CreateNotification :one
+INSERT INTO notifications (id, created_at, user_id, actor_id, type, chirp_id, read_at)
+SELECT gen_random_uuid(), NOW(), users.id, sqlc.narg('actor_id'), @type, sqlc.narg('chirp_id'), NULL
+FROM users
+WHERE users.id = @user_id
+AND users.deleted_at IS NULL
+AND COALESCE((users.notification_preferences ->> @type::text)::boolean, true)
+RETURNING *;
+
+-- name: GetNotificationGroups :many
+SELECT
+  type,
+  chirp_id,
+  (read_at IS NOT NULL)::boolean AS is_read,
+  array_agg(id ORDER BY created_at DESC)::uuid[] AS ids,
+  COALESCE((array_agg(actor_id ORDER BY created_at DESC) FILTER (WHERE actor_id IS NOT NULL))[1:3], '{}')::uuid[] AS actor_ids,
+  COUNT(DISTINCT actor_id) AS actor_count,
+  MAX(created_at)::timestamp AS latest_at
+FROM notifications
+WHERE user_id = @user_id
+AND ( read_at IS NULL OR NOT @unread_only::boolean )
+AND (
+  chirp_id IS NULL
+  OR EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = notifications.chirp_id
+    AND chirps.deleted_at IS NULL
+    AND chirp_visible(chirps.id, chirps.user_id, chirps.visibility, chirps.hidden_at, @user_id)
+  )
+)
+GROUP BY type, chirp_id, (read_at IS NOT NULL), CASE WHEN chirp_id IS NULL THEN date_trunc('day', created_at) END
+HAVING MAX(created_at) < @before::timestamp
+ORDER BY latest_at DESC
+LIMIT @max_groups;
+
+-- name: CountUnreadNotifications :one
+SELECT COUNT(*) FROM notifications
+WHERE user_id = $1 AND read_at IS NULL
+AND (
+  chirp_id IS NULL
+  OR EXISTS (
+    SELECT 1 FROM chirps
+    WHERE chirps.id = notifications.chirp_id
+    AND chirps.deleted_at IS NULL
+    AND chirp_visible(chirps.id, chirps.user_id, chirps.visibility, chirps.hidden_at, $1)
+  )
+);
+
+-- name: MarkNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = @user_id
+AND id = ANY(@ids::uuid[])
+AND read_at IS NULL;
+
+-- name: MarkAllNotificationsRead :exec
+UPDATE notifications
+SET read_at = NOW()
+WHERE user_id = $1
+AND read_at IS NULL;
+
+-- name: GetNotificationPreferences :one
+SELECT notification_preferences FROM users
+WHERE id = $1;
+
+-- name: UpdateNotificationPreferences :one
+UPDATE users
+SET notification_preferences = $1,
+    updated_at = NOW()
+WHERE id = $2
+RETURNING notification_preferences;
+
+-- name: GetUserNotifications :many
+SELECT * FROM notifications
+WHERE user_id = $1
+ORDER BY created_at;
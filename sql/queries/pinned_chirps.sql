@@ -0,0 +1,23 @@
+-- name: GetPinnedChirps :many
+SELECT * FROM pinned_chirps
+WHERE user_id = $1
+ORDER BY position;
+
+-- name: PinChirp :one
+INSERT INTO pinned_chirps (user_id, chirp_id, position, created_at)
+SELECT @user_id, @chirp_id, COALESCE(MAX(position), 0) + 1, NOW()
+FROM pinned_chirps
+WHERE user_id = @user_id
+HAVING COUNT(*) < @max_pins::integer
+ON CONFLICT (user_id, chirp_id) DO NOTHING
+RETURNING *;
+
+-- name: UnpinChirp :exec
+DELETE FROM pinned_chirps
+WHERE user_id = $1 AND chirp_id = $2;
+
+-- name: ReorderPinnedChirps :execrows
+UPDATE pinned_chirps
+SET position = array_position(@chirp_ids::uuid[], chirp_id)
+WHERE user_id = @user_id
+AND chirp_id = ANY(@chirp_ids::uuid[]);
@@ -0,0 +1,62 @@
+-- name: CreatePoll :one
+INSERT INTO polls (id, created_at, chirp_id, closes_at, closed_at)
+VALUES (
+  gen_random_uuid(), NOW(), @chirp_id, @closes_at::timestamp, NULL
+)
+RETURNING *;
+
+-- name: CreatePollOptions :many
+INSERT INTO poll_options (id, poll_id, position, text)
+SELECT gen_random_uuid(), @poll_id, options.position::integer, options.text
+FROM unnest(@texts::text[]) WITH ORDINALITY AS options(text, position)
+RETURNING *;
+
+-- name: GetPollByChirp :one
+SELECT * FROM polls
+WHERE chirp_id = $1;
+
+-- name: GetChirpPolls :many
+SELECT
+  polls.id AS poll_id,
+  polls.chirp_id,
+  polls.closes_at,
+  polls.closed_at,
+  poll_options.id AS option_id,
+  poll_options.text,
+  COUNT(poll_votes.user_id) AS votes,
+  COALESCE(bool_or(poll_votes.user_id = @viewer_id), false)::boolean AS viewer_voted
+FROM polls
+JOIN poll_options ON poll_options.poll_id = polls.id
+LEFT JOIN poll_votes ON poll_votes.option_id = poll_options.id
+WHERE polls.chirp_id = ANY(@chirp_ids::uuid[])
+GROUP BY polls.id, poll_options.id
+ORDER BY polls.chirp_id, poll_options.position;
+
+-- name: CreatePollVote :one
+INSERT INTO poll_votes (poll_id, option_id, user_id, created_at)
+SELECT polls.id, poll_options.id, @user_id, NOW()
+FROM polls
+JOIN poll_options ON poll_options.poll_id = polls.id
+WHERE polls.id = @poll_id
+AND poll_options.id = @option_id
+AND polls.closed_at IS NULL
+AND polls.closes_at > NOW()
+ON CONFLICT (poll_id, user_id) DO NOTHING
+RETURNING *;
+
+-- name: GetUserPollVotes :many
+SELECT polls.chirp_id, poll_votes.poll_id, poll_options.text, poll_votes.created_at
+FROM poll_votes
+JOIN polls ON polls.id = poll_votes.poll_id
+JOIN poll_options ON poll_options.id = poll_votes.option_id
+WHERE poll_votes.user_id = $1
+ORDER BY poll_votes.created_at;
+
+-- name: ClosePolls :many
+UPDATE polls
+SET closed_at = NOW()
+FROM chirps
+WHERE chirps.id = polls.chirp_id
+AND polls.closed_at IS NULL
+AND polls.closes_at <= NOW()
+RETURNING polls.id, polls.chirp_id, chirps.user_id;
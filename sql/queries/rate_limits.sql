@@ -0,0 +1,17 @@
+-- name: TakeRateLimitToken :one
+INSERT INTO rate_limit_buckets AS b (key, tokens, updated_at)
+VALUES (@key, @burst::double precision - 1, NOW())
+ON CONFLICT (key) DO UPDATE
+SET tokens = LEAST(@burst, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * @rate::double precision) - 1,
+    updated_at = NOW()
+WHERE LEAST(@burst, b.tokens + EXTRACT(EPOCH FROM NOW() - b.updated_at) * @rate::double precision) >= 1
+RETURNING tokens;
+
+-- name: GetRateLimitTokens :one
+SELECT LEAST(@burst::double precision, tokens + EXTRACT(EPOCH FROM NOW() - updated_at) * @rate::double precision)::double precision
+FROM rate_limit_buckets
+WHERE key = @key;
+
+-- name: PurgeRateLimitBuckets :execrows
+DELETE FROM rate_limit_buckets
+WHERE updated_at < @updated_before::timestamp;
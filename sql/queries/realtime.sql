@@ -0,0 +1,15 @@
+-- name: NotifyRealtimeEvent :exec
+SELECT pg_notify('realtime_events', @payload::text);
+
+-- name: CanWatchPresence :one
+SELECT EXISTS (
+  SELECT 1 FROM users
+  WHERE users.id = @target_id
+  AND users.deleted_at IS NULL
+  AND can_view_chirp(users.id, 'public', NULL, @viewer_id)
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = users.id
+    AND user_blocks.blocked_id = @viewer_id
+  )
+)::boolean;
@@ -14,3 +14,16 @@ UPDATE refresh_tokens
 SET updated_at = NOW(),
     revoked_at = NOW()
 WHERE token = $1;
+
+
+-- name: RevokeUserTokens :exec
+UPDATE refresh_tokens
+SET updated_at = NOW(),
+    revoked_at = NOW()
+WHERE user_id = $1
+AND revoked_at IS NULL;
+
+-- name: GetUserSessions :many
+SELECT created_at, updated_at, expires_at, revoked_at FROM refresh_tokens
+WHERE user_id = $1
+ORDER BY created_at;
@@ -0,0 +1,47 @@
+-- name: BlockUser :exec
+INSERT INTO user_blocks (blocker_id, blocked_id, created_at)
+VALUES (
+  $1, $2, NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnblockUser :exec
+DELETE FROM user_blocks
+WHERE blocker_id = $1 AND blocked_id = $2;
+
+-- name: GetBlockedUsers :many
+SELECT * FROM user_blocks
+WHERE blocker_id = $1
+ORDER BY created_at DESC;
+
+-- name: IsBlocked :one
+SELECT EXISTS (
+  SELECT 1 FROM user_blocks
+  WHERE blocker_id = $1 AND blocked_id = $2
+);
+
+-- name: MuteUser :exec
+INSERT INTO user_mutes (muter_id, muted_id, created_at)
+VALUES (
+  $1, $2, NOW()
+)
+ON CONFLICT DO NOTHING;
+
+-- name: UnmuteUser :exec
+DELETE FROM user_mutes
+WHERE muter_id = $1 AND muted_id = $2;
+
+-- name: GetMutedUsers :many
+SELECT * FROM user_mutes
+WHERE muter_id = $1
+ORDER BY created_at DESC;
+
+-- name: GetHiddenAuthors :many
+SELECT blocker_id AS user_id FROM user_blocks
+WHERE blocked_id = @viewer_id
+UNION
+SELECT blocked_id FROM user_blocks
+WHERE blocker_id = @viewer_id
+UNION
+SELECT muted_id FROM user_mutes
+WHERE muter_id = @viewer_id;
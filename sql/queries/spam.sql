@@ -0,0 +1,65 @@
+-- name: GetRecentUserChirps :many
+SELECT body, created_at FROM chirps
+WHERE user_id = @user_id
+AND created_at > @since::timestamp
+ORDER BY created_at DESC
+LIMIT @max_chirps;
+
+-- name: HoldChirp :exec
+WITH hidden AS (
+  UPDATE chirps
+  SET hidden_at = NOW(),
+      updated_at = NOW()
+  WHERE id = @chirp_id
+)
+INSERT INTO held_chirps (chirp_id, created_at, score, reasons)
+VALUES (@chirp_id, NOW(), @score, @reasons::text[]);
+
+-- name: GetHeldChirps :many
+SELECT held_chirps.chirp_id, held_chirps.created_at, held_chirps.score, held_chirps.reasons,
+  chirps.user_id, chirps.body
+FROM held_chirps
+JOIN chirps ON chirps.id = held_chirps.chirp_id
+WHERE chirps.deleted_at IS NULL
+AND held_chirps.created_at > @after::timestamp
+ORDER BY held_chirps.created_at
+LIMIT @max_chirps;
+
+-- name: ApproveHeldChirp :one
+WITH released AS (
+  DELETE FROM held_chirps
+  WHERE held_chirps.chirp_id = $1
+  RETURNING held_chirps.chirp_id
+)
+UPDATE chirps
+SET hidden_at = NULL,
+    updated_at = NOW()
+WHERE id IN (SELECT chirp_id FROM released)
+AND deleted_at IS NULL
+RETURNING *;
+
+-- name: RejectHeldChirp :one
+WITH released AS (
+  DELETE FROM held_chirps
+  WHERE held_chirps.chirp_id = $1
+  RETURNING held_chirps.chirp_id
+)
+UPDATE chirps
+SET deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id IN (SELECT chirp_id FROM released)
+AND deleted_at IS NULL
+RETURNING *;
+
+-- name: CreateSpamExample :exec
+INSERT INTO spam_examples (chirp_id, created_at, body, spam)
+VALUES ($1, NOW(), $2, $3)
+ON CONFLICT (chirp_id) DO UPDATE
+SET created_at = NOW(),
+    body = EXCLUDED.body,
+    spam = EXCLUDED.spam;
+
+-- name: GetSpamExamples :many
+SELECT body, spam FROM spam_examples
+ORDER BY created_at DESC
+LIMIT $1;
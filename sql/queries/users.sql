@@ -9,16 +9,110 @@ RETURNING *;
 TRUNCATE TABLE users CASCADE;
 
 -- name: GetUser :one
-SELECT * FROM users WHERE email = $1;
+SELECT * FROM users WHERE email = $1 AND deleted_at IS NULL;
 
 -- name: UpdateUser :one
 UPDATE users
 SET hashed_password = $1,
     email = $2
 WHERE id = $3
+AND deleted_at IS NULL
 RETURNING *;
 
 -- name: UpgradeUser :exec
 UPDATE users
 SET is_chirpy_red = true
+WHERE id = $1
+AND deleted_at IS NULL;
+
+
+-- name: GetUserByID :one
+SELECT * FROM users WHERE id = $1 AND deleted_at IS NULL;
+
+-- name: UpdateDMPolicy :exec
+UPDATE users
+SET dm_policy = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: UpdateProtected :exec
+UPDATE users
+SET protected = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: UpdateSensitiveChirps :exec
+UPDATE users
+SET sensitive_chirps = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: SuspendUser :exec
+UPDATE users
+SET suspended_until = $1,
+    suspension_reason = $2,
+    updated_at = NOW()
+WHERE id = $3;
+
+-- name: BanUser :exec
+UPDATE users
+SET banned_at = NOW(),
+    ban_reason = $1,
+    updated_at = NOW()
+WHERE id = $2;
+
+-- name: UnbanUser :exec
+UPDATE users
+SET banned_at = NULL,
+    ban_reason = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: ShadowbanUser :exec
+UPDATE users
+SET shadowbanned_at = NOW(),
+    updated_at = NOW()
 WHERE id = $1;
+
+-- name: UnshadowbanUser :exec
+UPDATE users
+SET shadowbanned_at = NULL,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: SoftDeleteUser :exec
+UPDATE users
+SET deleted_at = NOW(),
+    updated_at = NOW()
+WHERE id = $1
+AND deleted_at IS NULL;
+
+-- name: GetDeletedUser :one
+SELECT * FROM users
+WHERE email = $1
+AND deleted_at IS NOT NULL;
+
+-- name: RestoreUser :one
+UPDATE users
+SET deleted_at = NULL,
+    updated_at = NOW()
+WHERE id = @id
+AND deleted_at > @deleted_after::timestamp
+RETURNING *;
+
+-- name: PurgeDeletedUsers :execrows
+DELETE FROM users
+WHERE deleted_at < @deleted_before::timestamp;
+
+-- name: DowngradeUser :exec
+UPDATE users
+SET is_chirpy_red = false,
+    updated_at = NOW()
+WHERE id = $1;
+
+-- name: CreateAnonymousUser :exec
+INSERT INTO users (id, created_at, updated_at, email, hashed_password)
+VALUES (
+  $1, NOW(), NOW(), $2, ''
+)
+ON CONFLICT (id) DO NOTHING;
@@ -0,0 +1,35 @@
+-- +goose Up
+CREATE TABLE chirp_events (
+  id BIGSERIAL PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  event_type TEXT NOT NULL,
+  chirp_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  payload JSONB NOT NULL
+);
+
+CREATE INDEX chirp_events_user_id_idx ON chirp_events (user_id, id);
+
+-- +goose StatementBegin
+CREATE FUNCTION notify_chirp_event() RETURNS trigger AS $$
+BEGIN
+  PERFORM pg_notify('chirp_events', json_build_object(
+    'id', NEW.id,
+    'event_type', NEW.event_type,
+    'chirp_id', NEW.chirp_id,
+    'user_id', NEW.user_id,
+    'payload', NEW.payload
+  )::text);
+  RETURN NEW;
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER chirp_events_notify
+  AFTER INSERT ON chirp_events
+  FOR EACH ROW EXECUTE FUNCTION notify_chirp_event();
+
+-- +goose Down
+DROP TRIGGER chirp_events_notify ON chirp_events;
+DROP FUNCTION notify_chirp_event();
+DROP TABLE chirp_events;
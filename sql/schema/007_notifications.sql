@@ -0,0 +1,30 @@
+-- +goose Up
+CREATE TABLE notifications (
+  id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  user_id UUID NOT NULL,
+  actor_id UUID,
+  type TEXT NOT NULL,
+  chirp_id UUID,
+  read_at timestamp,
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_actor
+    FOREIGN KEY(actor_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_chirp
+    FOREIGN KEY(chirp_id)
+      REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX notifications_user_id_idx ON notifications (user_id, created_at);
+
+ALTER TABLE users ADD COLUMN notification_preferences JSONB NOT NULL DEFAULT '{}';
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN notification_preferences;
+DROP TABLE notifications;
@@ -0,0 +1,59 @@
+-- +goose Up
+CREATE TABLE conversations (
+  id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL,
+  created_by UUID NOT NULL,
+  is_group BOOLEAN NOT NULL DEFAULT false,
+  CONSTRAINT fk_user
+    FOREIGN KEY(created_by)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE conversation_members (
+  conversation_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  joined_at timestamp NOT NULL,
+  last_read_at timestamp,
+  cleared_at timestamp,
+  hidden BOOLEAN NOT NULL DEFAULT false,
+  PRIMARY KEY (conversation_id, user_id),
+  CONSTRAINT fk_conversation
+    FOREIGN KEY(conversation_id)
+      REFERENCES conversations(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX conversation_members_user_id_idx ON conversation_members (user_id);
+
+CREATE TABLE messages (
+  id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  conversation_id UUID NOT NULL,
+  sender_id UUID NOT NULL,
+  body TEXT NOT NULL,
+  CONSTRAINT fk_conversation
+    FOREIGN KEY(conversation_id)
+      REFERENCES conversations(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_user
+    FOREIGN KEY(sender_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX messages_conversation_id_idx ON messages (conversation_id, created_at);
+
+ALTER TABLE users ADD COLUMN dm_policy TEXT NOT NULL DEFAULT 'everyone'
+  CHECK (dm_policy IN ('everyone', 'nobody'));
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN dm_policy;
+DROP TABLE messages;
+DROP TABLE conversation_members;
+DROP TABLE conversations;
@@ -0,0 +1,87 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN role TEXT NOT NULL DEFAULT 'user'
+  CHECK (role IN ('user', 'moderator', 'admin'));
+ALTER TABLE users ADD COLUMN suspended_until timestamp;
+ALTER TABLE users ADD COLUMN suspension_reason TEXT;
+
+ALTER TABLE chirps ADD COLUMN hidden_at timestamp;
+
+CREATE TABLE reports (
+  id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL,
+  reporter_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  chirp_id UUID,
+  reason TEXT NOT NULL,
+  details TEXT NOT NULL DEFAULT '',
+  status TEXT NOT NULL DEFAULT 'open'
+    CHECK (status IN ('open', 'claimed', 'resolved')),
+  claimed_by UUID,
+  claimed_at timestamp,
+  resolved_at timestamp,
+  CONSTRAINT fk_reporter
+    FOREIGN KEY(reporter_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_chirp
+    FOREIGN KEY(chirp_id)
+      REFERENCES chirps(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_moderator
+    FOREIGN KEY(claimed_by)
+      REFERENCES users(id)
+        ON DELETE SET NULL
+);
+
+-- A reporter can only have one unresolved report per target.
+CREATE UNIQUE INDEX reports_unresolved_idx ON reports (
+  reporter_id, user_id, COALESCE(chirp_id, '00000000-0000-0000-0000-000000000000')
+) WHERE status <> 'resolved';
+
+CREATE INDEX reports_status_idx ON reports (status, created_at);
+
+-- Decisions deliberately have no foreign keys so they outlive the reports,
+-- chirps and users they refer to.
+CREATE TABLE moderation_decisions (
+  id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  report_id UUID NOT NULL,
+  moderator_id UUID NOT NULL,
+  action TEXT NOT NULL
+    CHECK (action IN ('dismiss', 'hide_chirp', 'suspend_user')),
+  note TEXT NOT NULL DEFAULT '',
+  user_id UUID NOT NULL,
+  chirp_id UUID,
+  chirp_body TEXT,
+  reason TEXT NOT NULL,
+  suspended_until timestamp
+);
+
+CREATE INDEX moderation_decisions_report_id_idx ON moderation_decisions (report_id);
+
+-- +goose StatementBegin
+CREATE FUNCTION forbid_moderation_decision_changes() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'moderation decisions are immutable';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER moderation_decisions_immutable
+  BEFORE UPDATE OR DELETE ON moderation_decisions
+  FOR EACH ROW EXECUTE FUNCTION forbid_moderation_decision_changes();
+
+-- +goose Down
+DROP TRIGGER moderation_decisions_immutable ON moderation_decisions;
+DROP FUNCTION forbid_moderation_decision_changes();
+DROP TABLE moderation_decisions;
+DROP TABLE reports;
+ALTER TABLE chirps DROP COLUMN hidden_at;
+ALTER TABLE users DROP COLUMN suspension_reason;
+ALTER TABLE users DROP COLUMN suspended_until;
+ALTER TABLE users DROP COLUMN role;
@@ -0,0 +1,9 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN banned_at timestamp;
+ALTER TABLE users ADD COLUMN ban_reason TEXT;
+ALTER TABLE users ADD COLUMN shadowbanned_at timestamp;
+
+-- +goose Down
+ALTER TABLE users DROP COLUMN shadowbanned_at;
+ALTER TABLE users DROP COLUMN ban_reason;
+ALTER TABLE users DROP COLUMN banned_at;
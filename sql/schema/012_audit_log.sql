@@ -0,0 +1,43 @@
+-- +goose Up
+-- The audit log has no foreign keys so entries outlive the users and chirps
+-- they mention.
+CREATE TABLE audit_log (
+  id BIGSERIAL PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  actor_id UUID,
+  action TEXT NOT NULL,
+  target_type TEXT NOT NULL DEFAULT '',
+  target_id TEXT NOT NULL DEFAULT '',
+  ip TEXT NOT NULL DEFAULT '',
+  request_id TEXT NOT NULL DEFAULT '',
+  -- json rather than jsonb so the text that was hashed is kept verbatim.
+  metadata JSON NOT NULL DEFAULT '{}',
+  prev_hash TEXT NOT NULL,
+  hash TEXT NOT NULL UNIQUE
+);
+
+CREATE INDEX audit_log_actor_id_idx ON audit_log (actor_id, id);
+CREATE INDEX audit_log_action_idx ON audit_log (action, id);
+CREATE INDEX audit_log_target_id_idx ON audit_log (target_id, id);
+
+-- +goose StatementBegin
+CREATE FUNCTION forbid_audit_log_changes() RETURNS trigger AS $$
+BEGIN
+  RAISE EXCEPTION 'the audit log is append-only';
+END;
+$$ LANGUAGE plpgsql;
+-- +goose StatementEnd
+
+CREATE TRIGGER audit_log_append_only
+  BEFORE UPDATE OR DELETE ON audit_log
+  FOR EACH ROW EXECUTE FUNCTION forbid_audit_log_changes();
+
+CREATE TRIGGER audit_log_no_truncate
+  BEFORE TRUNCATE ON audit_log
+  FOR EACH STATEMENT EXECUTE FUNCTION forbid_audit_log_changes();
+
+-- +goose Down
+DROP TRIGGER audit_log_no_truncate ON audit_log;
+DROP TRIGGER audit_log_append_only ON audit_log;
+DROP FUNCTION forbid_audit_log_changes();
+DROP TABLE audit_log;
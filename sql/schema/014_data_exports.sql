@@ -0,0 +1,31 @@
+-- +goose Up
+CREATE TABLE data_exports (
+  id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  user_id UUID NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'ready', 'failed')),
+  completed_at timestamp,
+  expires_at timestamp,
+  size_bytes BIGINT NOT NULL DEFAULT 0,
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX data_exports_user_id_idx ON data_exports (user_id, created_at);
+
+-- Archives live in their own table so export status lookups don't read them.
+CREATE TABLE data_export_archives (
+  export_id UUID PRIMARY KEY,
+  archive BYTEA NOT NULL,
+  CONSTRAINT fk_export
+    FOREIGN KEY(export_id)
+      REFERENCES data_exports(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE data_export_archives;
+DROP TABLE data_exports;
@@ -0,0 +1,55 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN import_key TEXT;
+
+-- Re-importing an archive skips chirps that were already imported.
+CREATE UNIQUE INDEX chirps_import_key_idx ON chirps (user_id, import_key)
+  WHERE import_key IS NOT NULL;
+
+CREATE TABLE chirp_imports (
+  id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL,
+  user_id UUID NOT NULL,
+  status TEXT NOT NULL DEFAULT 'pending'
+    CHECK (status IN ('pending', 'running', 'completed', 'failed')),
+  total INTEGER NOT NULL,
+  processed INTEGER NOT NULL DEFAULT 0,
+  imported INTEGER NOT NULL DEFAULT 0,
+  skipped INTEGER NOT NULL DEFAULT 0,
+  failed INTEGER NOT NULL DEFAULT 0,
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_imports_user_id_idx ON chirp_imports (user_id, created_at);
+
+-- The uploaded records, one JSON object per line, kept until the import
+-- finishes so an interrupted import can pick up where it stopped.
+CREATE TABLE chirp_import_records (
+  import_id UUID PRIMARY KEY,
+  records BYTEA NOT NULL,
+  CONSTRAINT fk_import
+    FOREIGN KEY(import_id)
+      REFERENCES chirp_imports(id)
+        ON DELETE CASCADE
+);
+
+CREATE TABLE chirp_import_errors (
+  import_id UUID NOT NULL,
+  item INTEGER NOT NULL,
+  error TEXT NOT NULL,
+  PRIMARY KEY (import_id, item),
+  CONSTRAINT fk_import
+    FOREIGN KEY(import_id)
+      REFERENCES chirp_imports(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE chirp_import_errors;
+DROP TABLE chirp_import_records;
+DROP TABLE chirp_imports;
+DROP INDEX chirps_import_key_idx;
+ALTER TABLE chirps DROP COLUMN import_key;
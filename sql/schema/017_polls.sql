@@ -0,0 +1,52 @@
+-- +goose Up
+CREATE TABLE polls (
+  id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  chirp_id UUID NOT NULL UNIQUE,
+  closes_at timestamp NOT NULL,
+  closed_at timestamp,
+  CONSTRAINT fk_chirp
+    FOREIGN KEY(chirp_id)
+      REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX polls_open_idx ON polls (closes_at) WHERE closed_at IS NULL;
+
+CREATE TABLE poll_options (
+  id UUID PRIMARY KEY,
+  poll_id UUID NOT NULL,
+  position INTEGER NOT NULL,
+  text TEXT NOT NULL,
+  UNIQUE (poll_id, position),
+  UNIQUE (poll_id, id),
+  CONSTRAINT fk_poll
+    FOREIGN KEY(poll_id)
+      REFERENCES polls(id)
+        ON DELETE CASCADE
+);
+
+-- The primary key allows one vote per user and poll, and the option has to
+-- belong to the poll being voted on.
+CREATE TABLE poll_votes (
+  poll_id UUID NOT NULL,
+  option_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  created_at timestamp NOT NULL,
+  PRIMARY KEY (poll_id, user_id),
+  CONSTRAINT fk_option
+    FOREIGN KEY(poll_id, option_id)
+      REFERENCES poll_options(poll_id, id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX poll_votes_option_id_idx ON poll_votes (option_id);
+
+-- +goose Down
+DROP TABLE poll_votes;
+DROP TABLE poll_options;
+DROP TABLE polls;
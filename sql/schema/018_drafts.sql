@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE drafts (
+  id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL,
+  user_id UUID NOT NULL,
+  body TEXT NOT NULL,
+  media_ids UUID[] NOT NULL DEFAULT '{}',
+  publish_at timestamp,
+  publish_error TEXT,
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX drafts_user_id_idx ON drafts (user_id, created_at);
+CREATE INDEX drafts_publish_at_idx ON drafts (publish_at) WHERE publish_at IS NOT NULL;
+
+-- +goose Down
+DROP TABLE drafts;
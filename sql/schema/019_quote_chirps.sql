@@ -0,0 +1,11 @@
+-- +goose Up
+-- No foreign key: a quote keeps pointing at the original after it is purged
+-- so it can be shown as deleted.
+ALTER TABLE chirps ADD COLUMN quoted_chirp_id UUID;
+
+CREATE INDEX chirps_quoted_chirp_id_idx ON chirps (quoted_chirp_id, created_at)
+  WHERE quoted_chirp_id IS NOT NULL;
+
+-- +goose Down
+DROP INDEX chirps_quoted_chirp_id_idx;
+ALTER TABLE chirps DROP COLUMN quoted_chirp_id;
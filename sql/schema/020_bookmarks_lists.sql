@@ -0,0 +1,52 @@
+-- +goose Up
+CREATE TABLE bookmarks (
+  user_id UUID NOT NULL,
+  chirp_id UUID NOT NULL,
+  created_at timestamp NOT NULL,
+  PRIMARY KEY (user_id, chirp_id),
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_chirp
+    FOREIGN KEY(chirp_id)
+      REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX bookmarks_user_id_idx ON bookmarks (user_id, created_at);
+
+CREATE TABLE lists (
+  id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  updated_at timestamp NOT NULL,
+  owner_id UUID NOT NULL,
+  name TEXT NOT NULL,
+  is_public BOOLEAN NOT NULL DEFAULT false,
+  CONSTRAINT fk_owner
+    FOREIGN KEY(owner_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX lists_owner_id_idx ON lists (owner_id, created_at);
+
+CREATE TABLE list_members (
+  list_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  created_at timestamp NOT NULL,
+  PRIMARY KEY (list_id, user_id),
+  CONSTRAINT fk_list
+    FOREIGN KEY(list_id)
+      REFERENCES lists(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE list_members;
+DROP TABLE lists;
+DROP TABLE bookmarks;
@@ -0,0 +1,21 @@
+-- +goose Up
+CREATE TABLE pinned_chirps (
+  user_id UUID NOT NULL,
+  chirp_id UUID NOT NULL,
+  position INTEGER NOT NULL,
+  created_at timestamp NOT NULL,
+  PRIMARY KEY (user_id, chirp_id),
+  -- Deferred so pins can swap places in a single reorder.
+  UNIQUE (user_id, position) DEFERRABLE INITIALLY DEFERRED,
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_chirp
+    FOREIGN KEY(chirp_id)
+      REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+-- +goose Down
+DROP TABLE pinned_chirps;
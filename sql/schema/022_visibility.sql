@@ -0,0 +1,90 @@
+-- +goose Up
+ALTER TABLE users ADD COLUMN protected BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE chirps ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+  CHECK (visibility IN ('public', 'followers', 'mentioned'));
+
+ALTER TABLE drafts ADD COLUMN visibility TEXT NOT NULL DEFAULT 'public'
+  CHECK (visibility IN ('public', 'followers', 'mentioned'));
+
+-- A follow of a protected account starts out as a request and only counts
+-- once approved_at is set.
+CREATE TABLE follows (
+  follower_id UUID NOT NULL,
+  followee_id UUID NOT NULL,
+  created_at timestamp NOT NULL,
+  approved_at timestamp,
+  PRIMARY KEY (follower_id, followee_id),
+  CONSTRAINT fk_follower
+    FOREIGN KEY(follower_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_followee
+    FOREIGN KEY(followee_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX follows_followee_id_idx ON follows (followee_id, created_at);
+
+CREATE TABLE chirp_mentions (
+  chirp_id UUID NOT NULL,
+  user_id UUID NOT NULL,
+  PRIMARY KEY (chirp_id, user_id),
+  CONSTRAINT fk_chirp
+    FOREIGN KEY(chirp_id)
+      REFERENCES chirps(id)
+        ON DELETE CASCADE,
+  CONSTRAINT fk_user
+    FOREIGN KEY(user_id)
+      REFERENCES users(id)
+        ON DELETE CASCADE
+);
+
+-- can_view_chirp is the audience check shared by every query that shows
+-- chirps: authors see their own chirps, protected accounts are limited to
+-- approved followers, and the chirp's visibility narrows it further.
+-- +goose StatementBegin
+CREATE FUNCTION can_view_chirp(author_id UUID, chirp_visibility TEXT, chirp_id UUID, viewer_id UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+  SELECT author_id = viewer_id OR (
+    (
+      NOT EXISTS (
+        SELECT 1 FROM users
+        WHERE users.id = author_id
+        AND users.protected
+      )
+      OR EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = viewer_id
+        AND follows.followee_id = author_id
+        AND follows.approved_at IS NOT NULL
+      )
+    )
+    AND (
+      chirp_visibility = 'public'
+      OR ( chirp_visibility = 'followers' AND EXISTS (
+        SELECT 1 FROM follows
+        WHERE follows.follower_id = viewer_id
+        AND follows.followee_id = author_id
+        AND follows.approved_at IS NOT NULL
+      ) )
+      OR ( chirp_visibility = 'mentioned' AND EXISTS (
+        SELECT 1 FROM chirp_mentions
+        WHERE chirp_mentions.chirp_id = can_view_chirp.chirp_id
+        AND chirp_mentions.user_id = viewer_id
+      ) )
+    )
+  )
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION can_view_chirp;
+DROP TABLE chirp_mentions;
+DROP TABLE follows;
+ALTER TABLE drafts DROP COLUMN visibility;
+ALTER TABLE chirps DROP COLUMN visibility;
+ALTER TABLE users DROP COLUMN protected;
@@ -0,0 +1,25 @@
+-- +goose Up
+ALTER TABLE chirps ADD COLUMN content_warning TEXT;
+ALTER TABLE chirps ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT false;
+
+ALTER TABLE drafts ADD COLUMN content_warning TEXT;
+ALTER TABLE drafts ADD COLUMN sensitive BOOLEAN NOT NULL DEFAULT false;
+
+-- How chirps with a content warning or the sensitive flag are shown to this
+-- user in chirp listings.
+ALTER TABLE users ADD COLUMN sensitive_chirps TEXT NOT NULL DEFAULT 'collapse'
+  CHECK (sensitive_chirps IN ('collapse', 'expand', 'hide'));
+
+ALTER TABLE moderation_decisions DROP CONSTRAINT moderation_decisions_action_check;
+ALTER TABLE moderation_decisions ADD CONSTRAINT moderation_decisions_action_check
+  CHECK (action IN ('dismiss', 'hide_chirp', 'suspend_user', 'mark_sensitive'));
+
+-- +goose Down
+ALTER TABLE moderation_decisions DROP CONSTRAINT moderation_decisions_action_check;
+ALTER TABLE moderation_decisions ADD CONSTRAINT moderation_decisions_action_check
+  CHECK (action IN ('dismiss', 'hide_chirp', 'suspend_user'));
+ALTER TABLE users DROP COLUMN sensitive_chirps;
+ALTER TABLE drafts DROP COLUMN sensitive;
+ALTER TABLE drafts DROP COLUMN content_warning;
+ALTER TABLE chirps DROP COLUMN sensitive;
+ALTER TABLE chirps DROP COLUMN content_warning;
@@ -0,0 +1,38 @@
+-- +goose Up
+-- Link previews are shared by every chirp linking to the same URL. They are
+-- fetched in the background and fetched again when a stale preview is
+-- linked to.
+CREATE TABLE link_previews (
+  url TEXT PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  requested_at timestamp NOT NULL,
+  claimed_at timestamp,
+  fetched_at timestamp,
+  title TEXT NOT NULL DEFAULT '',
+  description TEXT NOT NULL DEFAULT '',
+  image_url TEXT NOT NULL DEFAULT '',
+  site_name TEXT NOT NULL DEFAULT '',
+  error TEXT
+);
+
+CREATE INDEX link_previews_fetched_at_idx ON link_previews (fetched_at);
+
+-- chirp_links are the URLs found in a chirp body; the offsets are in bytes.
+CREATE TABLE chirp_links (
+  chirp_id UUID NOT NULL,
+  position INTEGER NOT NULL,
+  url TEXT NOT NULL,
+  start_offset INTEGER NOT NULL,
+  end_offset INTEGER NOT NULL,
+  PRIMARY KEY (chirp_id, position),
+  CONSTRAINT fk_chirp
+    FOREIGN KEY(chirp_id)
+      REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX chirp_links_url_idx ON chirp_links (url);
+
+-- +goose Down
+DROP TABLE chirp_links;
+DROP TABLE link_previews;
@@ -0,0 +1,35 @@
+-- +goose Up
+-- held_chirps are chirps the spam checks held for a moderator to review.
+-- They are hidden until a moderator approves them.
+CREATE TABLE held_chirps (
+  chirp_id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  score DOUBLE PRECISION NOT NULL,
+  reasons TEXT[] NOT NULL,
+  CONSTRAINT fk_chirp
+    FOREIGN KEY(chirp_id)
+      REFERENCES chirps(id)
+        ON DELETE CASCADE
+);
+
+CREATE INDEX held_chirps_created_at_idx ON held_chirps (created_at);
+
+-- spam_examples train the spam classifier from moderators' decisions about
+-- held and reported chirps. Like moderation decisions they have no foreign
+-- key and keep the chirp body, so they outlive the chirp.
+CREATE TABLE spam_examples (
+  chirp_id UUID PRIMARY KEY,
+  created_at timestamp NOT NULL,
+  body TEXT NOT NULL,
+  spam BOOLEAN NOT NULL
+);
+
+CREATE INDEX spam_examples_created_at_idx ON spam_examples (created_at);
+
+-- For the spam checks, which look at each author's recent chirps.
+CREATE INDEX chirps_user_id_created_at_idx ON chirps (user_id, created_at);
+
+-- +goose Down
+DROP INDEX chirps_user_id_created_at_idx;
+DROP TABLE spam_examples;
+DROP TABLE held_chirps;
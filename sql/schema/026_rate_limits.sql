@@ -0,0 +1,13 @@
+-- +goose Up
+-- Token buckets for rate limiting when instances share their limits. A
+-- missing bucket is full, so buckets that have refilled can be deleted.
+CREATE TABLE rate_limit_buckets (
+  key TEXT PRIMARY KEY,
+  tokens DOUBLE PRECISION NOT NULL,
+  updated_at timestamp NOT NULL
+);
+
+CREATE INDEX rate_limit_buckets_updated_at_idx ON rate_limit_buckets (updated_at);
+
+-- +goose Down
+DROP TABLE rate_limit_buckets;
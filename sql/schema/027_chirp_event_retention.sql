@@ -0,0 +1,6 @@
+-- +goose Up
+-- Chirp events are only kept for a short replay window and purged after.
+CREATE INDEX chirp_events_created_at_idx ON chirp_events (created_at);
+
+-- +goose Down
+DROP INDEX chirp_events_created_at_idx;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- An import is worked on by whichever instance holds its lease. The lease is
+-- renewed after every batch, so imports whose instance stopped can be taken
+-- over once it runs out.
+ALTER TABLE chirp_imports ADD COLUMN lease_until timestamp;
+
+-- +goose Down
+ALTER TABLE chirp_imports DROP COLUMN lease_until;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- An export is built by whichever instance holds its lease, which lasts
+-- longer than a build may take. Exports whose instance stopped are built
+-- again once it runs out.
+ALTER TABLE data_exports ADD COLUMN lease_until timestamp;
+
+-- +goose Down
+ALTER TABLE data_exports DROP COLUMN lease_until;
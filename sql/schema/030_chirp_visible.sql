@@ -0,0 +1,56 @@
+-- +goose Up
+-- chirp_visible is the check shared by every query that shows chirps, on
+-- top of the audience check in can_view_chirp: chirps hidden by moderators
+-- and chirps by shadowbanned or banned authors are only shown to their
+-- author, and chirps by deleted authors or authors who blocked the viewer
+-- aren't shown at all.
+-- +goose StatementBegin
+CREATE FUNCTION chirp_visible(chirp_id UUID, author_id UUID, chirp_visibility TEXT, chirp_hidden_at TIMESTAMP, viewer_id UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+  SELECT can_view_chirp(author_id, chirp_visibility, chirp_id, viewer_id)
+  AND (
+    author_id = viewer_id
+    OR ( chirp_hidden_at IS NULL AND NOT EXISTS (
+      SELECT 1 FROM users
+      WHERE users.id = author_id
+      AND ( users.shadowbanned_at IS NOT NULL OR users.banned_at IS NOT NULL )
+    ) )
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM users
+    WHERE users.id = author_id
+    AND users.deleted_at IS NOT NULL
+  )
+  AND NOT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = author_id
+    AND user_blocks.blocked_id = viewer_id
+  )
+$$;
+-- +goose StatementEnd
+
+-- viewer_hides_author reports whether the viewer blocked or muted the
+-- author. Chirp listings leave their chirps out, as the streams do.
+-- +goose StatementBegin
+CREATE FUNCTION viewer_hides_author(viewer_id UUID, author_id UUID)
+RETURNS BOOLEAN
+LANGUAGE sql STABLE
+AS $$
+  SELECT EXISTS (
+    SELECT 1 FROM user_blocks
+    WHERE user_blocks.blocker_id = viewer_id
+    AND user_blocks.blocked_id = author_id
+  )
+  OR EXISTS (
+    SELECT 1 FROM user_mutes
+    WHERE user_mutes.muter_id = viewer_id
+    AND user_mutes.muted_id = author_id
+  )
+$$;
+-- +goose StatementEnd
+
+-- +goose Down
+DROP FUNCTION viewer_hides_author;
+DROP FUNCTION chirp_visible;
@@ -0,0 +1,8 @@
+-- +goose Up
+-- publish_attempts counts how often a scheduled draft failed to publish for
+-- a reason that may pass, e.g. a database error. The publisher tries again
+-- later, waiting longer each time, and gives up after a few attempts.
+ALTER TABLE drafts ADD COLUMN publish_attempts integer NOT NULL DEFAULT 0;
+
+-- +goose Down
+ALTER TABLE drafts DROP COLUMN publish_attempts;
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aobatake/goserver/internal/database"
+	"github.com/aobatake/goserver/internal/events"
+	"github.com/google/uuid"
+)
+
+const (
+	heartbeatInterval = 15 * time.Second
+	// chirpEventReplayWindow is how far back a resuming stream is replayed.
+	// Older events are purged, so deleted and moderated chirps don't
+	// linger in them.
+	chirpEventReplayWindow = 10 * time.Minute
+	// hiddenAuthorsRefresh is how often open streams reload the viewer's
+	// blocks and mutes.
+	hiddenAuthorsRefresh = time.Minute
+)
+
+// hiddenAuthors are the authors whose chirps a viewer's live streams leave
+// out, as the timelines do: those who blocked the viewer and those the viewer
+// blocked or muted. It isn't safe for concurrent use.
+type hiddenAuthors struct {
+	cfg      *APIConfig
+	viewerID uuid.UUID
+	ids      map[uuid.UUID]struct{}
+	loaded   time.Time
+}
+
+// newHiddenAuthors loads the authors hidden from viewerID, who may be
+// uuid.Nil for anonymous viewers.
+func (cfg *APIConfig) newHiddenAuthors(ctx context.Context, viewerID uuid.UUID) (*hiddenAuthors, error) {
+	h := &hiddenAuthors{cfg: cfg, viewerID: viewerID}
+	err := h.load(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return h, nil
+}
+
+func (h *hiddenAuthors) load(ctx context.Context) error {
+	h.loaded = time.Now()
+	if h.viewerID == uuid.Nil {
+		return nil
+	}
+	ids, err := h.cfg.db.GetHiddenAuthors(ctx, h.viewerID)
+	if err != nil {
+		return err
+	}
+	h.ids = make(map[uuid.UUID]struct{}, len(ids))
+	for _, id := range ids {
+		h.ids[id] = struct{}{}
+	}
+	return nil
+}
+
+// hides reports whether chirps by authorID are left out. The set is
+// reloaded every hiddenAuthorsRefresh, keeping the old one if that fails.
+func (h *hiddenAuthors) hides(ctx context.Context, authorID uuid.UUID) bool {
+	if h.viewerID == uuid.Nil {
+		return false
+	}
+	if time.Since(h.loaded) >= hiddenAuthorsRefresh {
+		err := h.load(ctx)
+		if err != nil {
+			log.Printf("Can't reload blocks and mutes of %s: %v", h.viewerID, err)
+		}
+	}
+	_, ok := h.ids[authorID]
+	return ok
+}
+
+func (cfg *APIConfig) publishChirpEvent(ctx context.Context, eventType string, ch Chirp) {
+	var payload any = ch
+	if eventType == events.ChirpDeleted {
+		payload = struct {
+			ID      uuid.UUID `json:"id"`
+			User_id uuid.UUID `json:"user_id"`
+		}{
+			ID:      ch.ID,
+			User_id: ch.User_id,
+		}
+	}
+
+	data, err := json.Marshal(payload)
+	if err != nil {
+		log.Printf("Can't marshal %s event: %v", eventType, err)
+		return
+	}
+
+	err = cfg.db.CreateChirpEvent(ctx, database.CreateChirpEventParams{
+		EventType: eventType,
+		ChirpID:   ch.ID,
+		UserID:    ch.User_id,
+		Payload:   data,
+	})
+	if err != nil {
+		log.Printf("Can't store %s event: %v", eventType, err)
+	}
+}
+
+func writeEvent(w http.ResponseWriter, id int64, eventType string, data []byte) error {
+	_, err := fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", id, eventType, data)
+	return err
+}
+
+func (cfg *APIConfig) streamChirpsHandler(w http.ResponseWriter, r *http.Request) {
+	var authorID uuid.UUID
+	var err error
+
+	AIDParam := r.URL.Query().Get("author_id")
+	filterByUserID := false
+	if AIDParam != "" {
+		authorID, err = uuid.Parse(AIDParam)
+		if err != nil {
+			respondError(w, "Can't parse authorID", 500, err)
+			return
+		}
+		filterByUserID = true
+	}
+
+	lastEventID := int64(0)
+	resume := r.Header.Get("Last-Event-ID")
+	if resume == "" {
+		resume = r.URL.Query().Get("last_event_id")
+	}
+	if resume != "" {
+		lastEventID, err = strconv.ParseInt(resume, 10, 64)
+		if err != nil {
+			respondError(w, "Can't parse Last-Event-ID", 400, err)
+			return
+		}
+	}
+
+	viewerID, err := cfg.viewerID(r)
+	if err != nil {
+		respondError(w, "Unauthorized or Token Invalid", 401, err)
+		return
+	}
+	hidden, err := cfg.newHiddenAuthors(r.Context(), viewerID)
+	if err != nil {
+		respondError(w, "Can't get blocks and mutes", 500, err)
+		return
+	}
+
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		respondError(w, "Streaming unsupported", 500, nil)
+		return
+	}
+
+	// Subscribe before replaying so nothing published in between is missed;
+	// duplicates are skipped by comparing against lastEventID.
+	live, cancel := cfg.events.Subscribe()
+	defer cancel()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(200)
+
+	if resume != "" {
+		for {
+			backlog, err := cfg.db.GetChirpEventsAfter(r.Context(), database.GetChirpEventsAfterParams{
+				AfterID:        lastEventID,
+				Since:          time.Now().UTC().Add(-chirpEventReplayWindow),
+				UserID:         authorID,
+				FilterByUserID: filterByUserID,
+			})
+			if err != nil {
+				log.Printf("Can't replay chirp events: %v", err)
+				return
+			}
+			for _, e := range backlog {
+				lastEventID = e.ID
+				if hidden.hides(r.Context(), e.UserID) {
+					continue
+				}
+				if writeEvent(w, e.ID, e.EventType, e.Payload) != nil {
+					return
+				}
+			}
+			if len(backlog) < 500 {
+				break
+			}
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			_, err := fmt.Fprint(w, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		case e, ok := <-live:
+			if !ok {
+				return
+			}
+			if e.EventType != events.ChirpCreated && e.EventType != events.ChirpDeleted {
+				continue
+			}
+			if e.ID <= lastEventID {
+				continue
+			}
+			if filterByUserID && e.UserID != authorID {
+				continue
+			}
+			if hidden.hides(r.Context(), e.UserID) {
+				continue
+			}
+			if writeEvent(w, e.ID, e.EventType, e.Payload) != nil {
+				return
+			}
+			lastEventID = e.ID
+			flusher.Flush()
+		}
+	}
+}